@@ -0,0 +1,45 @@
+package alpm
+
+import "slices"
+
+// Dependents returns the installed packages which need any of pkgs,
+// directly or through other packages, like pactree -r. Packages which
+// only optionally depend on them are returned separately.
+func (db *LocalDB) Dependents(pkgs ...*Package) (required []*Package, optional []*Package) {
+	seen := make(map[string]bool)
+	for _, pkg := range pkgs {
+		seen[pkg.Name] = true
+	}
+
+	queue := slices.Clone(pkgs)
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, name := range current.RequiredBy {
+			dependent, exists := db.byName[name]
+			if !exists || seen[name] {
+				continue
+			}
+
+			seen[name] = true
+			required = append(required, dependent)
+			queue = append(queue, dependent)
+		}
+	}
+
+	// Packages which optionally use a dependent lose out as well.
+	for _, pkg := range slices.Concat(pkgs, required) {
+		for _, name := range pkg.OptionalFor {
+			dependent, exists := db.byName[name]
+			if !exists || seen[name] {
+				continue
+			}
+
+			seen[name] = true
+			optional = append(optional, dependent)
+		}
+	}
+
+	return required, optional
+}
@@ -0,0 +1,79 @@
+package alpm
+
+import (
+	"slices"
+	"testing"
+)
+
+// dependentsTestDB has a chain of libraries under curl, a pair of
+// packages that depend on each other, and packages that only optionally
+// use the others.
+func dependentsTestDB() *LocalDB {
+	return newTestDB(
+		&Package{Name: "glibc", Reason: ReasonDependency},
+		&Package{Name: "zlib", Reason: ReasonDependency, Depends: []string{"glibc"}, Provides: []string{"libz.so=1-64"}},
+		&Package{Name: "openssl", Reason: ReasonDependency, Depends: []string{"glibc"}},
+		&Package{Name: "curl", Reason: ReasonDependency, Depends: []string{"libz.so=1-64", "openssl"}},
+		&Package{Name: "git", Reason: ReasonExplicit, Depends: []string{"curl"}},
+		&Package{Name: "tool", Reason: ReasonExplicit, Depends: []string{"curl"}, OptDepends: []OptDepend{{Name: "zlib"}}},
+		&Package{Name: "cycle-a", Reason: ReasonDependency, Depends: []string{"zlib", "cycle-b"}},
+		&Package{Name: "cycle-b", Reason: ReasonDependency, Depends: []string{"cycle-a"}},
+		&Package{Name: "viewer", Reason: ReasonExplicit, OptDepends: []OptDepend{{Name: "zlib"}}},
+		&Package{Name: "plugin", Reason: ReasonExplicit, OptDepends: []OptDepend{{Name: "git"}}},
+	)
+}
+
+func TestDependents(t *testing.T) {
+	db := dependentsTestDB()
+
+	tests := []struct {
+		pkgs     []string
+		required []string
+		optional []string
+	}{
+		// curl needs zlib through what it provides, and git needs curl.
+		// tool needs it as well as optionally using zlib, so is only
+		// counted once.
+		{
+			pkgs:     []string{"zlib"},
+			required: []string{"curl", "cycle-a", "git", "tool", "cycle-b"},
+			optional: []string{"viewer", "plugin"},
+		},
+		// The cycle ends the search rather than coming back to where it
+		// started.
+		{
+			pkgs:     []string{"cycle-a"},
+			required: []string{"cycle-b"},
+		},
+		{
+			pkgs:     []string{"openssl"},
+			required: []string{"curl", "git", "tool"},
+			optional: []string{"plugin"},
+		},
+		// Packages asked about aren't their own dependents.
+		{
+			pkgs:     []string{"openssl", "curl"},
+			required: []string{"git", "tool"},
+			optional: []string{"plugin"},
+		},
+		{
+			pkgs: []string{"viewer"},
+		},
+	}
+
+	for _, test := range tests {
+		var pkgs []*Package
+		for _, name := range test.pkgs {
+			pkg, _ := db.Package(name)
+			pkgs = append(pkgs, pkg)
+		}
+
+		required, optional := db.Dependents(pkgs...)
+		if got := packageNames(required); !slices.Equal(got, test.required) {
+			t.Errorf("Dependents(%v) required = %v, want %v", test.pkgs, got, test.required)
+		}
+		if got := packageNames(optional); !slices.Equal(got, test.optional) {
+			t.Errorf("Dependents(%v) optional = %v, want %v", test.pkgs, got, test.optional)
+		}
+	}
+}
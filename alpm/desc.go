@@ -0,0 +1,138 @@
+package alpm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readSections splits a desc or files entry into its %SECTION% blocks.
+// Each block runs until the next blank line.
+func readSections(r io.Reader) (map[string][]string, error) {
+	sections := make(map[string][]string)
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var current string
+	for sc.Scan() {
+		line := sc.Text()
+
+		switch {
+		case line == "":
+			current = ""
+		case current == "" && strings.HasPrefix(line, "%") && strings.HasSuffix(line, "%"):
+			current = strings.Trim(line, "%")
+			sections[current] = nil
+		case current != "":
+			sections[current] = append(sections[current], line)
+		}
+	}
+
+	return sections, sc.Err()
+}
+
+// parseDesc fills pkg from a desc entry. Local and sync databases use
+// the same format, but each only has some of the fields.
+func parseDesc(r io.Reader, pkg *Package) error {
+	sections, err := readSections(r)
+	if err != nil {
+		return err
+	}
+
+	single := func(key string) string {
+		if values := sections[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	pkg.Name = single("NAME")
+	pkg.Base = single("BASE")
+	pkg.Version = single("VERSION")
+	pkg.Description = single("DESC")
+	pkg.URL = single("URL")
+	pkg.Arch = single("ARCH")
+	pkg.Packager = single("PACKAGER")
+	pkg.Filename = single("FILENAME")
+
+	pkg.Licenses = sections["LICENSE"]
+	pkg.Groups = sections["GROUPS"]
+	pkg.Provides = sections["PROVIDES"]
+	pkg.Depends = sections["DEPENDS"]
+	pkg.Conflicts = sections["CONFLICTS"]
+	pkg.Replaces = sections["REPLACES"]
+	pkg.MakeDepends = sections["MAKEDEPENDS"]
+	pkg.CheckDepends = sections["CHECKDEPENDS"]
+	pkg.Validation = sections["VALIDATION"]
+
+	pkg.OptDepends = pkg.OptDepends[:0]
+	for _, line := range sections["OPTDEPENDS"] {
+		pkg.OptDepends = append(pkg.OptDepends, parseOptDepend(line))
+	}
+
+	if pkg.BuildDate, err = parseTimestamp(single("BUILDDATE")); err != nil {
+		return err
+	}
+	if pkg.InstallDate, err = parseTimestamp(single("INSTALLDATE")); err != nil {
+		return err
+	}
+
+	// Local entries call the installed size SIZE, sync entries ISIZE.
+	size := single("SIZE")
+	if size == "" {
+		size = single("ISIZE")
+	}
+	if pkg.InstalledSize, err = parseSize(size); err != nil {
+		return err
+	}
+	if pkg.DownloadSize, err = parseSize(single("CSIZE")); err != nil {
+		return err
+	}
+
+	if reason := single("REASON"); reason == "1" {
+		pkg.Reason = ReasonDependency
+	} else {
+		pkg.Reason = ReasonExplicit
+	}
+
+	if pkg.Name == "" || pkg.Version == "" {
+		return fmt.Errorf("desc entry is missing %%NAME%% or %%VERSION%%")
+	}
+
+	return nil
+}
+
+func parseOptDepend(line string) OptDepend {
+	name, reason, _ := strings.Cut(line, ":")
+	return OptDepend{Name: strings.TrimSpace(name), Reason: strings.TrimSpace(reason)}
+}
+
+func parseTimestamp(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q: %w", value, err)
+	}
+
+	return time.Unix(seconds, 0), nil
+}
+
+func parseSize(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", value, err)
+	}
+
+	return size, nil
+}
@@ -0,0 +1,202 @@
+package alpm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts pacman uses for dates, which depend on the C locale's %c.
+var infoDateLayouts = []string{
+	"Mon 02 Jan 2006 03:04:05 PM MST",
+	"Mon 02 Jan 2006 15:04:05 MST",
+	"Mon Jan _2 15:04:05 2006",
+	time.UnixDate,
+}
+
+// ParseInfo reads the output of pacman -Qi or -Si, which may describe
+// several packages separated by blank lines. Output must be in the C
+// locale, since the field names are translated otherwise. Malformed
+// values are reported, but don't stop the rest from being parsed.
+func ParseInfo(r io.Reader) ([]*Package, error) {
+	var pkgs []*Package
+	var pkg *Package
+	var key string
+	var firstErr error
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+
+		if strings.TrimSpace(line) == "" {
+			pkg = nil
+			key = ""
+			continue
+		}
+
+		// Only Optional Deps spans several lines; later lines are
+		// indented to line up with the first value.
+		if strings.HasPrefix(line, " ") && pkg != nil {
+			if key == "Optional Deps" {
+				pkg.OptDepends = append(pkg.OptDepends, parseInfoOptDepend(strings.TrimSpace(line)))
+			}
+			continue
+		}
+
+		name, value, found := strings.Cut(line, " : ")
+		if !found {
+			continue
+		}
+
+		if pkg == nil {
+			pkg = &Package{}
+			pkgs = append(pkgs, pkg)
+		}
+
+		key = strings.TrimSpace(name)
+		if err := setInfoField(pkg, key, strings.TrimSpace(value)); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return pkgs, err
+	}
+
+	return pkgs, firstErr
+}
+
+func setInfoField(pkg *Package, key string, value string) error {
+	var err error
+
+	switch key {
+	case "Repository":
+		pkg.Repository = value
+	case "Name":
+		pkg.Name = value
+	case "Version":
+		pkg.Version = value
+	case "Description":
+		pkg.Description = infoValue(value)
+	case "Architecture":
+		pkg.Arch = value
+	case "URL":
+		pkg.URL = infoValue(value)
+	case "Licenses":
+		pkg.Licenses = infoList(value)
+	case "Groups":
+		pkg.Groups = infoList(value)
+	case "Provides":
+		pkg.Provides = infoList(value)
+	case "Depends On":
+		pkg.Depends = infoList(value)
+	case "Optional Deps":
+		pkg.OptDepends = nil
+		if value != "None" {
+			pkg.OptDepends = append(pkg.OptDepends, parseInfoOptDepend(value))
+		}
+	case "Required By":
+		pkg.RequiredBy = infoList(value)
+	case "Optional For":
+		pkg.OptionalFor = infoList(value)
+	case "Conflicts With":
+		pkg.Conflicts = infoList(value)
+	case "Replaces":
+		pkg.Replaces = infoList(value)
+	case "Download Size":
+		pkg.DownloadSize, err = parseInfoSize(value)
+	case "Installed Size":
+		pkg.InstalledSize, err = parseInfoSize(value)
+	case "Packager":
+		pkg.Packager = value
+	case "Build Date":
+		pkg.BuildDate, err = parseInfoDate(value)
+	case "Install Date":
+		pkg.InstallDate, err = parseInfoDate(value)
+	case "Install Reason":
+		if strings.HasPrefix(value, "Installed as a dependency") {
+			pkg.Reason = ReasonDependency
+		} else {
+			pkg.Reason = ReasonExplicit
+		}
+	case "Install Script":
+		pkg.HasScript = value == "Yes"
+	case "Validated By":
+		pkg.Validation = infoList(value)
+	}
+
+	return err
+}
+
+func infoValue(value string) string {
+	if value == "None" {
+		return ""
+	}
+	return value
+}
+
+// infoList splits a list value. pacman separates entries with two
+// spaces, since a single space can appear within an entry.
+func infoList(value string) []string {
+	if value == "None" || value == "" {
+		return nil
+	}
+
+	var list []string
+	for _, entry := range strings.Split(value, "  ") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+func parseInfoOptDepend(value string) OptDepend {
+	installed := strings.HasSuffix(value, " [installed]")
+	opt := parseOptDepend(strings.TrimSuffix(value, " [installed]"))
+	opt.IsInstalled = installed
+	return opt
+}
+
+// parseInfoSize converts a size such as "1.50 MiB" back to bytes. The
+// result is only as precise as pacman's two decimal places.
+func parseInfoSize(value string) (int64, error) {
+	number, unit, _ := strings.Cut(value, " ")
+
+	size, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	multipliers := map[string]float64{
+		"B":   1,
+		"KiB": 1 << 10,
+		"MiB": 1 << 20,
+		"GiB": 1 << 30,
+		"TiB": 1 << 40,
+	}
+
+	multiplier, exists := multipliers[unit]
+	if !exists {
+		return 0, fmt.Errorf("unknown size unit %q", unit)
+	}
+
+	return int64(size * multiplier), nil
+}
+
+func parseInfoDate(value string) (time.Time, error) {
+	if value == "None" || value == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range infoDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognised date %q", value)
+}
@@ -0,0 +1,205 @@
+package alpm
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseTestInfo(t *testing.T, name string) []*Package {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", "info", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	pkgs, err := ParseInfo(f)
+	if err != nil {
+		t.Fatalf("ParseInfo: %v", err)
+	}
+	return pkgs
+}
+
+// query.txt is pacman -Qi output for an installed package.
+func TestParseInfoQuery(t *testing.T) {
+	pkgs := parseTestInfo(t, "query.txt")
+	if len(pkgs) != 1 {
+		t.Fatalf("parsed %d packages, want 1", len(pkgs))
+	}
+	python := pkgs[0]
+
+	if python.Name != "python" || python.Version != "3.11.6-1" || python.Arch != "x86_64" || python.URL != "https://www.python.org/" {
+		t.Errorf("python = %+v", python)
+	}
+	if python.Repository != "" || python.DownloadSize != 0 {
+		t.Errorf("installed package has sync details: %+v", python)
+	}
+
+	wantDepends := []string{"bzip2", "expat", "gdbm", "libffi", "libnsl", "libxcrypt", "openssl", "zlib", "tzdata", "mpdecimal"}
+	if !slices.Equal(python.Depends, wantDepends) {
+		t.Errorf("depends = %v, want %v", python.Depends, wantDepends)
+	}
+	if !slices.Equal(python.RequiredBy, []string{"meson", "python-packaging", "python-pip"}) {
+		t.Errorf("required by = %v", python.RequiredBy)
+	}
+	if !slices.Equal(python.OptionalFor, []string{"vim", "git"}) {
+		t.Errorf("optional for = %v", python.OptionalFor)
+	}
+	if !slices.Equal(python.Replaces, []string{"python3"}) {
+		t.Errorf("replaces = %v", python.Replaces)
+	}
+
+	// None leaves a list empty.
+	if python.Groups != nil || python.Conflicts != nil {
+		t.Errorf("groups = %v, conflicts = %v, want none", python.Groups, python.Conflicts)
+	}
+
+	wantOpt := []OptDepend{
+		{Name: "python-setuptools", Reason: "for building Python packages using tooling that is usually bundled with Python"},
+		{Name: "python-pip", Reason: "for installing Python packages using tooling that is usually bundled with Python", IsInstalled: true},
+		{Name: "sqlite", Reason: "for a default database integration", IsInstalled: true},
+		{Name: "tk"},
+	}
+	if !slices.Equal(python.OptDepends, wantOpt) {
+		t.Errorf("optdepends = %+v\nwant %+v", python.OptDepends, wantOpt)
+	}
+
+	if python.InstalledSize != 62201528 {
+		t.Errorf("installed size = %d, want 62201528", python.InstalledSize)
+	}
+	if want := time.Date(2023, 10, 3, 11, 32, 19, 0, time.UTC); !python.BuildDate.Equal(want) {
+		t.Errorf("build date = %v, want %v", python.BuildDate, want)
+	}
+	if want := time.Date(2023, 12, 9, 0, 0, 0, 0, time.UTC); !python.InstallDate.Equal(want) {
+		t.Errorf("install date = %v, want %v", python.InstallDate, want)
+	}
+
+	if python.Reason != ReasonDependency || python.HasScript {
+		t.Errorf("reason = %v, script = %v", python.Reason, python.HasScript)
+	}
+	if !slices.Equal(python.Validation, []string{"Signature"}) {
+		t.Errorf("validated by = %v", python.Validation)
+	}
+}
+
+// sync.txt is pacman -Si output for two packages at once.
+func TestParseInfoSync(t *testing.T) {
+	pkgs := parseTestInfo(t, "sync.txt")
+	if len(pkgs) != 2 {
+		t.Fatalf("parsed %d packages, want 2", len(pkgs))
+	}
+	tzdata, baseDevel := pkgs[0], pkgs[1]
+
+	if tzdata.Repository != "core" || tzdata.Name != "tzdata" || baseDevel.Repository != "extra" || baseDevel.Name != "base-devel" {
+		t.Fatalf("packages = %+v, %+v", tzdata, baseDevel)
+	}
+
+	// Entries are separated by two spaces, since they may contain one.
+	if want := []string{"LicenseRef-Public-Domain", "custom:Public Domain"}; !slices.Equal(tzdata.Licenses, want) {
+		t.Errorf("licenses = %q, want %q", tzdata.Licenses, want)
+	}
+	if want := []string{"MD5 Sum", "SHA-256 Sum", "Signature"}; !slices.Equal(tzdata.Validation, want) {
+		t.Errorf("validated by = %q, want %q", tzdata.Validation, want)
+	}
+	if want := []string{"SHA-256 Sum", "Signature"}; !slices.Equal(baseDevel.Validation, want) {
+		t.Errorf("validated by = %q, want %q", baseDevel.Validation, want)
+	}
+
+	if tzdata.Provides != nil || tzdata.Depends != nil || tzdata.OptDepends != nil {
+		t.Errorf("tzdata = %+v, want no relations", tzdata)
+	}
+	if len(baseDevel.Depends) != 26 || baseDevel.Depends[25] != "which" {
+		t.Errorf("base-devel depends = %v", baseDevel.Depends)
+	}
+	if baseDevel.Packager != "Jan Alexander Steffens (heftig) <heftig@archlinux.org>" {
+		t.Errorf("packager = %q", baseDevel.Packager)
+	}
+
+	tests := []struct {
+		pkg  *Package
+		size int64
+		want int64
+	}{
+		{tzdata, tzdata.DownloadSize, 426618},
+		{tzdata, tzdata.InstalledSize, 1897922},
+		{baseDevel, baseDevel.DownloadSize, 2160},
+		{baseDevel, baseDevel.InstalledSize, 0},
+	}
+	for _, test := range tests {
+		if test.size != test.want {
+			t.Errorf("%s size = %d, want %d", test.pkg.Name, test.size, test.want)
+		}
+	}
+
+	// pacman's %c differs between glibc versions.
+	if want := time.Date(2024, 2, 2, 14, 14, 35, 0, time.UTC); !tzdata.BuildDate.Equal(want) {
+		t.Errorf("tzdata build date = %v, want %v", tzdata.BuildDate, want)
+	}
+	if want := time.Date(2023, 7, 22, 16, 30, 8, 0, time.UTC); !baseDevel.BuildDate.Equal(want) {
+		t.Errorf("base-devel build date = %v, want %v", baseDevel.BuildDate, want)
+	}
+
+	// Installed details aren't part of -Si.
+	if !tzdata.InstallDate.IsZero() || tzdata.RequiredBy != nil {
+		t.Errorf("sync package has install details: %+v", tzdata)
+	}
+}
+
+// A value that can't be parsed is reported, but the rest still is.
+func TestParseInfoMalformed(t *testing.T) {
+	info := strings.Join([]string{
+		"Name            : vim",
+		"Version         : 9.1.0-1",
+		"Installed Size  : 4.00 XiB",
+		"Build Date      : yesterday",
+		"Licenses        : Vim",
+		"",
+		"Name            : gvim",
+		"Installed Size  : 8.00 MiB",
+	}, "\n")
+
+	pkgs, err := ParseInfo(strings.NewReader(info))
+	if err == nil || !strings.HasPrefix(err.Error(), "Installed Size: ") {
+		t.Errorf("err = %v, want the first bad field", err)
+	}
+	if len(pkgs) != 2 {
+		t.Fatalf("parsed %d packages, want 2", len(pkgs))
+	}
+	if vim := pkgs[0]; vim.Version != "9.1.0-1" || vim.InstalledSize != 0 || !slices.Equal(vim.Licenses, []string{"Vim"}) {
+		t.Errorf("vim = %+v", vim)
+	}
+	if gvim := pkgs[1]; gvim.InstalledSize != 8<<20 {
+		t.Errorf("gvim installed size = %d", gvim.InstalledSize)
+	}
+}
+
+func TestParseInfoSize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{"0.00 B", 0},
+		{"512.00 B", 512},
+		{"1.50 KiB", 1536},
+		{"1.00 MiB", 1 << 20},
+		{"2.00 GiB", 2 << 30},
+		{"1.00 TiB", 1 << 40},
+	}
+
+	for _, test := range tests {
+		if got, err := parseInfoSize(test.value); err != nil || got != test.want {
+			t.Errorf("parseInfoSize(%q) = %d, %v, want %d", test.value, got, err, test.want)
+		}
+	}
+
+	for _, value := range []string{"1.00 XiB", "many MiB", ""} {
+		if _, err := parseInfoSize(value); err == nil {
+			t.Errorf("parseInfoSize(%q) succeeded", value)
+		}
+	}
+}
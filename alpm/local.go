@@ -0,0 +1,294 @@
+package alpm
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalDB is the database of installed packages, found under the local
+// directory of pacman's DBPath. Each package has its own directory
+// holding a desc entry, a files entry and a compressed mtree.
+type LocalDB struct {
+	Dir      string
+	Packages []*Package
+
+	// Entries which couldn't be read, keyed by their directory. They're
+	// left out of Packages rather than failing the whole database.
+	Errors map[string]error
+
+	byName     map[string]*Package
+	satisfiers map[string][]*Package
+}
+
+func ReadLocalDB(dbPath string) (*LocalDB, error) {
+	dir := filepath.Join(dbPath, "local")
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &LocalDB{
+		Dir:      dir,
+		Packages: make([]*Package, 0, len(entries)),
+		Errors:   make(map[string]error),
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		pkg, err := readLocalPackage(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			db.Errors[entry.Name()] = err
+			continue
+		}
+
+		db.Packages = append(db.Packages, pkg)
+	}
+
+	slices.SortFunc(db.Packages, func(a, b *Package) int { return strings.Compare(a.Name, b.Name) })
+	db.index()
+
+	return db, nil
+}
+
+func readLocalPackage(dir string) (*Package, error) {
+	f, err := os.Open(filepath.Join(dir, "desc"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pkg := &Package{}
+	if err := parseDesc(f, pkg); err != nil {
+		return nil, err
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "install"))
+	pkg.HasScript = err == nil
+
+	return pkg, nil
+}
+
+// index builds the lookups used to resolve dependencies, then fills in
+// the reverse dependency fields of every package and marks the optional
+// dependencies which are installed.
+func (db *LocalDB) index() {
+	db.byName = make(map[string]*Package, len(db.Packages))
+	db.satisfiers = make(map[string][]*Package, len(db.Packages))
+
+	for _, pkg := range db.Packages {
+		db.byName[pkg.Name] = pkg
+		db.satisfiers[pkg.Name] = append(db.satisfiers[pkg.Name], pkg)
+
+		for _, provide := range pkg.Provides {
+			name := DependName(provide)
+			db.satisfiers[name] = append(db.satisfiers[name], pkg)
+		}
+	}
+
+	for _, pkg := range db.Packages {
+		for _, dep := range pkg.Depends {
+			for _, satisfier := range db.satisfiers[DependName(dep)] {
+				satisfier.RequiredBy = appendUnique(satisfier.RequiredBy, pkg.Name)
+			}
+		}
+
+		for i, opt := range pkg.OptDepends {
+			satisfiers := db.Satisfiers(opt.Name)
+			for _, satisfier := range satisfiers {
+				satisfier.OptionalFor = appendUnique(satisfier.OptionalFor, pkg.Name)
+			}
+			pkg.OptDepends[i].IsInstalled = len(satisfiers) > 0
+		}
+	}
+}
+
+func appendUnique(list []string, value string) []string {
+	if slices.Contains(list, value) {
+		return list
+	}
+	return append(list, value)
+}
+
+func (db *LocalDB) Package(name string) (*Package, bool) {
+	pkg, exists := db.byName[name]
+	return pkg, exists
+}
+
+// Satisfiers returns the installed packages which satisfy a dependency,
+// either by name or through their provides.
+func (db *LocalDB) Satisfiers(dep string) []*Package {
+	return db.satisfiers[DependName(dep)]
+}
+
+func (db *LocalDB) packageDir(pkg *Package) string {
+	return filepath.Join(db.Dir, pkg.Name+"-"+pkg.Version)
+}
+
+// LoadFiles reads the file list of pkg, taking sizes and modes from its
+// mtree where one exists. File lists are large, so they're only read
+// when asked for.
+func (db *LocalDB) LoadFiles(pkg *Package) error {
+	dir := db.packageDir(pkg)
+
+	f, err := os.Open(filepath.Join(dir, "files"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sections, err := readSections(f)
+	if err != nil {
+		return err
+	}
+
+	attributes, err := readMtree(filepath.Join(dir, "mtree"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	pkg.Files = make([]File, 0, len(sections["FILES"]))
+	for _, path := range sections["FILES"] {
+		file := File{Path: path}
+		if attr, exists := attributes[strings.TrimSuffix(path, "/")]; exists {
+			file.Size = attr.Size
+			file.Mode = attr.Mode
+			file.ModTime = attr.ModTime
+			file.LinkTarget = attr.LinkTarget
+		}
+		pkg.Files = append(pkg.Files, file)
+	}
+
+	pkg.Backup = pkg.Backup[:0]
+	for _, line := range sections["BACKUP"] {
+		path, hash, _ := strings.Cut(line, "\t")
+		pkg.Backup = append(pkg.Backup, Backup{Path: path, Hash: hash})
+	}
+
+	return nil
+}
+
+// readMtree parses the gzipped mtree spec installed alongside each
+// package, keyed by path without the leading "./". /set and /unset
+// change the keywords applied to the entries after them.
+func readMtree(path string) (map[string]File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	files := make(map[string]File)
+	defaults := make(map[string]string)
+
+	sc := bufio.NewScanner(gz)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		switch fields[0] {
+		case "/set":
+			for _, kv := range fields[1:] {
+				key, value, _ := strings.Cut(kv, "=")
+				defaults[key] = value
+			}
+			continue
+
+		case "/unset":
+			for _, key := range fields[1:] {
+				if key == "all" {
+					clear(defaults)
+				} else {
+					delete(defaults, key)
+				}
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(fields[0], "./") {
+			continue
+		}
+
+		keywords := make(map[string]string, len(defaults)+len(fields))
+		for key, value := range defaults {
+			keywords[key] = value
+		}
+		for _, kv := range fields[1:] {
+			key, value, _ := strings.Cut(kv, "=")
+			keywords[key] = value
+		}
+
+		name := unescapeMtree(strings.TrimPrefix(fields[0], "./"))
+		files[name] = mtreeFile(name, keywords)
+	}
+
+	return files, sc.Err()
+}
+
+func mtreeFile(name string, keywords map[string]string) File {
+	file := File{Path: name}
+
+	file.Size, _ = strconv.ParseInt(keywords["size"], 10, 64)
+
+	if mode, err := strconv.ParseUint(keywords["mode"], 8, 32); err == nil {
+		file.Mode = fs.FileMode(mode)
+	}
+
+	switch keywords["type"] {
+	case "dir":
+		file.Mode |= fs.ModeDir
+	case "link":
+		file.Mode |= fs.ModeSymlink
+		file.LinkTarget = unescapeMtree(keywords["link"])
+	}
+
+	if seconds, _, _ := strings.Cut(keywords["time"], "."); seconds != "" {
+		if unix, err := strconv.ParseInt(seconds, 10, 64); err == nil {
+			file.ModTime = time.Unix(unix, 0)
+		}
+	}
+
+	return file
+}
+
+// unescapeMtree decodes the \ooo octal escapes mtree uses for spaces
+// and other special characters in paths.
+func unescapeMtree(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if code, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(code))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,235 @@
+package alpm
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func readTestLocalDB(t *testing.T) *LocalDB {
+	t.Helper()
+
+	db, err := ReadLocalDB("testdata")
+	if err != nil {
+		t.Fatalf("ReadLocalDB: %v", err)
+	}
+	return db
+}
+
+func TestReadLocalDB(t *testing.T) {
+	db := readTestLocalDB(t)
+
+	var names []string
+	for _, pkg := range db.Packages {
+		names = append(names, pkg.Name)
+	}
+	if want := []string{"curl", "zlib"}; !slices.Equal(names, want) {
+		t.Fatalf("packages = %v, want %v", names, want)
+	}
+
+	curl, _ := db.Package("curl")
+	if curl.Version != "8.5.0-1" || curl.InstalledSize != 1900544 || !curl.HasScript {
+		t.Errorf("curl = %+v", curl)
+	}
+	if curl.Reason != ReasonExplicit {
+		t.Errorf("curl reason = %v, want explicit", curl.Reason)
+	}
+	if !curl.InstallDate.Equal(time.Unix(1702080000, 0)) {
+		t.Errorf("curl install date = %v", curl.InstallDate)
+	}
+
+	zlib, _ := db.Package("zlib")
+	if zlib.Reason != ReasonDependency || zlib.HasScript {
+		t.Errorf("zlib = %+v", zlib)
+	}
+
+	// curl depends on a library zlib provides, and on zlib optionally.
+	if !slices.Equal(zlib.RequiredBy, []string{"curl"}) {
+		t.Errorf("zlib required by %v, want [curl]", zlib.RequiredBy)
+	}
+	if !slices.Equal(zlib.OptionalFor, []string{"curl"}) {
+		t.Errorf("zlib optional for %v, want [curl]", zlib.OptionalFor)
+	}
+	if satisfiers := db.Satisfiers("libz.so=1-64"); len(satisfiers) != 1 || satisfiers[0] != zlib {
+		t.Errorf("libz.so satisfied by %v, want zlib", satisfiers)
+	}
+
+	// Optional dependencies are marked installed, like pacman -Qi does.
+	wantOpt := []OptDepend{
+		{Name: "zlib", Reason: "already installed", IsInstalled: true},
+		{Name: "brotli", Reason: "brotli compression"},
+	}
+	if !slices.Equal(curl.OptDepends, wantOpt) {
+		t.Errorf("curl optdepends = %+v, want %+v", curl.OptDepends, wantOpt)
+	}
+}
+
+func TestReadLocalDBSkipsBadEntries(t *testing.T) {
+	db := readTestLocalDB(t)
+
+	if len(db.Errors) != 2 {
+		t.Fatalf("errors = %v, want broken-1.0-1 and nodesc-2.0-1", db.Errors)
+	}
+	if err := db.Errors["broken-1.0-1"]; err == nil {
+		t.Error("broken-1.0-1 has an invalid build date, but no error")
+	}
+	if err := db.Errors["nodesc-2.0-1"]; !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("nodesc-2.0-1 error = %v, want not exist", err)
+	}
+}
+
+func TestReadLocalDBMissing(t *testing.T) {
+	if _, err := ReadLocalDB(t.TempDir()); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("err = %v, want not exist", err)
+	}
+}
+
+func TestLoadFiles(t *testing.T) {
+	db := readTestLocalDB(t)
+	curl, _ := db.Package("curl")
+
+	if err := db.LoadFiles(curl); err != nil {
+		t.Fatalf("LoadFiles: %v", err)
+	}
+
+	want := []File{
+		{Path: "etc/", Mode: fs.ModeDir | 0o755, ModTime: time.Unix(1701993600, 0)},
+		{Path: "etc/curlrc", Size: 12, Mode: 0o644, ModTime: time.Unix(1701993600, 0)},
+		{Path: "usr/", Mode: fs.ModeDir | 0o755, ModTime: time.Unix(1701993600, 0)},
+		{Path: "usr/bin/", Mode: fs.ModeDir | 0o755, ModTime: time.Unix(1701993600, 0)},
+		{Path: "usr/bin/curl", Size: 300000, Mode: 0o755, ModTime: time.Unix(1701993600, 0)},
+		{Path: "usr/share/", Mode: fs.ModeDir | 0o755, ModTime: time.Unix(1701993600, 0)},
+		{Path: "usr/share/doc/", Mode: fs.ModeDir | 0o755, ModTime: time.Unix(1701993600, 0)},
+		{Path: "usr/share/doc/curl/", Mode: fs.ModeDir | 0o755, ModTime: time.Unix(1701993600, 0)},
+		{Path: "usr/share/doc/curl/read me.txt", Size: 42, Mode: 0o644, ModTime: time.Unix(1701993600, 0)},
+	}
+	if len(curl.Files) != len(want) {
+		t.Fatalf("files = %v, want %v", curl.Files, want)
+	}
+	for i, file := range curl.Files {
+		if file.Path != want[i].Path || file.Size != want[i].Size || file.Mode != want[i].Mode || !file.ModTime.Equal(want[i].ModTime) {
+			t.Errorf("file %d = %+v, want %+v", i, file, want[i])
+		}
+	}
+
+	wantBackup := []Backup{{Path: "etc/curlrc", Hash: "d41d8cd98f00b204e9800998ecf8427e"}}
+	if !slices.Equal(curl.Backup, wantBackup) {
+		t.Errorf("backup = %v, want %v", curl.Backup, wantBackup)
+	}
+}
+
+func TestLoadFilesSymlink(t *testing.T) {
+	db := readTestLocalDB(t)
+	zlib, _ := db.Package("zlib")
+
+	if err := db.LoadFiles(zlib); err != nil {
+		t.Fatalf("LoadFiles: %v", err)
+	}
+
+	i := slices.IndexFunc(zlib.Files, func(f File) bool { return f.Path == "usr/lib/libz.so" })
+	if i < 0 {
+		t.Fatalf("usr/lib/libz.so missing from %v", zlib.Files)
+	}
+	if link := zlib.Files[i]; link.LinkTarget != "libz.so.1.3.1" || link.Mode&fs.ModeSymlink == 0 {
+		t.Errorf("libz.so = %+v, want a symlink to libz.so.1.3.1", link)
+	}
+}
+
+// Without an mtree, the file list is still read but has no sizes.
+func TestLoadFilesWithoutMtree(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "local", "zlib-1.3.1-2")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"desc", "files"} {
+		data, err := os.ReadFile(filepath.Join("testdata", "local", "zlib-1.3.1-2", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := ReadLocalDB(filepath.Dir(filepath.Dir(dir)))
+	if err != nil {
+		t.Fatalf("ReadLocalDB: %v", err)
+	}
+
+	zlib, _ := db.Package("zlib")
+	if err := db.LoadFiles(zlib); err != nil {
+		t.Fatalf("LoadFiles: %v", err)
+	}
+	if len(zlib.Files) != 6 {
+		t.Fatalf("files = %v, want 6", zlib.Files)
+	}
+	for _, file := range zlib.Files {
+		if file.Size != 0 || file.Mode != 0 {
+			t.Errorf("%s has attributes %+v without an mtree", file.Path, file)
+		}
+	}
+}
+
+func TestReadMtree(t *testing.T) {
+	files, err := readMtree(filepath.Join("testdata", "local", "curl-8.5.0-1", "mtree"))
+	if err != nil {
+		t.Fatalf("readMtree: %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		size    int64
+		mode    fs.FileMode
+		modTime int64
+	}{
+		// Takes its mode from the first /set.
+		{"etc/curlrc", 12, 0o644, 1701993600},
+		{"etc", 0, fs.ModeDir | 0o755, 1701993600},
+		// Takes its mode from the second /set, dropping the fraction of
+		// its time.
+		{"usr/bin/curl", 300000, 0o755, 1701993600},
+		// Overrides the mode from /set, and has an escaped space.
+		{"usr/share/doc/curl/read me.txt", 42, 0o644, 1701993600},
+		// Follows a blank line, and /unset mode.
+		{"usr/share/doc/curl/COPYING", 1088, 0, 1701993600},
+		// Follows /unset all, which drops every default.
+		{"usr/share/doc/curl/NEWS", 7, 0, 1701993600},
+	}
+
+	for _, test := range tests {
+		file, exists := files[test.path]
+		if !exists {
+			t.Errorf("%s missing", test.path)
+			continue
+		}
+		if file.Size != test.size || file.Mode != test.mode || file.ModTime.Unix() != test.modTime {
+			t.Errorf("%s = %+v, want size %d, mode %v, time %d", test.path, file, test.size, test.mode, test.modTime)
+		}
+	}
+}
+
+func TestReadMtreeNotGzipped(t *testing.T) {
+	if _, err := readMtree(filepath.Join("testdata", "local", "curl-8.5.0-1", "files")); err == nil {
+		t.Error("reading a plain text mtree succeeded")
+	}
+}
+
+func TestUnescapeMtree(t *testing.T) {
+	tests := map[string]string{
+		`plain`:            "plain",
+		`read\040me.txt`:   "read me.txt",
+		`tab\011and\040sp`: "tab\tand sp",
+		`trailing\04`:      `trailing\04`,
+		`not\08octal`:      `not\08octal`,
+	}
+
+	for escaped, want := range tests {
+		if got := unescapeMtree(escaped); got != want {
+			t.Errorf("unescapeMtree(%q) = %q, want %q", escaped, got, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package alpm
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"time"
+)
+
+const DefaultLogPath = "/var/log/pacman.log"
+
+// Timestamps have been written in both of these formats over the years.
+var logTimeFormats = []string{"2006-01-02T15:04:05-0700", "2006-01-02 15:04"}
+
+// PackageLog is what pacman's log says about packages over time: when
+// each was last upgraded, and when each was last removed. Packages
+// which never were are missing.
+type PackageLog struct {
+	Upgraded map[string]time.Time
+	Removed  map[string]time.Time
+}
+
+func ReadPackageLog(logPath string) (*PackageLog, error) {
+	f, err := os.Open(logPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	log := &PackageLog{
+		Upgraded: make(map[string]time.Time),
+		Removed:  make(map[string]time.Time),
+	}
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// [2024-01-10T10:00:00+0000] [ALPM] upgraded glibc (2.39-1 -> 2.40-1)
+		stamp, rest, found := strings.Cut(strings.TrimPrefix(sc.Text(), "["), "] ")
+		if !found {
+			continue
+		}
+
+		action, rest, _ := strings.Cut(strings.TrimPrefix(rest, "[ALPM] "), " ")
+		name, _, _ := strings.Cut(rest, " ")
+
+		var dates map[string]time.Time
+		switch action {
+		case "upgraded":
+			dates = log.Upgraded
+		case "removed":
+			dates = log.Removed
+		default:
+			continue
+		}
+
+		if date, ok := parseLogTime(stamp); ok {
+			dates[name] = date
+		}
+	}
+
+	return log, sc.Err()
+}
+
+func parseLogTime(stamp string) (time.Time, bool) {
+	for _, format := range logTimeFormats {
+		if t, err := time.Parse(format, stamp); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,45 @@
+package alpm
+
+// IsOrphan reports whether pkg was installed as a dependency and no
+// installed package needs it, even optionally, like pacman -Qdt.
+func IsOrphan(pkg *Package) bool {
+	return pkg.Reason == ReasonDependency && len(pkg.RequiredBy) == 0 && len(pkg.OptionalFor) == 0
+}
+
+// OrphanWaves returns the current orphans, followed by the packages
+// that removing them would leave orphaned, and so on until nothing
+// more would be. pacman -Rs removes every wave at once.
+func (db *LocalDB) OrphanWaves() [][]*Package {
+	removed := make(map[string]bool)
+	isGone := func(names []string) bool {
+		for _, name := range names {
+			if !removed[name] {
+				return false
+			}
+		}
+		return true
+	}
+
+	var waves [][]*Package
+	for {
+		var wave []*Package
+		for _, pkg := range db.Packages {
+			if removed[pkg.Name] || pkg.Reason != ReasonDependency {
+				continue
+			}
+
+			if isGone(pkg.RequiredBy) && isGone(pkg.OptionalFor) {
+				wave = append(wave, pkg)
+			}
+		}
+
+		if len(wave) == 0 {
+			return waves
+		}
+
+		for _, pkg := range wave {
+			removed[pkg.Name] = true
+		}
+		waves = append(waves, wave)
+	}
+}
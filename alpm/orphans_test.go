@@ -0,0 +1,113 @@
+package alpm
+
+import (
+	"slices"
+	"testing"
+)
+
+// newTestDB indexes pkgs as if they'd been read from a local database,
+// filling in RequiredBy and OptionalFor.
+func newTestDB(pkgs ...*Package) *LocalDB {
+	db := &LocalDB{Packages: pkgs, Errors: make(map[string]error)}
+	db.index()
+	return db
+}
+
+func packageNames(pkgs []*Package) []string {
+	var names []string
+	for _, pkg := range pkgs {
+		names = append(names, pkg.Name)
+	}
+	return names
+}
+
+// orphanTestDB has an explicitly installed app with a chain of
+// dependencies, a chain of orphans, and a cycle nothing else needs.
+func orphanTestDB() *LocalDB {
+	return newTestDB(
+		&Package{Name: "app", Reason: ReasonExplicit, Depends: []string{"libfoo", "libz.so=1-64"}, OptDepends: []OptDepend{{Name: "optlib"}}},
+		&Package{Name: "libfoo", Reason: ReasonDependency, Depends: []string{"libbar>=1.0"}},
+		&Package{Name: "libbar", Reason: ReasonDependency},
+		&Package{Name: "zlib", Reason: ReasonDependency, Provides: []string{"libz.so=1-64"}},
+		&Package{Name: "optlib", Reason: ReasonDependency},
+		&Package{Name: "tool", Reason: ReasonExplicit},
+
+		&Package{Name: "leftover", Reason: ReasonDependency, Depends: []string{"libchain"}, OptDepends: []OptDepend{{Name: "optonly"}}},
+		&Package{Name: "libchain", Reason: ReasonDependency, Depends: []string{"libbase"}},
+		&Package{Name: "libbase", Reason: ReasonDependency},
+		&Package{Name: "optonly", Reason: ReasonDependency},
+
+		&Package{Name: "cycle-a", Reason: ReasonDependency, Depends: []string{"cycle-b"}},
+		&Package{Name: "cycle-b", Reason: ReasonDependency, Depends: []string{"cycle-a"}},
+	)
+}
+
+func TestIsOrphan(t *testing.T) {
+	db := orphanTestDB()
+
+	tests := []struct {
+		name     string
+		isOrphan bool
+	}{
+		// Explicitly installed packages never are, needed or not.
+		{"app", false},
+		{"tool", false},
+		{"libfoo", false},
+		// Needed through a chain of dependencies.
+		{"libbar", false},
+		// Needed through what it provides.
+		{"zlib", false},
+		// An optional dependency is enough to keep a package, like -Qdt.
+		{"optlib", false},
+		{"leftover", true},
+		// Only needed by an orphan, so not orphaned until it's removed.
+		{"libchain", false},
+		{"optonly", false},
+		// Each half of the cycle needs the other.
+		{"cycle-a", false},
+		{"cycle-b", false},
+	}
+
+	for _, test := range tests {
+		pkg, _ := db.Package(test.name)
+		if got := IsOrphan(pkg); got != test.isOrphan {
+			t.Errorf("IsOrphan(%s) = %v, want %v", test.name, got, test.isOrphan)
+		}
+	}
+}
+
+func TestOrphanWaves(t *testing.T) {
+	db := orphanTestDB()
+
+	var got [][]string
+	for _, wave := range db.OrphanWaves() {
+		got = append(got, packageNames(wave))
+	}
+
+	// Removing each wave orphans the next. The cycle is never reached,
+	// as pacman -Rs wouldn't remove it either.
+	want := [][]string{
+		{"leftover"},
+		{"libchain", "optonly"},
+		{"libbase"},
+	}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("waves = %v, want %v", got, want)
+	}
+
+	// Working out the waves doesn't change the database.
+	if libchain, _ := db.Package("libchain"); !slices.Equal(libchain.RequiredBy, []string{"leftover"}) {
+		t.Errorf("libchain required by %v after OrphanWaves", libchain.RequiredBy)
+	}
+}
+
+func TestOrphanWavesNone(t *testing.T) {
+	db := newTestDB(
+		&Package{Name: "app", Reason: ReasonExplicit, Depends: []string{"lib"}},
+		&Package{Name: "lib", Reason: ReasonDependency},
+	)
+
+	if waves := db.OrphanWaves(); len(waves) != 0 {
+		t.Errorf("waves = %v, want none", waves)
+	}
+}
@@ -0,0 +1,108 @@
+// Package alpm reads pacman's package databases directly, without
+// going through pacman itself.
+package alpm
+
+import (
+	"io/fs"
+	"strings"
+	"time"
+)
+
+const DefaultDBPath = "/var/lib/pacman"
+
+type InstallReason uint8
+
+const (
+	ReasonExplicit InstallReason = iota
+	ReasonDependency
+)
+
+func (r InstallReason) String() string {
+	if r == ReasonDependency {
+		return "Installed as a dependency for another package"
+	}
+	return "Explicitly installed"
+}
+
+type OptDepend struct {
+	Name   string
+	Reason string
+
+	// Only known for installed packages, whether read from the local
+	// database or parsed from pacman -Qi output.
+	IsInstalled bool
+}
+
+// Package holds everything pacman records about a package. Local
+// packages also carry install details and, once LoadFiles has been
+// called, their file list. Sync packages carry download details.
+type Package struct {
+	Name        string
+	Base        string
+	Version     string
+	Description string
+	URL         string
+	Arch        string
+	Packager    string
+	Repository  string
+	Filename    string
+
+	Licenses   []string
+	Groups     []string
+	Provides   []string
+	Depends    []string
+	OptDepends []OptDepend
+	Conflicts  []string
+	Replaces   []string
+
+	MakeDepends  []string
+	CheckDepends []string
+
+	// Filled in from the rest of the database, like pacman -Qi does.
+	RequiredBy  []string
+	OptionalFor []string
+
+	BuildDate   time.Time
+	InstallDate time.Time
+
+	InstalledSize int64
+	DownloadSize  int64
+
+	Reason     InstallReason
+	Validation []string
+	HasScript  bool
+
+	Files  []File
+	Backup []Backup
+}
+
+type File struct {
+	Path    string
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+
+	// Only set for symlinks.
+	LinkTarget string
+}
+
+func (f File) IsDir() bool {
+	return f.Mode.IsDir() || strings.HasSuffix(f.Path, "/")
+}
+
+// Backup is a configuration file which pacman preserves across
+// upgrades, along with the checksum it was installed with.
+type Backup struct {
+	Path string
+	Hash string
+}
+
+// DependName strips the version constraint and description from a
+// dependency string, e.g. "glibc>=2.38" becomes "glibc".
+func DependName(dep string) string {
+	dep, _, _ = strings.Cut(dep, ":")
+	if i := strings.IndexAny(dep, "<>="); i >= 0 {
+		dep = dep[:i]
+	}
+	return strings.TrimSpace(dep)
+}
@@ -0,0 +1,334 @@
+package alpm
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const DefaultConfigPath = "/etc/pacman.conf"
+
+// SyncIndex holds the packages of every sync database in memory. When
+// two repositories have a package of the same name, the one from the
+// repository listed first in pacman.conf wins, as it does for pacman.
+type SyncIndex struct {
+	Repos    []string
+	Packages []*Package
+
+	// Databases which couldn't be read, keyed by repository. They're
+	// left out of Repos rather than failing the whole index.
+	Errors map[string]error
+
+	byName     map[string]*Package
+	satisfiers map[string][]*Package
+}
+
+// ReadSyncIndex loads every <repo>.db under the sync directory of
+// dbPath. Repositories are ordered as in the pacman.conf at confPath,
+// or alphabetically if it can't be read. Unreadable databases are
+// recorded in Errors, and only fail the index if none could be read.
+func ReadSyncIndex(dbPath string, confPath string) (*SyncIndex, error) {
+	dir := filepath.Join(dbPath, "sync")
+
+	dbFiles, err := filepath.Glob(filepath.Join(dir, "*.db"))
+	if err != nil {
+		return nil, err
+	}
+	if len(dbFiles) == 0 {
+		return nil, fmt.Errorf("no sync databases found in %s", dir)
+	}
+
+	repos := make([]string, 0, len(dbFiles))
+	for _, file := range dbFiles {
+		repos = append(repos, strings.TrimSuffix(filepath.Base(file), ".db"))
+	}
+
+	if order, err := readRepoOrder(confPath); err == nil {
+		slices.SortStableFunc(repos, func(a, b string) int {
+			return repoRank(order, a) - repoRank(order, b)
+		})
+	}
+
+	idx := &SyncIndex{Errors: make(map[string]error)}
+	for _, repo := range repos {
+		pkgs, err := ReadSyncDB(filepath.Join(dir, repo+".db"), repo)
+		if err != nil {
+			idx.Errors[repo] = err
+			continue
+		}
+		idx.Repos = append(idx.Repos, repo)
+		idx.Packages = append(idx.Packages, pkgs...)
+	}
+
+	if len(idx.Repos) == 0 {
+		return nil, fmt.Errorf("%s: %w", repos[0], idx.Errors[repos[0]])
+	}
+
+	idx.index()
+	return idx, nil
+}
+
+func repoRank(order []string, repo string) int {
+	if i := slices.Index(order, repo); i >= 0 {
+		return i
+	}
+	return len(order)
+}
+
+// readRepoOrder returns the repository sections of a pacman.conf in
+// the order they're declared.
+func readRepoOrder(confPath string) ([]string, error) {
+	f, err := os.Open(confPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var repos []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			if section := strings.Trim(line, "[]"); section != "options" {
+				repos = append(repos, section)
+			}
+		}
+	}
+
+	return repos, sc.Err()
+}
+
+// ReadSyncDB parses a single sync database archive. The archive holds
+// one directory per package, each with a desc entry and, in databases
+// written by older versions of repo-add, a separate depends entry.
+func ReadSyncDB(dbFile string, repo string) ([]*Package, error) {
+	f, err := os.Open(dbFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, closeReader, err := decompress(f)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs, err := readSyncArchive(r, repo)
+	if closeErr := closeReader(); err == nil {
+		err = closeErr
+	}
+
+	return pkgs, err
+}
+
+func readSyncArchive(r io.Reader, repo string) ([]*Package, error) {
+	var pkgs []*Package
+	byDir := make(map[string]*Package)
+
+	// Tar orders depends before desc, which would overwrite its fields,
+	// so depends entries are parsed once every desc has been.
+	depends := make(map[string][]byte)
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entry := path.Base(header.Name)
+		if header.Typeflag != tar.TypeReg || (entry != "desc" && entry != "depends") {
+			continue
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+
+		dir := path.Dir(header.Name)
+		if entry == "depends" {
+			depends[dir] = data
+			continue
+		}
+
+		pkg, exists := byDir[dir]
+		if !exists {
+			pkg = &Package{Repository: repo}
+			byDir[dir] = pkg
+			pkgs = append(pkgs, pkg)
+		}
+
+		if err := parseDesc(bytes.NewReader(data), pkg); err != nil {
+			return nil, fmt.Errorf("%s: %w", header.Name, err)
+		}
+	}
+
+	for dir, data := range depends {
+		pkg, exists := byDir[dir]
+		if !exists {
+			continue
+		}
+
+		if err := parseDepends(bytes.NewReader(data), pkg); err != nil {
+			return nil, fmt.Errorf("%s/depends: %w", dir, err)
+		}
+	}
+
+	slices.SortFunc(pkgs, func(a, b *Package) int { return strings.Compare(a.Name, b.Name) })
+	return pkgs, nil
+}
+
+// parseDepends reads the separate depends entry of old databases. Its
+// fields would otherwise have been part of desc.
+func parseDepends(r io.Reader, pkg *Package) error {
+	sections, err := readSections(r)
+	if err != nil {
+		return err
+	}
+
+	pkg.Depends = append(pkg.Depends, sections["DEPENDS"]...)
+	pkg.Provides = append(pkg.Provides, sections["PROVIDES"]...)
+	pkg.Conflicts = append(pkg.Conflicts, sections["CONFLICTS"]...)
+	pkg.Replaces = append(pkg.Replaces, sections["REPLACES"]...)
+	pkg.MakeDepends = append(pkg.MakeDepends, sections["MAKEDEPENDS"]...)
+	pkg.CheckDepends = append(pkg.CheckDepends, sections["CHECKDEPENDS"]...)
+	for _, line := range sections["OPTDEPENDS"] {
+		pkg.OptDepends = append(pkg.OptDepends, parseOptDepend(line))
+	}
+
+	return nil
+}
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	bzip2Magic = []byte("BZh")
+)
+
+// decompress detects how a database is compressed from its magic bytes.
+// The standard library has no zstd or xz support, so those are piped
+// through the zstd and xz tools, one of which pacman itself depends on.
+func decompress(r io.Reader) (io.Reader, func() error, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(6)
+	noop := func() error { return nil }
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gz, gz.Close, nil
+
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return bzip2.NewReader(br), noop, nil
+
+	case bytes.HasPrefix(magic, zstdMagic):
+		return decompressWith(br, "zstd", "-dcq")
+
+	case bytes.HasPrefix(magic, xzMagic):
+		return decompressWith(br, "xz", "-dcq")
+
+	default:
+		return br, noop, nil
+	}
+}
+
+func decompressWith(r io.Reader, tool string, args ...string) (io.Reader, func() error, error) {
+	cmd := exec.Command(tool, args...)
+	cmd.Stdin = r
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("%s is needed to read this database: %w", tool, err)
+	}
+
+	// Drain whatever the archive reader left unread so that the tool
+	// can exit before it's waited on.
+	wait := func() error {
+		io.Copy(io.Discard, stdout)
+		return cmd.Wait()
+	}
+
+	return stdout, wait, nil
+}
+
+func (idx *SyncIndex) index() {
+	idx.byName = make(map[string]*Package, len(idx.Packages))
+	idx.satisfiers = make(map[string][]*Package, len(idx.Packages))
+
+	for _, pkg := range idx.Packages {
+		if _, exists := idx.byName[pkg.Name]; !exists {
+			idx.byName[pkg.Name] = pkg
+		}
+
+		idx.satisfiers[pkg.Name] = append(idx.satisfiers[pkg.Name], pkg)
+		for _, provide := range pkg.Provides {
+			name := DependName(provide)
+			idx.satisfiers[name] = append(idx.satisfiers[name], pkg)
+		}
+	}
+}
+
+// Package returns the package pacman would pick for name, ignoring
+// packages which only provide it.
+func (idx *SyncIndex) Package(name string) (*Package, bool) {
+	pkg, exists := idx.byName[name]
+	return pkg, exists
+}
+
+func (idx *SyncIndex) Satisfiers(dep string) []*Package {
+	return idx.satisfiers[DependName(dep)]
+}
+
+// Search returns the packages whose name or description contains every
+// whitespace-separated term, ignoring case, like pacman -Ss.
+func (idx *SyncIndex) Search(query string) []*Package {
+	terms := strings.Fields(strings.ToLower(query))
+
+	return idx.SearchFunc(func(pkg *Package) bool {
+		name := strings.ToLower(pkg.Name)
+		desc := strings.ToLower(pkg.Description)
+
+		for _, term := range terms {
+			if !strings.Contains(name, term) && !strings.Contains(desc, term) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// SearchFunc returns the packages for which match returns true, in
+// repository order.
+func (idx *SyncIndex) SearchFunc(match func(pkg *Package) bool) []*Package {
+	var results []*Package
+	for _, pkg := range idx.Packages {
+		if match(pkg) {
+			results = append(results, pkg)
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,269 @@
+package alpm
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// The sync databases under testdata are compressed with gzip (core),
+// zstd (extra) and xz (multilib).
+func TestReadSyncDB(t *testing.T) {
+	tests := []struct {
+		repo  string
+		names []string
+	}{
+		{"core", []string{"bash", "glibc", "zlib"}},
+		{"extra", []string{"curl", "zlib"}},
+		{"multilib", []string{"lib32-zlib"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.repo, func(t *testing.T) {
+			pkgs, err := ReadSyncDB(filepath.Join("testdata", "sync", test.repo+".db"), test.repo)
+			if err != nil {
+				t.Fatalf("ReadSyncDB: %v", err)
+			}
+
+			var names []string
+			for _, pkg := range pkgs {
+				names = append(names, pkg.Name)
+				if pkg.Repository != test.repo {
+					t.Errorf("%s is in %q, want %q", pkg.Name, pkg.Repository, test.repo)
+				}
+			}
+			if !slices.Equal(names, test.names) {
+				t.Errorf("packages = %v, want %v", names, test.names)
+			}
+		})
+	}
+}
+
+func TestReadSyncDBDesc(t *testing.T) {
+	pkgs, err := ReadSyncDB(filepath.Join("testdata", "sync", "core.db"), "core")
+	if err != nil {
+		t.Fatalf("ReadSyncDB: %v", err)
+	}
+
+	glibc := pkgs[slices.IndexFunc(pkgs, func(p *Package) bool { return p.Name == "glibc" })]
+	if glibc.Version != "2.39-1" || glibc.Filename != "glibc-2.39-1-x86_64.pkg.tar.zst" {
+		t.Errorf("glibc = %+v", glibc)
+	}
+	if glibc.DownloadSize != 10000000 || glibc.InstalledSize != 48000000 {
+		t.Errorf("glibc sizes = %d, %d", glibc.DownloadSize, glibc.InstalledSize)
+	}
+	if !slices.Equal(glibc.Licenses, []string{"GPL-2.0-or-later", "LGPL-2.1-or-later"}) {
+		t.Errorf("glibc licenses = %v", glibc.Licenses)
+	}
+	wantOpt := []OptDepend{{Name: "gd", Reason: "for memusagestat"}, {Name: "perl", Reason: "for mtrace"}}
+	if !slices.Equal(glibc.OptDepends, wantOpt) {
+		t.Errorf("glibc optdepends = %v, want %v", glibc.OptDepends, wantOpt)
+	}
+
+	// bash is written the old way, with a separate depends entry.
+	bash := pkgs[slices.IndexFunc(pkgs, func(p *Package) bool { return p.Name == "bash" })]
+	if !slices.Equal(bash.Depends, []string{"readline", "glibc"}) || !slices.Equal(bash.Provides, []string{"sh"}) {
+		t.Errorf("bash depends = %v, provides = %v", bash.Depends, bash.Provides)
+	}
+	if len(bash.OptDepends) != 1 || bash.OptDepends[0].Name != "bash-completion" {
+		t.Errorf("bash optdepends = %v", bash.OptDepends)
+	}
+}
+
+func TestReadSyncDBInvalidDesc(t *testing.T) {
+	_, err := ReadSyncDB(filepath.Join("testdata", "broken.db"), "broken")
+	if err == nil || !strings.Contains(err.Error(), "broken-1.0-1/desc") {
+		t.Errorf("err = %v, want an error naming the entry", err)
+	}
+}
+
+// Entries other than desc and depends, and directories, are skipped.
+func TestReadSyncArchive(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	entries := []struct {
+		name     string
+		typeflag byte
+		body     string
+	}{
+		{"vim-9.1-1/", tar.TypeDir, ""},
+		{"vim-9.1-1/desc", tar.TypeReg, "%NAME%\nvim\n\n%VERSION%\n9.1-1\n"},
+		{"vim-9.1-1/files", tar.TypeReg, "%FILES%\nusr/bin/vim\n"},
+		{"vim-9.1-1/depends", tar.TypeReg, "%DEPENDS%\nvim-runtime=9.1-1\n"},
+		{"vim-runtime-9.1-1/desc", tar.TypeReg, "%NAME%\nvim-runtime\n\n%VERSION%\n9.1-1\n"},
+		{"vim-runtime-9.1-1/link", tar.TypeSymlink, ""},
+	}
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Typeflag: entry.typeflag, Mode: 0o644, Size: int64(len(entry.body))}
+		if entry.typeflag == tar.TypeSymlink {
+			header.Linkname = "desc"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(entry.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Uncompressed databases are read as they are.
+	r, closeReader, err := decompress(&buf)
+	if err != nil {
+		t.Fatalf("decompress: %v", err)
+	}
+	defer closeReader()
+
+	pkgs, err := readSyncArchive(r, "extra")
+	if err != nil {
+		t.Fatalf("readSyncArchive: %v", err)
+	}
+
+	if len(pkgs) != 2 || pkgs[0].Name != "vim" || pkgs[1].Name != "vim-runtime" {
+		t.Fatalf("packages = %v", pkgs)
+	}
+	if !slices.Equal(pkgs[0].Depends, []string{"vim-runtime=9.1-1"}) {
+		t.Errorf("vim depends = %v", pkgs[0].Depends)
+	}
+	if len(pkgs[0].Files) != 0 {
+		t.Errorf("vim files = %v, want none from a sync database", pkgs[0].Files)
+	}
+}
+
+func TestReadSyncIndexRepoOrder(t *testing.T) {
+	idx, err := ReadSyncIndex("testdata", filepath.Join("testdata", "pacman.conf"))
+	if err != nil {
+		t.Fatalf("ReadSyncIndex: %v", err)
+	}
+
+	if want := []string{"extra", "core", "multilib"}; !slices.Equal(idx.Repos, want) {
+		t.Errorf("repos = %v, want %v", idx.Repos, want)
+	}
+
+	// Both repositories have zlib, and extra is listed first.
+	zlib, _ := idx.Package("zlib")
+	if zlib.Repository != "extra" || zlib.Version != "1.3-1" {
+		t.Errorf("zlib from %s at %s, want extra at 1.3-1", zlib.Repository, zlib.Version)
+	}
+	if satisfiers := idx.Satisfiers("zlib>=1.3"); len(satisfiers) != 2 {
+		t.Errorf("zlib satisfied by %d packages, want both", len(satisfiers))
+	}
+	if satisfiers := idx.Satisfiers("libc.so"); len(satisfiers) != 1 || satisfiers[0].Name != "glibc" {
+		t.Errorf("libc.so satisfied by %v, want glibc", satisfiers)
+	}
+}
+
+func TestReadSyncIndexWithoutConfig(t *testing.T) {
+	idx, err := ReadSyncIndex("testdata", filepath.Join(t.TempDir(), "pacman.conf"))
+	if err != nil {
+		t.Fatalf("ReadSyncIndex: %v", err)
+	}
+
+	if want := []string{"core", "extra", "multilib"}; !slices.Equal(idx.Repos, want) {
+		t.Errorf("repos = %v, want %v", idx.Repos, want)
+	}
+	if zlib, _ := idx.Package("zlib"); zlib.Repository != "core" {
+		t.Errorf("zlib from %s, want core", zlib.Repository)
+	}
+}
+
+// A database which can't be read is skipped, and the rest still load.
+func TestReadSyncIndexSkipsBrokenRepo(t *testing.T) {
+	dbPath := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dbPath, "sync"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"sync/core.db", "sync/extra.db", "broken.db"} {
+		data, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dbPath, "sync", filepath.Base(file)), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx, err := ReadSyncIndex(dbPath, "")
+	if err != nil {
+		t.Fatalf("ReadSyncIndex: %v", err)
+	}
+
+	if want := []string{"core", "extra"}; !slices.Equal(idx.Repos, want) {
+		t.Errorf("repos = %v, want %v", idx.Repos, want)
+	}
+	if len(idx.Errors) != 1 || idx.Errors["broken"] == nil {
+		t.Errorf("errors = %v, want broken only", idx.Errors)
+	}
+	if _, exists := idx.Package("curl"); !exists {
+		t.Error("curl from extra is missing")
+	}
+	if _, exists := idx.Package("glibc"); !exists {
+		t.Error("glibc from core is missing")
+	}
+}
+
+// With nothing readable, the index fails like a single database would.
+func TestReadSyncIndexAllBroken(t *testing.T) {
+	dbPath := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dbPath, "sync"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", "broken.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dbPath, "sync", "broken.db"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadSyncIndex(dbPath, ""); err == nil || !strings.HasPrefix(err.Error(), "broken: ") {
+		t.Errorf("err = %v, want broken's error", err)
+	}
+}
+
+func TestReadSyncIndexEmpty(t *testing.T) {
+	dbPath := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dbPath, "sync"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadSyncIndex(dbPath, ""); err == nil {
+		t.Error("reading a sync directory without databases succeeded")
+	}
+}
+
+func TestReadRepoOrder(t *testing.T) {
+	repos, err := readRepoOrder(filepath.Join("testdata", "pacman.conf"))
+	if err != nil {
+		t.Fatalf("readRepoOrder: %v", err)
+	}
+
+	if want := []string{"extra", "core", "multilib"}; !slices.Equal(repos, want) {
+		t.Errorf("repos = %v, want %v", repos, want)
+	}
+}
+
+// zstd and xz databases are piped through their tools, so reading them
+// fails with a useful error when the tool isn't installed.
+func TestReadSyncDBMissingTool(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	tests := map[string]string{"extra": "zstd", "multilib": "xz"}
+	for repo, tool := range tests {
+		_, err := ReadSyncDB(filepath.Join("testdata", "sync", repo+".db"), repo)
+		if err == nil || !strings.Contains(err.Error(), tool+" is needed") {
+			t.Errorf("%s: err = %v, want %s to be needed", repo, err, tool)
+		}
+	}
+
+	// gzip is handled without any tools.
+	if _, err := ReadSyncDB(filepath.Join("testdata", "sync", "core.db"), "core"); err != nil {
+		t.Errorf("core: %v", err)
+	}
+}
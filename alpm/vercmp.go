@@ -0,0 +1,153 @@
+package alpm
+
+import "strings"
+
+// Vercmp compares two versions of the form [epoch:]pkgver[-pkgrel] the
+// way libalpm's alpm_pkg_vercmp does, returning -1, 0 or 1. The pkgrel
+// is only compared when both versions have one.
+func Vercmp(a string, b string) int {
+	if a == b {
+		return 0
+	}
+
+	epochA, verA, relA := parseEVR(a)
+	epochB, verB, relB := parseEVR(b)
+
+	result := rpmvercmp(epochA, epochB)
+	if result == 0 {
+		result = rpmvercmp(verA, verB)
+		if result == 0 && relA != "" && relB != "" {
+			result = rpmvercmp(relA, relB)
+		}
+	}
+
+	return result
+}
+
+// parseEVR splits a version into its epoch, version and release. Unlike
+// RPM, a missing epoch is always treated as 0.
+func parseEVR(evr string) (epoch string, version string, release string) {
+	digits := 0
+	for digits < len(evr) && isDigit(evr[digits]) {
+		digits++
+	}
+
+	if digits < len(evr) && evr[digits] == ':' {
+		epoch = evr[:digits]
+		version = evr[digits+1:]
+		if epoch == "" {
+			epoch = "0"
+		}
+	} else {
+		epoch = "0"
+		version = evr
+	}
+
+	if i := strings.LastIndexByte(version, '-'); i >= 0 {
+		release = version[i+1:]
+		version = version[:i]
+	}
+
+	return epoch, version, release
+}
+
+// rpmvercmp compares two version strings segment by segment, where a
+// segment is a run of digits or a run of letters. It's a direct port of
+// the function of the same name in libalpm, quirks included.
+func rpmvercmp(a string, b string) int {
+	if a == b {
+		return 0
+	}
+
+	one, two := 0, 0
+	for one < len(a) && two < len(b) {
+		start1, start2 := one, two
+
+		for one < len(a) && !isAlnum(a[one]) {
+			one++
+		}
+		for two < len(b) && !isAlnum(b[two]) {
+			two++
+		}
+
+		if one >= len(a) || two >= len(b) {
+			break
+		}
+
+		// Separators of different lengths decide the comparison.
+		if one-start1 != two-start2 {
+			if one-start1 < two-start2 {
+				return -1
+			}
+			return 1
+		}
+
+		end1, end2 := one, two
+		isNum := isDigit(a[end1])
+		if isNum {
+			for end1 < len(a) && isDigit(a[end1]) {
+				end1++
+			}
+			for end2 < len(b) && isDigit(b[end2]) {
+				end2++
+			}
+		} else {
+			for end1 < len(a) && isAlpha(a[end1]) {
+				end1++
+			}
+			for end2 < len(b) && isAlpha(b[end2]) {
+				end2++
+			}
+		}
+
+		// Numeric segments are always newer than alpha segments.
+		if two == end2 {
+			if isNum {
+				return 1
+			}
+			return -1
+		}
+
+		segment1, segment2 := a[one:end1], b[two:end2]
+		if isNum {
+			segment1 = strings.TrimLeft(segment1, "0")
+			segment2 = strings.TrimLeft(segment2, "0")
+
+			if len(segment1) != len(segment2) {
+				if len(segment1) > len(segment2) {
+					return 1
+				}
+				return -1
+			}
+		}
+
+		if result := strings.Compare(segment1, segment2); result != 0 {
+			return result
+		}
+
+		one, two = end1, end2
+	}
+
+	if one >= len(a) && two >= len(b) {
+		return 0
+	}
+
+	// A remaining alpha segment never beats an empty one, so "1.0a" is
+	// older than "1.0", but "1.0.1" is newer.
+	if (one >= len(a) && !isAlpha(b[two])) || (one < len(a) && isAlpha(a[one])) {
+		return -1
+	}
+	return 1
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || isAlpha(c)
+}
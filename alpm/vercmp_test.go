@@ -0,0 +1,70 @@
+package alpm
+
+import "testing"
+
+// The cases are pacman's own vercmp tests, each of which is also
+// checked with its arguments swapped.
+func TestVercmp(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want int
+	}{
+		{"equal", "1.5.0", "1.5.0", 0},
+		{"newer patch", "1.5.1", "1.5.0", 1},
+		{"longer version", "1.5.1", "1.5", 1},
+
+		{"equal pkgrel", "1.5.0-1", "1.5.0-1", 0},
+		{"newer pkgrel", "1.5.0-1", "1.5.0-2", -1},
+		{"pkgver before pkgrel", "1.5.0-2", "1.5.1-1", -1},
+		{"pkgrel with shorter pkgver", "1.5-2", "1.5.1-1", -1},
+
+		{"pkgrel only on right", "1.5", "1.5-1", 0},
+		{"pkgrel only on left", "1.5-1", "1.5", 0},
+		{"pkgrel only on left, older pkgver", "1.0-1", "1.1", -1},
+		{"pkgrel only on left, newer pkgver", "1.1-1", "1.0", 1},
+
+		{"alpha suffix is older", "1.5b", "1.5", -1},
+		{"alpha suffix with pkgrel", "1.5b-1", "1.5-1", -1},
+		{"alpha suffix against longer version", "1.5b", "1.5.1", -1},
+		{"a before alpha", "1.0a", "1.0alpha", -1},
+		{"alpha before b", "1.0alpha", "1.0b", -1},
+		{"b before beta", "1.0b", "1.0beta", -1},
+		{"beta before rc", "1.0beta", "1.0rc", -1},
+		{"rc before release", "1.0rc", "1.0", -1},
+		{"dotted alpha is newer", "1.5.a", "1.5", 1},
+		{"dotted alphas", "1.5.b", "1.5.a", 1},
+		{"numeric beats alpha", "1.5.1", "1.5.b", 1},
+		{"dotted alpha with pkgrel", "1.5.b-1", "1.5.b", 0},
+		{"pkgrel against dotted alpha", "1.5-1", "1.5.b", -1},
+
+		{"different separators", "2.0", "2_0", 0},
+		{"mixed separators", "2.0_a", "2_0.a", 0},
+		{"missing separator", "2.0a", "2.0.a", -1},
+		{"longer separator run", "2___a", "2_a", 1},
+
+		{"equal epochs", "0:1.0", "0:1.0", 0},
+		{"epoch then pkgver", "0:1.0", "0:1.1", -1},
+		{"newer epoch", "1:1.0", "0:1.0", 1},
+		{"epoch beats pkgver", "1:1.0", "0:1.1", 1},
+		{"older epoch", "1:1.0", "2:1.1", -1},
+		{"epoch beats pkgrel", "1:1.0", "0:1.0-1", 1},
+		{"epoch with pkgrels", "1:1.0-1", "0:1.1-1", 1},
+		{"missing epoch is zero", "0:1.0", "1.0", 0},
+		{"missing epoch, older pkgver", "0:1.0", "1.1", -1},
+		{"missing epoch, newer pkgver", "0:1.1", "1.0", 1},
+		{"epoch against none", "1:1.0", "1.1", 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Vercmp(test.a, test.b); got != test.want {
+				t.Errorf("Vercmp(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+			}
+			if got := Vercmp(test.b, test.a); got != -test.want {
+				t.Errorf("Vercmp(%q, %q) = %d, want %d", test.b, test.a, got, -test.want)
+			}
+		})
+	}
+}
@@ -4,10 +4,14 @@ import (
 	"cmp"
 	"errors"
 	"fmt"
+	"regexp"
 	"slices"
 	"strings"
+	"time"
 
+	"ptui/alpm"
 	cmd "ptui/command"
+	"ptui/fuzzy"
 	"ptui/types"
 
 	"github.com/charmbracelet/bubbles/textinput"
@@ -27,7 +31,33 @@ type browseModel struct {
 	searchResultLines        []string
 	visibleSearchResultLines []int
 
+	// Without the sync index, results are described by pacman -Ss.
+	// lastResultName is the result its description lines belong to.
+	resultDetails  map[string]searchResultDetail
+	lastResultName string
+
+	// Each change to the search starts a timer, and only the latest
+	// one runs a query.
+	querySeq int
+
+	// A package to select once the current query has finished.
+	pendingSelection string
+
+	// The characters the search matched in each visible line.
+	searchMode    searchMode
+	searchMatches [][]int
+
 	infoLines []string
+	info      packageInfoView
+
+	// When the sync databases can be read directly, searching and
+	// viewing details doesn't need pacman.
+	dbs      *packageDatabases
+	sortMode sortMode
+	marks    markSet
+	table    packageTable
+
+	isSortReversed bool
 
 	fullHeight         int
 	searchResultCursor int
@@ -52,19 +82,55 @@ type browseModel struct {
 
 type browseInitMsg struct{}
 
-func initialBrowseModel() *browseModel {
+// browseQueryMsg is sent once the search has stopped changing.
+type browseQueryMsg struct {
+	seq  int
+	text string
+}
+
+// How long the search has to stay the same before it's queried.
+const queryDelay = 250 * time.Millisecond
+
+// searchResultDetail is what pacman -Ss says about a result, for when
+// the sync databases can't be read.
+type searchResultDetail struct {
+	repository  string
+	version     string
+	description string
+}
+
+// Names are kept short enough to leave room for descriptions.
+const maxBrowseNameWidth = 32
+
+func initialBrowseModel(dbs *packageDatabases) *browseModel {
 	model := browseModel{
 		title:              "Browse",
+		dbs:                dbs,
 		searchResultCursor: 0,
 		isViewingList:      true,
+		marks:              make(markSet),
+		resultDetails:      make(map[string]searchResultDetail),
+		table:              newPackageTable(maxBrowseNameWidth, columnVersion, columnRepository),
 		hotkeys:            make(map[string]types.HotkeyBinding),
 
 		startRoutes: types.MessageRouter[*browseModel, cmd.CommandStartMsg]{
 			PackageList: func(m *browseModel, msg cmd.CommandStartMsg) tea.Cmd {
+				// Queries can start out of order, so an older one may
+				// arrive after the one that replaced it.
+				if msg.CommandId < m.listCmdId {
+					msg.Handle.Cancel()
+					return nil
+				}
+
+				if handle, running := cmd.Lookup(m.listCmdId); running {
+					handle.Cancel()
+				}
+
 				m.isFinishedReadingLines = false
 				m.listCmdId = msg.CommandId
 				m.searchResultLines = m.searchResultLines[:0]
 				m.visibleSearchResultLines = m.visibleSearchResultLines[:0]
+				clear(m.resultDetails)
 
 				m.listViewport.SetContent("Loading results...")
 				return nil
@@ -81,7 +147,11 @@ func initialBrowseModel() *browseModel {
 					return nil
 				}
 
-				m.searchResultLines = append(m.searchResultLines, msg.Lines...)
+				if msg.IsError {
+					m.searchResultLines = append(m.searchResultLines, msg.Lines...)
+				} else {
+					m.addSearchOutput(msg.Lines)
+				}
 				m.buildPackageList()
 				return nil
 			},
@@ -91,6 +161,7 @@ func initialBrowseModel() *browseModel {
 				}
 
 				m.infoLines = append(m.infoLines, msg.Lines...)
+				m.info.SetFromLines(m.infoLines)
 				m.buildInfoList()
 				return nil
 			},
@@ -101,18 +172,21 @@ func initialBrowseModel() *browseModel {
 					return nil
 				}
 
-				if msg.Err != nil {
+				// pacman exits with an error when nothing matches.
+				if msg.Err != nil && !msg.Interrupted && len(m.searchResultLines) > 0 {
 					m.searchResultLines = append(m.searchResultLines, fmt.Sprintf("\n%s\n", msg.Err))
 					m.buildPackageList()
 				}
 
 				m.isFinishedReadingLines = true
+				m.cmds = append(m.cmds, m.selectPendingPackage())
 
 				return nil
 			},
 			PackageInfo: func(m *browseModel, msg cmd.CommandDoneMsg) tea.Cmd {
 				if msg.CommandId == m.infoCmdId && msg.Err != nil {
 					m.infoLines = append(m.infoLines, fmt.Sprintf("\n%s\n", msg.Err))
+					m.info.SetPackage(nil)
 					m.buildInfoList()
 				}
 				return nil
@@ -125,6 +199,20 @@ func initialBrowseModel() *browseModel {
 	model.createHotkey("I", "I", "View Details", model.viewDetails)
 	model.createHotkey("backspace", "Backspace", "Close Details", model.closeDetails)
 	model.createHotkey("enter", "Enter", "Install Selected", model.installSelected)
+	model.createHotkey("X", "X", "Cancel Transaction", cancelTransaction)
+	model.createHotkey("]", "]", "Next Dependency", model.nextLink)
+	model.createHotkey("[", "[", "Previous Dependency", model.previousLink)
+	model.createHotkey("G", "G", "Go To Dependency", model.followLink)
+	model.createHotkey("S", "S", "Cycle Sort", model.cycleSort)
+	model.createHotkey(" ", "Space", "Toggle Mark", model.toggleMark)
+	model.createHotkey("M", "M", "Mark All Visible", model.markAllVisible)
+	model.createHotkey("V", "V", "Invert Marks", model.invertMarks)
+	model.createHotkey("C", "C", "Clear Marks", model.clearMarks)
+	model.createHotkey("ctrl+t", "Ctrl+T", "Cycle Search Mode", model.cycleSearchMode)
+	model.createHotkey("L", "L", "Choose Columns", model.chooseColumns)
+	model.createHotkey("<", "<", "Sort By Previous Column", model.sortByPreviousColumn)
+	model.createHotkey(">", ">", "Sort By Next Column", model.sortByNextColumn)
+	model.createHotkey("~", "~", "Reverse Sort", model.reverseSort)
 
 	slices.SortFunc(model.hotkeysOrdered, func(a, b string) int {
 		hotkeyA := model.hotkeys[a]
@@ -152,7 +240,19 @@ func (m *browseModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case browseInitMsg:
-		m.cmds = append(m.cmds, m.searchPackageDatabase(""))
+		if m.dbs.needsSyncIndex() {
+			m.cmds = append(m.cmds, loadSyncIndex)
+		} else {
+			m.cmds = append(m.cmds, m.searchPackageDatabase(""))
+		}
+
+	case syncIndexLoadedMsg:
+		m.cmds = append(m.cmds, m.searchPackageDatabase(m.searchInput.Value()))
+
+	case browseQueryMsg:
+		if msg.seq == m.querySeq {
+			m.cmds = append(m.cmds, m.searchPackageDatabase(msg.text))
+		}
 
 	case cmd.CommandStartMsg:
 		handler, exists := m.startRoutes[msg.Target]
@@ -191,19 +291,29 @@ func (m *browseModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.infoViewport.Width = msg.Width
 
 			m.searchInput.Width = msg.Width
+
+			m.table.SetWidth(msg.Width)
+			m.buildPackageList()
 		} else {
 			m.listViewport = viewport.New(msg.Width, msg.Height)
 			m.infoViewport = viewport.New(msg.Width, msg.Height)
 			m.hotkeyViewport = viewport.New(msg.Width, len(m.hotkeys))
 
 			m.searchInput = textinput.New()
+			m.searchInput.Prompt = m.searchMode.prompt()
 			m.searchInput.Width = msg.Width
+			m.table.SetWidth(msg.Width)
 
 			m.hasViewportDimensions = true
 		}
 	case tea.KeyMsg:
+		searchText := m.searchInput.Value()
 		handleHotkeyAndSearch(m, msg)
 
+		if m.searchInput.Value() != searchText {
+			m.cmds = append(m.cmds, m.queueQuery())
+		}
+
 		switch msg.String() {
 		case "up", "k":
 			if m.searchResultCursor > 0 {
@@ -228,6 +338,8 @@ func (m *browseModel) View() (final string) {
 	var packageListTopRow string
 	if m.searchInput.Focused() {
 		packageListTopRow = m.searchInput.View()
+	} else if m.isViewingList {
+		packageListTopRow = m.table.Header(m.sortMode, m.isSortReversed)
 	}
 
 	var activeViewport string
@@ -260,7 +372,7 @@ func (m *browseModel) View() (final string) {
 
 	var cursorPositionText string
 	if len(m.visibleSearchResultLines) > 0 {
-		cursorPositionText = fmt.Sprintf(" %d of %d ", m.searchResultCursor+1, len(m.visibleSearchResultLines))
+		cursorPositionText = fmt.Sprintf(" %d of %d%s ", m.searchResultCursor+1, len(m.visibleSearchResultLines), m.marks.borderText())
 	} else {
 		cursorPositionText = " No results "
 	}
@@ -290,6 +402,45 @@ func (m *browseModel) ToggleHotkeys() tea.Cmd {
 	return nil
 }
 
+func (m *browseModel) sortBy(mode sortMode, isReversed bool) tea.Cmd {
+	if !m.isViewingList || m.searchInput.Focused() {
+		return nil
+	}
+
+	if m.dbs.sync == nil {
+		return setStatus("Sorting needs the sync databases")
+	}
+
+	m.sortMode, m.isSortReversed = mode, isReversed
+	m.searchResultCursor = 0
+
+	return tea.Batch(m.searchPackageDatabase(m.searchInput.Value()), setStatus(sortStatus(m.sortMode, m.isSortReversed)))
+}
+
+func (m *browseModel) cycleSort() tea.Cmd {
+	return m.sortBy(nextSortMode(m.sortMode, []sortMode{sortByName, sortByVersion}), false)
+}
+
+func (m *browseModel) sortByPreviousColumn() tea.Cmd {
+	return m.sortBy(m.table.adjacentSortMode(m.sortMode, -1), false)
+}
+
+func (m *browseModel) sortByNextColumn() tea.Cmd {
+	return m.sortBy(m.table.adjacentSortMode(m.sortMode, 1), false)
+}
+
+func (m *browseModel) reverseSort() tea.Cmd {
+	return m.sortBy(m.sortMode, !m.isSortReversed)
+}
+
+func (m *browseModel) chooseColumns() tea.Cmd {
+	if !m.isViewingList || m.searchInput.Focused() {
+		return nil
+	}
+
+	return openDialog(newColumnDialog(&m.table, m.buildPackageList))
+}
+
 func (m *browseModel) toggleSearch() tea.Cmd {
 	if !m.isViewingList {
 		return nil
@@ -305,40 +456,142 @@ func (m *browseModel) toggleSearch() tea.Cmd {
 	return nil
 }
 
+func (m *browseModel) cycleSearchMode() tea.Cmd {
+	if !m.isViewingList {
+		return nil
+	}
+
+	m.searchMode = nextSearchMode(m.searchMode)
+	m.searchInput.Prompt = m.searchMode.prompt()
+	m.searchResultCursor = 0
+	m.buildPackageList()
+
+	return tea.Batch(m.queueQuery(), setStatus(fmt.Sprintf("%s search", m.searchMode)))
+}
+
 func (m *browseModel) buildPackageList() {
 	m.visibleSearchResultLines = m.visibleSearchResultLines[:0]
+	m.searchMatches = m.searchMatches[:0]
 
-	searchText := m.searchInput.Value()
-	for i, line := range m.searchResultLines {
-		if matchesSearch(line, searchText) {
-			m.visibleSearchResultLines = append(m.visibleSearchResultLines, i)
-		}
+	matches, err := rankLines(m.searchResultLines, m.searchInput.Value(), m.searchMode)
+	for _, match := range matches {
+		m.visibleSearchResultLines = append(m.visibleSearchResultLines, match.index)
+		m.searchMatches = append(m.searchMatches, match.positions)
 	}
 
 	if m.searchResultCursor >= len(m.visibleSearchResultLines) {
 		m.searchResultCursor = 0
 	}
 
+	if err != nil {
+		m.listViewport.SetContent(fmt.Sprintf("Invalid regular expression: %s", err))
+		return
+	}
+
 	var builder strings.Builder
 	for i, lineIdx := range m.visibleSearchResultLines {
 		name, _, _ := strings.Cut(m.searchResultLines[lineIdx], "\n")
-		if i == m.searchResultCursor {
-			builder.WriteString(selectedStyle.Render(name) + "\n")
-		} else {
-			builder.WriteString(m.searchResultLines[lineIdx])
+		pkg := m.resultPackage(name)
+
+		row := m.table.Row(
+			name,
+			m.searchMatches[i],
+			m.marks.prefix(name),
+			m.dbs.upgradeMarker(name),
+			i == m.searchResultCursor,
+			pkg,
+			m.dbs,
+		)
+
+		if pkg != nil && pkg.Description != "" {
+			description := truncate("  "+pkg.Description, m.listViewport.Width-lipgloss.Width(row))
+			row += reducedEmphasisStyle.Render(description)
 		}
+
+		builder.WriteString(row + "\n")
 	}
 
 	m.listViewport.SetContent(builder.String())
 }
 
 func (m *browseModel) buildInfoList() {
-	var builder strings.Builder
-	for _, line := range m.infoLines {
-		builder.WriteString(line)
+	content, linkLine := m.info.Render(m.infoViewport.Width, m.infoLines)
+	m.infoViewport.SetContent(content)
+
+	if linkLine >= 0 {
+		scrollIntoView(&m.infoViewport, linkLine)
+	}
+}
+
+func (m *browseModel) nextLink() tea.Cmd {
+	if m.isViewingList {
+		return nil
+	}
+
+	m.info.NextLink()
+	m.buildInfoList()
+	return nil
+}
+
+func (m *browseModel) previousLink() tea.Cmd {
+	if m.isViewingList {
+		return nil
+	}
+
+	m.info.PreviousLink()
+	m.buildInfoList()
+	return nil
+}
+
+// followLink shows the details of the package satisfying the selected
+// dependency, moving the list cursor to it.
+func (m *browseModel) followLink() tea.Cmd {
+	target, selected := m.info.SelectedLink()
+	if m.isViewingList || !selected {
+		return nil
+	}
+
+	if m.dbs.sync != nil {
+		if satisfiers := m.dbs.sync.Satisfiers(target); len(satisfiers) > 0 {
+			target = satisfiers[0].Name
+		}
+	}
+
+	m.searchInput.SetValue("")
+	m.pendingSelection = target
+
+	// Without the sync index, the package is selected once pacman has
+	// finished listing every package.
+	if run := m.searchPackageDatabase(""); run != nil {
+		return run
+	}
+
+	return m.selectPendingPackage()
+}
+
+// selectPendingPackage moves the cursor to the package waiting to be
+// selected, and shows its details.
+func (m *browseModel) selectPendingPackage() tea.Cmd {
+	target := m.pendingSelection
+	if target == "" {
+		return nil
+	}
+	m.pendingSelection = ""
+
+	m.buildPackageList()
+
+	i := slices.IndexFunc(m.visibleSearchResultLines, func(lineIdx int) bool {
+		return m.searchResultLines[lineIdx] == target+"\n"
+	})
+	if i < 0 {
+		return setStatus(fmt.Sprintf("%s is not in the sync databases", target))
 	}
 
-	m.infoViewport.SetContent(builder.String())
+	m.searchResultCursor = i
+	m.buildPackageList()
+	scrollIntoView(&m.listViewport, m.searchResultCursor)
+
+	return m.viewDetails()
 }
 
 func (m *browseModel) viewDetails() tea.Cmd {
@@ -350,6 +603,14 @@ func (m *browseModel) viewDetails() tea.Cmd {
 		return nil
 	}
 
+	if m.dbs.sync != nil {
+		if pkg, exists := m.dbs.sync.Package(name); exists {
+			m.info.SetPackage(pkg)
+			m.buildInfoList()
+			return nil
+		}
+	}
+
 	return cmd.NewCommand().
 		Operation("S").
 		Options("i").
@@ -360,6 +621,7 @@ func (m *browseModel) viewDetails() tea.Cmd {
 
 func (m *browseModel) closeDetails() tea.Cmd {
 	m.isViewingList = true
+	m.info.SetPackage(nil)
 	m.infoViewport.SetContent("")
 
 	return nil
@@ -382,27 +644,243 @@ func (m *browseModel) ResetCursor() {
 	m.buildPackageList()
 }
 
+// queueQuery searches again once the search stops changing, so that
+// typing doesn't start a query for every key.
+func (m *browseModel) queueQuery() tea.Cmd {
+	m.querySeq++
+	seq, text := m.querySeq, m.searchInput.Value()
+
+	return tea.Tick(queryDelay, func(time.Time) tea.Msg {
+		return browseQueryMsg{seq: seq, text: text}
+	})
+}
+
 func (m *browseModel) searchPackageDatabase(text string) tea.Cmd {
+	if m.dbs.sync != nil {
+		results, err := m.searchSyncIndex(text)
+		if err != nil {
+			// The list explains the error when it's filtered.
+			m.buildPackageList()
+			return nil
+		}
+		m.dbs.sortPackages(results, m.sortMode, m.isSortReversed)
+
+		m.searchResultLines = m.searchResultLines[:0]
+		for _, pkg := range results {
+			m.searchResultLines = append(m.searchResultLines, pkg.Name+"\n")
+		}
+
+		m.isFinishedReadingLines = true
+		m.buildPackageList()
+		return nil
+	}
+
+	// pacman -Ss takes regular expressions, so the terms of other
+	// searches are escaped. It can't search fuzzily, so fuzzy searches
+	// only find names and descriptions containing every term.
+	terms := strings.Fields(text)
+	if m.searchMode != searchRegex {
+		for i, term := range terms {
+			terms[i] = regexp.QuoteMeta(term)
+		}
+	}
+
 	return cmd.NewCommand().
 		Operation("S").
-		Options("s", "q").
-		Arguments(text, "--noconfirm").
+		Options("s").
+		Arguments(terms...).
+		Arguments("--noconfirm").
 		Target(PackageList).
 		Run()
 }
 
+// searchSyncIndex finds packages the way pacman -Ss would. Fuzzy
+// searches also find every package whose name matches fuzzily.
+func (m *browseModel) searchSyncIndex(text string) ([]*alpm.Package, error) {
+	switch {
+	case m.searchMode == searchRegex:
+		var regexes []*regexp.Regexp
+		for _, term := range strings.Fields(text) {
+			regex, err := compileSearchRegex(term)
+			if err != nil {
+				return nil, err
+			}
+			regexes = append(regexes, regex)
+		}
+
+		return m.dbs.sync.SearchFunc(func(pkg *alpm.Package) bool {
+			for _, regex := range regexes {
+				if !regex.MatchString(pkg.Name) && !regex.MatchString(pkg.Description) {
+					return false
+				}
+			}
+			return true
+		}), nil
+
+	case m.searchMode == searchFuzzy && text != "":
+		results := m.dbs.sync.Search(text)
+
+		found := make(map[*alpm.Package]bool, len(results))
+		for _, pkg := range results {
+			found[pkg] = true
+		}
+
+		return append(results, m.dbs.sync.SearchFunc(func(pkg *alpm.Package) bool {
+			_, matches := fuzzy.Find(text, pkg.Name)
+			return matches && !found[pkg]
+		})...), nil
+
+	default:
+		return m.dbs.sync.Search(text), nil
+	}
+}
+
+// resultPackage returns what's known about a search result: its sync
+// package, or what pacman -Ss said about it.
+func (m *browseModel) resultPackage(name string) *alpm.Package {
+	if m.dbs.sync != nil {
+		if pkg, exists := m.dbs.sync.Package(name); exists {
+			return pkg
+		}
+	}
+
+	if detail, exists := m.resultDetails[name]; exists {
+		return &alpm.Package{
+			Name:        name,
+			Version:     detail.version,
+			Repository:  detail.repository,
+			Description: detail.description,
+		}
+	}
+
+	return nil
+}
+
+// addSearchOutput reads the results of pacman -Ss, which lists each
+// package as "repo/name version" followed by an indented description.
+func (m *browseModel) addSearchOutput(lines []string) {
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\n")
+
+		if strings.HasPrefix(line, " ") {
+			if detail, exists := m.resultDetails[m.lastResultName]; exists {
+				detail.description = strings.TrimSpace(line)
+				m.resultDetails[m.lastResultName] = detail
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		repository, name, found := strings.Cut(fields[0], "/")
+		if !found {
+			repository, name = "", fields[0]
+		}
+
+		m.searchResultLines = append(m.searchResultLines, name+"\n")
+
+		// Like the sync index, the first repository wins.
+		m.lastResultName = ""
+		if _, exists := m.resultDetails[name]; !exists {
+			detail := searchResultDetail{repository: repository}
+			if len(fields) > 1 {
+				detail.version = fields[1]
+			}
+
+			m.resultDetails[name] = detail
+			m.lastResultName = name
+		}
+	}
+}
+
 func (m *browseModel) installSelected() tea.Cmd {
-	name, err := m.getSelectedPackageName()
+	targets, err := m.transactionTargets()
 
 	if err != nil {
 		return nil
 	}
 
-	return cmd.NewCommand().
-		Operation("S").
-		Arguments(name, "--noconfirm").
-		Target(Background).
-		Run()
+	return previewTransaction(transaction{
+		kind:      installTransaction,
+		targets:   targets,
+		callback:  func() tea.Cmd { return loadLocalDb },
+		onConfirm: func() { m.clearMarks() },
+	})
+}
+
+// transactionTargets is every marked package, or the one under the
+// cursor if none are marked.
+func (m *browseModel) transactionTargets() ([]string, error) {
+	if len(m.marks) > 0 {
+		return m.marks.Names(), nil
+	}
+
+	name, err := m.getSelectedPackageName()
+	if err != nil {
+		return nil, err
+	}
+	return []string{name}, nil
+}
+
+func (m *browseModel) visiblePackageNames() []string {
+	names := make([]string, len(m.visibleSearchResultLines))
+	for i, lineIdx := range m.visibleSearchResultLines {
+		names[i] = strings.TrimSuffix(m.searchResultLines[lineIdx], "\n")
+	}
+	return names
+}
+
+func (m *browseModel) toggleMark() tea.Cmd {
+	name, err := m.getSelectedPackageName()
+	if !m.isViewingList || err != nil {
+		return nil
+	}
+
+	m.marks.Toggle(name)
+
+	// Marking moves on, so that runs of packages can be marked quickly.
+	if m.searchResultCursor < len(m.visibleSearchResultLines)-1 {
+		m.searchResultCursor++
+	}
+
+	m.buildPackageList()
+	scrollIntoView(&m.listViewport, m.searchResultCursor)
+	return nil
+}
+
+func (m *browseModel) markAllVisible() tea.Cmd {
+	if !m.isViewingList {
+		return nil
+	}
+
+	for _, name := range m.visiblePackageNames() {
+		m.marks[name] = struct{}{}
+	}
+
+	m.buildPackageList()
+	return nil
+}
+
+func (m *browseModel) invertMarks() tea.Cmd {
+	if !m.isViewingList {
+		return nil
+	}
+
+	for _, name := range m.visiblePackageNames() {
+		m.marks.Toggle(name)
+	}
+
+	m.buildPackageList()
+	return nil
+}
+
+func (m *browseModel) clearMarks() tea.Cmd {
+	m.marks.Clear()
+	m.buildPackageList()
+	return nil
 }
 
 func (m *browseModel) getSelectedPackageName() (string, error) {
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"ptui/alpm"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type column uint8
+
+const (
+	columnVersion column = iota
+	columnRepository
+	columnSize
+	columnInstallDate
+	columnReason
+)
+
+var allColumns = []column{columnVersion, columnRepository, columnSize, columnInstallDate, columnReason}
+
+func (c column) String() string {
+	switch c {
+	case columnRepository:
+		return "Repo"
+	case columnSize:
+		return "Size"
+	case columnInstallDate:
+		return "Installed"
+	case columnReason:
+		return "Reason"
+	default:
+		return "Version"
+	}
+}
+
+func (c column) width() int {
+	switch c {
+	case columnVersion:
+		return 16
+	default:
+		return 10
+	}
+}
+
+func (c column) sortMode() sortMode {
+	switch c {
+	case columnRepository:
+		return sortByRepository
+	case columnSize:
+		return sortBySize
+	case columnInstallDate:
+		return sortByInstallDate
+	case columnReason:
+		return sortByReason
+	default:
+		return sortByVersion
+	}
+}
+
+// value is what the column shows for pkg. Install details come from
+// the local database, so they're blank for packages which aren't
+// installed.
+func (c column) value(pkg *alpm.Package, dbs *packageDatabases) string {
+	switch c {
+	case columnVersion:
+		return pkg.Version
+	case columnRepository:
+		return dbs.repository(pkg)
+	case columnSize:
+		if pkg.InstalledSize == 0 {
+			return ""
+		}
+		return formatSize(pkg.InstalledSize)
+	}
+
+	installed, exists := dbs.installedPackage(pkg.Name)
+	if !exists {
+		return ""
+	}
+
+	switch c {
+	case columnInstallDate:
+		if installed.InstallDate.IsZero() {
+			return ""
+		}
+		return installed.InstallDate.Format("2006-01-02")
+	case columnReason:
+		if installed.Reason == alpm.ReasonDependency {
+			return "dependency"
+		}
+		return "explicit"
+	default:
+		return ""
+	}
+}
+
+// How narrow names can get before columns are hidden to make room.
+const minNameWidth = 20
+
+const columnGap = 2
+
+// packageTable lays a package list out as a table, showing as many of
+// the chosen columns as fit beside the names.
+type packageTable struct {
+	columns []column
+	visible []column
+
+	width     int
+	nameWidth int
+
+	// Names are no wider than this when set, leaving the rest of the
+	// row for something else.
+	maxNameWidth int
+}
+
+func newPackageTable(maxNameWidth int, columns ...column) packageTable {
+	return packageTable{columns: columns, maxNameWidth: maxNameWidth}
+}
+
+func (t *packageTable) SetWidth(width int) {
+	t.width = width
+	t.layout()
+}
+
+// Toggle shows or hides a column, keeping the columns in their usual
+// order.
+func (t *packageTable) Toggle(c column) {
+	if i := slices.Index(t.columns, c); i >= 0 {
+		t.columns = slices.Delete(t.columns, i, i+1)
+	} else {
+		t.columns = append(t.columns, c)
+		slices.Sort(t.columns)
+	}
+
+	t.layout()
+}
+
+func (t *packageTable) layout() {
+	t.visible = t.visible[:0]
+
+	used := 0
+	for _, c := range t.columns {
+		if t.width-used-c.width()-columnGap < minNameWidth {
+			break
+		}
+
+		used += c.width() + columnGap
+		t.visible = append(t.visible, c)
+	}
+
+	t.nameWidth = t.width - used
+	if t.maxNameWidth > 0 {
+		t.nameWidth = min(t.nameWidth, t.maxNameWidth)
+	}
+}
+
+// sortModes are the modes of the name column and each visible column,
+// from left to right.
+func (t *packageTable) sortModes() []sortMode {
+	modes := []sortMode{sortByName}
+	for _, c := range t.visible {
+		modes = append(modes, c.sortMode())
+	}
+	return modes
+}
+
+// adjacentSortMode is the mode of the column offset places from the
+// one the list is sorted by, wrapping around.
+func (t *packageTable) adjacentSortMode(mode sortMode, offset int) sortMode {
+	modes := t.sortModes()
+	i := max(0, slices.Index(modes, mode))
+	return modes[((i+offset)%len(modes)+len(modes))%len(modes)]
+}
+
+// Header titles the columns, marking the one the list is sorted by. If
+// that column is hidden, the name column says what the sort is.
+func (t *packageTable) Header(mode sortMode, isReversed bool) string {
+	arrow := " ▲"
+	if isReversed {
+		arrow = " ▼"
+	}
+
+	nameTitle := "Name"
+	if mode == sortByName {
+		nameTitle += arrow
+	} else if !slices.Contains(t.sortModes(), mode) {
+		nameTitle += fmt.Sprintf(" (by %s%s)", mode, arrow)
+	}
+
+	var header strings.Builder
+	header.WriteString(padRight(truncate(nameTitle, t.nameWidth), t.nameWidth))
+
+	for _, c := range t.visible {
+		title := c.String()
+		if c.sortMode() == mode {
+			title += arrow
+		}
+
+		header.WriteString(strings.Repeat(" ", columnGap))
+		header.WriteString(alignCell(c, title))
+	}
+
+	return reducedEmphasisStyle.Bold(true).Render(header.String())
+}
+
+// Row renders a package: its name, highlighted where the search
+// matched it, and its cells. pkg can be nil when nothing is known
+// about the package but its name.
+func (t *packageTable) Row(name string, positions []int, prefix string, marker string, isSelected bool, pkg *alpm.Package, dbs *packageDatabases) string {
+	available := t.nameWidth - lipgloss.Width(prefix)
+
+	// The marker gives way to the name when space is short.
+	if available-lipgloss.Width(marker) < minNameWidth/2 {
+		marker = ""
+	}
+	available -= lipgloss.Width(marker)
+
+	style, highlight := defaultStyle, matchStyle
+	if isSelected {
+		style, highlight = selectedStyle, selectedMatchStyle
+	}
+
+	nameCell := prefix + highlightMatches(truncate(name, available), positions, style, highlight) + marker
+	if len(t.visible) == 0 {
+		return nameCell
+	}
+
+	var cells strings.Builder
+	for _, c := range t.visible {
+		var value string
+		if pkg != nil {
+			value = c.value(pkg, dbs)
+		}
+
+		cells.WriteString(strings.Repeat(" ", columnGap))
+		cells.WriteString(alignCell(c, truncate(value, c.width())))
+	}
+
+	return padRight(nameCell, t.nameWidth) + reducedEmphasisStyle.Render(cells.String())
+}
+
+// alignCell pads text to the column's width, lining sizes up on the
+// right.
+func alignCell(c column, text string) string {
+	padding := strings.Repeat(" ", max(0, c.width()-lipgloss.Width(text)))
+	if c == columnSize {
+		return padding + text
+	}
+	return text + padding
+}
+
+// newColumnDialog lets the user choose the table's columns. It reopens
+// after each change, so several can be changed at once.
+func newColumnDialog(t *packageTable, onChange func()) *dialogModel {
+	d := &dialogModel{
+		title:   "Columns",
+		options: []dialogOption{{key: "enter", label: "Done"}},
+	}
+
+	var body strings.Builder
+	for i, c := range allColumns {
+		check := "[ ]"
+		if slices.Contains(t.columns, c) {
+			check = "[x]"
+		}
+
+		key := strconv.Itoa(i + 1)
+		fmt.Fprintf(&body, "%s  %s %s\n", key, check, c)
+
+		d.options = append(d.options, dialogOption{
+			key: key,
+			action: func() tea.Cmd {
+				t.Toggle(c)
+				onChange()
+				return openDialog(newColumnDialog(t, onChange))
+			},
+		})
+	}
+	body.WriteString("\nColumns that don't fit beside the names are hidden.")
+
+	d.body = body.String()
+	return d
+}
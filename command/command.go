@@ -2,11 +2,11 @@ package command
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"os/exec"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,11 +16,19 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-var Program *tea.Program
+// Sender delivers messages back to the Bubble Tea runtime. It is
+// satisfied by *tea.Program, but anything which can accept messages
+// (such as a recorder in tests) can be used instead.
+type Sender interface {
+	Send(msg tea.Msg)
+}
+
+var Program Sender
 
 type CommandStartMsg struct {
 	CommandId int
 	Target    types.StreamTarget
+	Handle    *Handle
 }
 
 type CommandChunkMsg struct {
@@ -31,9 +39,56 @@ type CommandChunkMsg struct {
 }
 
 type CommandDoneMsg struct {
+	CommandId   int
+	Target      types.StreamTarget
+	Err         error
+	Interrupted bool
+
+	// The command line, which is also sent for commands that failed
+	// before they could start.
+	Argv []string
+}
+
+// LockWaitMsg is sent while a command is waiting for another process
+// to release the package database lock.
+type LockWaitMsg struct {
 	CommandId int
 	Target    types.StreamTarget
-	Err       error
+	Holders   []int
+}
+
+// StaleLockMsg is sent when a command couldn't start because the
+// package database lock was left behind by a crashed transaction.
+type StaleLockMsg struct {
+	CommandId int
+	Target    types.StreamTarget
+	Path      string
+}
+
+// PromptMsg is sent when a command stops to ask for input, such as the
+// password requested by the elevation helper or one of pacman's
+// questions. The command stays blocked until Reply is called or it's
+// cancelled.
+type PromptMsg struct {
+	CommandId int
+	Target    types.StreamTarget
+	Text      string
+	Secret    bool
+
+	// The output leading up to the question, such as the list of
+	// providers to choose from.
+	Context []string
+
+	// Choices is empty when the answer has to be typed. Default is
+	// what pacman assumes when the answer is empty.
+	Choices []PromptChoice
+	Default string
+
+	Reply func(answer string) error
+}
+
+type LockClearedMsg struct {
+	Err error
 }
 
 type Command struct {
@@ -42,9 +97,13 @@ type Command struct {
 	args         []string
 	doneCallback func() tea.Cmd
 	target       types.StreamTarget
+	executor     Executor
+	noConfirm    bool
 }
 
-var mutex sync.Mutex
+// transactionMutex stops pTUI's own transactions racing each other
+// for the package database lock.
+var transactionMutex sync.Mutex
 
 func NewCommand() *Command {
 	return &Command{}
@@ -75,59 +134,164 @@ func (c *Command) Callback(cb func() tea.Cmd) *Command {
 	return c
 }
 
+// Executor overrides the executor used to run this command. If it
+// isn't set, DefaultExecutor is used.
+func (c *Command) Executor(e Executor) *Command {
+	c.executor = e
+	return c
+}
+
+// NoConfirm stops pacman asking questions by passing --noconfirm,
+// unless the command runs in a terminal where they can be answered.
+func (c *Command) NoConfirm() *Command {
+	c.noConfirm = true
+	return c
+}
+
 func (c *Command) Run() tea.Cmd {
+	_, run := c.start()
+	return run
+}
+
+// start is Run, but also returns the id the command will report in
+// its messages.
+func (c *Command) start() (int, tea.Cmd) {
 	var builtCommand []string
 
 	mainOp := c.operation + strings.Join(c.options, "")
 
-	builtCommand = append(builtCommand, mainOp)
+	if mainOp != "" {
+		builtCommand = append(builtCommand, mainOp)
+	}
 	builtCommand = append(builtCommand, c.args...)
 
-	return startCommand(builtCommand, c.target, c.doneCallback)
+	executor := c.executor
+	if executor == nil {
+		executor = DefaultExecutor
+		if c.isTransaction() && TransactionExecutor != nil {
+			executor = TransactionExecutor
+		}
+	}
+
+	if c.noConfirm && !isTerminal(executor) {
+		builtCommand = append(builtCommand, "--noconfirm")
+	}
+
+	return startCommand(executor, builtCommand, c.isTransaction(), c.target, c.doneCallback)
+}
+
+// isTransaction reports whether the command changes the system, which
+// means pacman will need to take the package database lock.
+func (c *Command) isTransaction() bool {
+	isPrint := slices.Contains(c.options, "p") || slices.Contains(c.args, "--print")
+
+	// A command pointed at a database of its own, as checkupdates does,
+	// leaves the system's alone.
+	hasOwnDB := slices.ContainsFunc(c.args, func(arg string) bool {
+		return arg == "--dbpath" || strings.HasPrefix(arg, "--dbpath=")
+	})
+	if hasOwnDB {
+		return false
+	}
+
+	switch c.operation {
+	case "-R", "-U":
+		return !isPrint
+	case "-S":
+		// Refreshing the databases needs the lock even when printing.
+		if slices.Contains(c.options, "y") {
+			return true
+		}
+
+		for _, opt := range c.options {
+			switch opt {
+			case "s", "i", "l", "g":
+				return false
+			}
+		}
+
+		return !isPrint
+	default:
+		return false
+	}
+}
+
+// ClearStaleLock removes a stale package database lock, typically
+// after the user has confirmed that no transaction is running. Without
+// root, the file is removed through the elevation helper and streamed
+// to target like any other command.
+func ClearStaleLock(target types.StreamTarget) tea.Cmd {
+	if Elevation == nil {
+		return func() tea.Msg {
+			return LockClearedMsg{Err: Lock.ClearStale()}
+		}
+	}
+
+	if Lock.Inspect().State != LockStale {
+		return func() tea.Msg {
+			return LockClearedMsg{Err: ErrLockNotStale}
+		}
+	}
+
+	return NewCommand().
+		Executor(NewElevatedExecutor(Elevation, "rm")).
+		Arguments("-f", "--", Lock.Path).
+		Target(target).
+		Callback(func() tea.Cmd {
+			return func() tea.Msg {
+				if Lock.Inspect().State != LockFree {
+					return LockClearedMsg{Err: errors.New("lock file is still present")}
+				}
+				return LockClearedMsg{}
+			}
+		}).
+		Run()
 }
 
 var nextId atomic.Int32
 
-func startCommand(args []string, target types.StreamTarget, cb func() tea.Cmd) tea.Cmd {
-	id := (int)(nextId.Load())
-	nextId.Add(1)
+func startCommand(executor Executor, args []string, needsLock bool, target types.StreamTarget, cb func() tea.Cmd) (int, tea.Cmd) {
+	id := (int)(nextId.Add(1) - 1)
+
+	argv := commandLine(executor, args)
 
 	// The Bubble Tea runtime does not guarantee an execution
 	// order when batching tea.Cmds. In order to distinguish
 	// concurrent pTUI commands from an external database lock,
 	// checks for both are required.
-	return func() tea.Msg {
-		mutex.Lock()
-		defer mutex.Unlock()
+	return id, func() tea.Msg {
+		handle := newHandle(id, target, args, argv, needsLock, elevatorOf(executor))
+		handle.IsTerminal = isTerminal(executor)
+		running.add(handle)
 
 		// It is possible that the pacman database is locked
-		// by an another, such as a cron job or another instance
-		// of pTUI. If this is the case, exit early
-		if !tryGetDbLock() {
-			return func() tea.Msg {
-				return CommandDoneMsg{
-					CommandId: id,
-					Target:    target,
-					Err:       errors.New("could not acquire package database lock"),
-				}
+		// by another process, such as a cron job or another instance
+		// of pTUI. Queries don't need the lock, so they start straight
+		// away, but transactions must wait for it to be released. Only
+		// transactions hold the mutex while waiting, so that two of
+		// them can't both see the lock free and start together.
+		if needsLock {
+			transactionMutex.Lock()
+			defer transactionMutex.Unlock()
+
+			_, err := Lock.Wait(handle.cancelled, func(status LockStatus) {
+				Program.Send(LockWaitMsg{CommandId: id, Target: target, Holders: status.Holders})
+			})
+
+			if errors.Is(err, ErrStaleLock) {
+				Program.Send(StaleLockMsg{CommandId: id, Target: target, Path: Lock.Path})
 			}
-		}
-
-		cmd := exec.Command("pacman", args...)
-
-		stdout, err := cmd.StdoutPipe()
-		if err != nil {
-			return CommandDoneMsg{CommandId: id, Target: target, Err: err}
-		}
 
-		stderr, err := cmd.StderrPipe()
-		if err != nil {
-			return CommandDoneMsg{CommandId: id, Target: target, Err: err}
+			if err != nil {
+				running.remove(id)
+				return CommandDoneMsg{CommandId: id, Target: target, Err: err, Interrupted: handle.WasCancelled(), Argv: argv}
+			}
 		}
 
-		err = cmd.Start()
+		proc, err := handle.start(executor, args)
 		if err != nil {
-			return CommandDoneMsg{Err: err}
+			running.remove(id)
+			return CommandDoneMsg{CommandId: id, Target: target, Err: err, Interrupted: handle.WasCancelled(), Argv: argv}
 		}
 
 		// We need to ensure that the pipes aren't closed until
@@ -136,44 +300,96 @@ func startCommand(args []string, target types.StreamTarget, cb func() tea.Cmd) t
 		var pipes sync.WaitGroup
 		pipes.Add(2)
 
+		// Only transactions and commands run through a helper or a
+		// terminal ask questions. Query output, such as package
+		// descriptions, could otherwise be mistaken for a prompt.
+		detectPrompts := needsLock || isElevated(executor) || isTerminal(executor)
+
 		go func() {
 			defer pipes.Done()
-			streamLines(id, target, stdout, false)
+			streamLines(id, target, proc, proc.Stdout(), false, detectPrompts)
 		}()
 
 		go func() {
 			defer pipes.Done()
-			streamLines(id, target, stderr, true)
+			streamLines(id, target, proc, proc.Stderr(), true, detectPrompts)
 		}()
 
 		go func() {
 			pipes.Wait()
+			err := proc.Wait()
+			running.remove(id)
+
 			if cb != nil {
-				Program.Send(cb())
+				if callback := cb(); callback != nil {
+					Program.Send(callback())
+				}
 			}
-			Program.Send(CommandDoneMsg{CommandId: id, Target: target, Err: cmd.Wait()})
+
+			Program.Send(CommandDoneMsg{
+				CommandId:   id,
+				Target:      target,
+				Err:         err,
+				Interrupted: handle.WasCancelled(),
+				Argv:        argv,
+			})
 		}()
 
-		return CommandStartMsg{CommandId: id, Target: target}
+		return CommandStartMsg{CommandId: id, Target: target, Handle: handle}
 
 	}
 }
 
-func tryGetDbLock() bool {
-	if _, err := os.Stat("/var/lib/pacman/db.lck"); errors.Is(err, os.ErrNotExist) {
-		return true
-	} else {
-		return false
-	}
-}
+// How many lines of output are kept to give a prompt context.
+const maxPromptContext = 12
 
-func streamLines(id int, target types.StreamTarget, reader io.Reader, isStdErr bool) {
+func streamLines(id int, target types.StreamTarget, proc Process, reader io.Reader, isStdErr bool, detectPrompts bool) {
 	sc := bufio.NewScanner(reader)
+	sc.Split(scanLinesOrPrompts)
 	const batchSize = 100
 
-	var batch []string
+	var batch, recent []string
+	var progress progressParser
 	for sc.Scan() {
-		batch = append(batch, sc.Text()+"\n")
+		line := stripEscapes(sc.Text())
+
+		msg, keep := progress.parse(id, target, line)
+		if msg != nil {
+			Program.Send(msg)
+		}
+		if !keep {
+			continue
+		}
+
+		var prompt PromptMsg
+		isPrompt := false
+		if detectPrompts {
+			prompt, isPrompt = parsePrompt(line, recent)
+		}
+
+		if isPrompt {
+			// Anything printed before the prompt needs to be visible
+			// while the user is answering it.
+			if len(batch) > 0 {
+				Program.Send(CommandChunkMsg{CommandId: id, Target: target, Lines: batch, IsError: isStdErr})
+				batch = make([]string, 0, batchSize)
+			}
+
+			prompt.CommandId, prompt.Target, prompt.Reply = id, target, replyTo(proc)
+			Program.Send(prompt)
+
+			recent = nil
+			continue
+		}
+
+		if strings.TrimSpace(line) != "" {
+			recent = append(recent, line)
+			if len(recent) > maxPromptContext {
+				recent = recent[1:]
+			}
+		}
+
+		batch = append(batch, line+"\n")
 
 		if len(batch) >= batchSize {
 			Program.Send(CommandChunkMsg{CommandId: id, Target: target, Lines: batch, IsError: isStdErr})
@@ -199,3 +415,44 @@ func streamLines(id int, target types.StreamTarget, reader io.Reader, isStdErr b
 		})
 	}
 }
+
+// scanLinesOrPrompts splits output into lines ending in "\n", "\r\n"
+// or a lone "\r", which terminals use to redraw progress bars in
+// place. An unterminated line is returned as soon as it looks like a
+// prompt: prompts don't end in a newline, so waiting for one would
+// deadlock with the process waiting for an answer.
+func scanLinesOrPrompts(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+
+		// A carriage return might be the first half of "\r\n".
+		if i+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+		if i+1 < len(data) && data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+		return i + 1, data[:i], nil
+	}
+
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+
+	// Prompts end in a space, so a prompt that has only been partly
+	// read isn't mistaken for a whole one.
+	if line := stripEscapes(string(data)); strings.HasSuffix(line, " ") && isPrompt(line) {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
+
+func replyTo(proc Process) func(string) error {
+	return func(answer string) error {
+		_, err := io.WriteString(proc.Stdin(), answer+"\n")
+		return err
+	}
+}
@@ -0,0 +1,408 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"ptui/types"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const testTarget types.StreamTarget = 7
+
+// runFake runs a query through a fake executor, returning the message
+// its tea.Cmd produced.
+func runFake(t *testing.T, executor *fakeExecutor, c *Command) tea.Msg {
+	t.Helper()
+	return c.Executor(executor).Target(testTarget).Run()()
+}
+
+// chunks joins the output a command sent, separately for each stream.
+func chunks(msgs []tea.Msg, id int) (stdout []string, stderr []string) {
+	for _, msg := range msgs {
+		chunk, ok := msg.(CommandChunkMsg)
+		if !ok || chunk.CommandId != id {
+			continue
+		}
+		if chunk.IsError {
+			stderr = append(stderr, chunk.Lines...)
+		} else {
+			stdout = append(stdout, chunk.Lines...)
+		}
+	}
+	return stdout, stderr
+}
+
+func TestRunRoutesStreams(t *testing.T) {
+	r := useRecorder(t)
+	executor := &fakeExecutor{scripts: []fakeScript{{
+		stdout: "bash 5.2.026-2\nglibc 2.39-1\n",
+		stderr: "warning: database file for 'extra' does not exist\n",
+	}}}
+
+	msg := runFake(t, executor, NewCommand().Operation("Q").Options("n"))
+	start, ok := msg.(CommandStartMsg)
+	if !ok {
+		t.Fatalf("Run returned %T, want CommandStartMsg", msg)
+	}
+	if start.Target != testTarget || start.Handle.CommandId != start.CommandId {
+		t.Errorf("start = %+v", start)
+	}
+
+	done := r.WaitDone(t)
+	if done.CommandId != start.CommandId || done.Target != testTarget || done.Err != nil || done.Interrupted {
+		t.Errorf("done = %+v", done)
+	}
+
+	if want := [][]string{{"-Qn"}}; !slices.EqualFunc(executor.started, want, slices.Equal) {
+		t.Errorf("started with %v, want %v", executor.started, want)
+	}
+
+	stdout, stderr := chunks(r.Messages(), start.CommandId)
+	if want := []string{"bash 5.2.026-2\n", "glibc 2.39-1\n"}; !slices.Equal(stdout, want) {
+		t.Errorf("stdout = %q, want %q", stdout, want)
+	}
+	if want := []string{"warning: database file for 'extra' does not exist\n"}; !slices.Equal(stderr, want) {
+		t.Errorf("stderr = %q, want %q", stderr, want)
+	}
+
+	if _, exists := Lookup(start.CommandId); exists {
+		t.Error("command is still registered after finishing")
+	}
+}
+
+func TestRunBatchesOutput(t *testing.T) {
+	r := useRecorder(t)
+
+	var output strings.Builder
+	for i := range 250 {
+		fmt.Fprintf(&output, "package-%d 1.0-1\n", i)
+	}
+	executor := &fakeExecutor{scripts: []fakeScript{{stdout: output.String()}}}
+
+	start := runFake(t, executor, NewCommand().Operation("Q")).(CommandStartMsg)
+	r.WaitDone(t)
+
+	var sizes []int
+	for _, msg := range r.Messages() {
+		if chunk, ok := msg.(CommandChunkMsg); ok && chunk.CommandId == start.CommandId {
+			sizes = append(sizes, len(chunk.Lines))
+		}
+	}
+	if want := []int{100, 100, 50}; !slices.Equal(sizes, want) {
+		t.Errorf("chunk sizes = %v, want %v", sizes, want)
+	}
+}
+
+func TestRunCallbackBeforeDone(t *testing.T) {
+	r := useRecorder(t)
+	executor := &fakeExecutor{scripts: []fakeScript{{stdout: "ok\n"}}}
+
+	type refreshedMsg struct{}
+	c := NewCommand().Operation("Q").Callback(func() tea.Cmd {
+		return func() tea.Msg { return refreshedMsg{} }
+	})
+
+	runFake(t, executor, c)
+	r.WaitDone(t)
+
+	msgs := r.Messages()
+	callback := slices.IndexFunc(msgs, func(msg tea.Msg) bool { _, ok := msg.(refreshedMsg); return ok })
+	done := slices.IndexFunc(msgs, func(msg tea.Msg) bool { _, ok := msg.(CommandDoneMsg); return ok })
+	if callback < 0 || callback > done {
+		t.Errorf("callback at %d, done at %d, want the callback first", callback, done)
+	}
+}
+
+func TestRunExitError(t *testing.T) {
+	r := useRecorder(t)
+
+	exitErr := errors.New("exit status 1")
+	executor := &fakeExecutor{scripts: []fakeScript{{
+		stderr: "error: package 'nonexistent' was not found\n",
+		err:    exitErr,
+	}}}
+
+	start := runFake(t, executor, NewCommand().Operation("Q").Arguments("nonexistent")).(CommandStartMsg)
+	done := r.WaitDone(t)
+
+	if !errors.Is(done.Err, exitErr) || done.Interrupted {
+		t.Errorf("done = %+v, want the exit error", done)
+	}
+
+	// The reason it failed is still streamed.
+	if _, stderr := chunks(r.Messages(), start.CommandId); len(stderr) != 1 {
+		t.Errorf("stderr = %q", stderr)
+	}
+}
+
+func TestRunStartError(t *testing.T) {
+	useRecorder(t)
+
+	startErr := errors.New(`exec: "pacman": executable file not found in $PATH`)
+	executor := &fakeExecutor{startErr: startErr}
+
+	msg := runFake(t, executor, NewCommand().Operation("Q"))
+	done, ok := msg.(CommandDoneMsg)
+	if !ok {
+		t.Fatalf("Run returned %T, want CommandDoneMsg", msg)
+	}
+	if !errors.Is(done.Err, startErr) || !slices.Equal(done.Argv, []string{"-Q"}) {
+		t.Errorf("done = %+v", done)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	r := useRecorder(t)
+
+	interrupted := errors.New("signal: interrupt")
+	executor := &fakeExecutor{scripts: []fakeScript{{
+		stdout:         "checking dependencies...\n",
+		untilSignalled: true,
+		signalErr:      interrupted,
+	}}}
+
+	start := runFake(t, executor, NewCommand().Operation("S").Options("s").Arguments("vim")).(CommandStartMsg)
+
+	handle, exists := Lookup(start.CommandId)
+	if !exists {
+		t.Fatal("running command isn't registered")
+	}
+	if latest, _ := Latest(testTarget); latest != handle {
+		t.Error("Latest doesn't return the running command")
+	}
+
+	sig, err := handle.Cancel()
+	if err != nil || sig != syscall.SIGINT {
+		t.Errorf("Cancel = %v, %v, want SIGINT", sig, err)
+	}
+
+	done := r.WaitDone(t)
+	if !done.Interrupted || !errors.Is(done.Err, interrupted) {
+		t.Errorf("done = %+v, want it interrupted", done)
+	}
+	if signals := executor.procs[0].Signals(); len(signals) != 1 || signals[0] != syscall.SIGINT {
+		t.Errorf("signals = %v, want SIGINT", signals)
+	}
+}
+
+// A second cancel escalates, for processes which ignore SIGINT.
+func TestHandleCancelEscalates(t *testing.T) {
+	proc := newFakeProcess(fakeScript{untilSignalled: true})
+	handle := &Handle{proc: proc}
+
+	first, _ := handle.Cancel()
+	second, _ := handle.Cancel()
+	if first != syscall.SIGINT || second != syscall.SIGTERM {
+		t.Errorf("signals = %v, %v, want SIGINT then SIGTERM", first, second)
+	}
+	if !handle.WasCancelled() {
+		t.Error("WasCancelled = false after cancelling")
+	}
+
+	proc.Wait()
+}
+
+// A transaction can be cancelled while it waits for the lock, and is
+// then never started.
+func TestRunCancelWhileWaiting(t *testing.T) {
+	useRecorder(t)
+
+	proc := newFakeProc(t)
+	previous := Lock
+	Lock = newTestLock(t, proc, true)
+	Lock.Timeout = 5 * time.Second
+	t.Cleanup(func() { Lock = previous })
+	proc.add(100, []string{"pacman", "-Syu"})
+
+	executor := &fakeExecutor{scripts: []fakeScript{{stdout: "removing vim...\n"}}}
+	result := make(chan tea.Msg)
+	go func() {
+		result <- runFake(t, executor, NewCommand().Operation("R").Arguments("vim").NoConfirm())
+	}()
+
+	var handle *Handle
+	for deadline := time.Now().Add(5 * time.Second); handle == nil; {
+		if time.Now().After(deadline) {
+			t.Fatal("waiting transaction isn't registered")
+		}
+		handle, _ = LatestTransaction()
+		time.Sleep(time.Millisecond)
+	}
+
+	if sig, err := handle.Cancel(); sig != nil || err != nil {
+		t.Errorf("Cancel = %v, %v, want nothing signalled", sig, err)
+	}
+
+	done, ok := (<-result).(CommandDoneMsg)
+	if !ok || !errors.Is(done.Err, ErrCancelled) || !done.Interrupted {
+		t.Errorf("done = %+v, want it cancelled", done)
+	}
+	if len(executor.started) != 0 {
+		t.Errorf("started %v after cancelling", executor.started)
+	}
+	if _, exists := Lookup(handle.CommandId); exists {
+		t.Error("cancelled transaction is still registered")
+	}
+}
+
+// Queries aren't cancelled as transactions.
+func TestLatestTransaction(t *testing.T) {
+	r := useRecorder(t)
+	useFreeLock(t)
+
+	executor := &fakeExecutor{scripts: []fakeScript{
+		{untilSignalled: true},
+		{untilSignalled: true},
+		{untilSignalled: true},
+	}}
+	first := runFake(t, executor, NewCommand().Operation("S").Arguments("vim")).(CommandStartMsg)
+	runFake(t, executor, NewCommand().Operation("Q").Options("i").Arguments("vim"))
+
+	if latest, _ := LatestTransaction(); latest != first.Handle {
+		t.Errorf("latest transaction = %+v, want the install", latest)
+	}
+
+	second := runFake(t, executor, NewCommand().Operation("R").Arguments("vim")).(CommandStartMsg)
+	if latest, _ := LatestTransaction(); latest != second.Handle {
+		t.Errorf("latest transaction = %+v, want the removal", latest)
+	}
+
+	for _, proc := range executor.procs {
+		proc.Signal(syscall.SIGINT)
+	}
+	for range executor.procs {
+		r.WaitDone(t)
+	}
+	if latest, exists := LatestTransaction(); exists {
+		t.Errorf("latest transaction = %+v after all finished", latest)
+	}
+}
+
+// doas and pkexec become root, so can only be signalled through the
+// helper again.
+func TestHandleSignalThroughHelper(t *testing.T) {
+	executor := &fakeExecutor{scripts: []fakeScript{{refuseSignals: true}}}
+
+	handle := newHandle(1, testTarget, []string{"-Syu"}, nil, true, &Elevator{Helper: "doas"})
+	proc, err := handle.start(executor, handle.Args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.Wait()
+
+	sig, err := handle.Cancel()
+	if sig != syscall.SIGINT || !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("Cancel = %v, %v, want a permission error", sig, err)
+	}
+
+	kill, err := handle.killCommand(sig)
+	if err != nil {
+		t.Fatalf("killCommand: %v", err)
+	}
+	want := []string{"doas", "--", "kill", "-s", "INT", "--", strconv.Itoa(fakePid)}
+	if got := commandLine(kill.executor, kill.args); !slices.Equal(got, want) {
+		t.Errorf("kill = %q, want %q", got, want)
+	}
+
+	// Without a helper, there's nothing to send it through.
+	handle.elevator = nil
+	if _, err := handle.SignalThroughHelper(sig, testTarget); err == nil {
+		t.Error("signalled through a missing helper")
+	}
+}
+
+func TestRunTransactionWaitsForLock(t *testing.T) {
+	r := useRecorder(t)
+	useFreeLock(t)
+
+	executor := &fakeExecutor{scripts: []fakeScript{{stdout: "removing vim...\n"}}}
+	start, ok := runFake(t, executor, NewCommand().Operation("R").Arguments("vim").NoConfirm()).(CommandStartMsg)
+	if !ok {
+		t.Fatal("transaction didn't start with the lock free")
+	}
+
+	if done := r.WaitDone(t); done.Err != nil {
+		t.Errorf("done = %+v", done)
+	}
+	if want := []string{"-R", "vim", "--noconfirm"}; !slices.Equal(start.Handle.Args, want) {
+		t.Errorf("args = %v, want %v", start.Handle.Args, want)
+	}
+}
+
+// useFreeLock points the lock manager at a lock file nobody holds.
+func useFreeLock(t *testing.T) {
+	t.Helper()
+
+	previous := Lock
+	Lock = NewLockManager(filepath.Join(t.TempDir(), "db.lck"))
+	t.Cleanup(func() { Lock = previous })
+}
+
+func TestRunAnswersTransactionPrompts(t *testing.T) {
+	r := useRecorder(t)
+	useFreeLock(t)
+
+	executor := &fakeExecutor{scripts: []fakeScript{{
+		stdout:         "Packages (1) vim-9.1-1\n\n:: Proceed with installation? [Y/n] ",
+		untilSignalled: true,
+	}}}
+	runFake(t, executor, NewCommand().Operation("S").Arguments("vim"))
+
+	var prompt PromptMsg
+	deadline := time.Now().Add(5 * time.Second)
+	for prompt.Reply == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the prompt")
+		}
+		for _, msg := range r.Messages() {
+			if msg, ok := msg.(PromptMsg); ok {
+				prompt = msg
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if prompt.Text != "Proceed with installation?" || prompt.Default != "y" {
+		t.Errorf("prompt = %+v", prompt)
+	}
+	if err := prompt.Reply("n"); err != nil {
+		t.Fatalf("Reply: %v", err)
+	}
+	if input := executor.procs[0].Input(); input != "n\n" {
+		t.Errorf("input = %q, want the answer", input)
+	}
+
+	executor.procs[0].Signal(syscall.SIGINT)
+	r.WaitDone(t)
+}
+
+// Query output is never taken for a prompt, even when a line looks
+// like one.
+func TestRunIgnoresQueryPrompts(t *testing.T) {
+	r := useRecorder(t)
+
+	executor := &fakeExecutor{scripts: []fakeScript{{
+		stdout: "Description     : Reset your password:\nOverwrite? [y/N]\n",
+	}}}
+	start := runFake(t, executor, NewCommand().Operation("Q").Options("i").Arguments("passwd-reset")).(CommandStartMsg)
+	r.WaitDone(t)
+
+	for _, msg := range r.Messages() {
+		if prompt, ok := msg.(PromptMsg); ok {
+			t.Errorf("query output was taken for a prompt: %+v", prompt)
+		}
+	}
+	if stdout, _ := chunks(r.Messages(), start.CommandId); len(stdout) != 2 {
+		t.Errorf("stdout = %q, want both lines", stdout)
+	}
+}
@@ -0,0 +1,52 @@
+package command
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// The prompt sudo is told to print when it needs a password. It's
+// distinctive so that it can be picked out of the stderr stream.
+const sudoPromptPrefix = "[pTUI] "
+const sudoPrompt = sudoPromptPrefix + "password for %p: "
+
+var Helpers = []string{"sudo", "doas", "pkexec", "run0"}
+
+// Elevator runs a command as root through a helper program. Only sudo
+// can read a password from a pipe; the other helpers either rely on a
+// polkit agent or on cached credentials.
+type Elevator struct {
+	Helper string
+}
+
+// Elevation is the helper used for transactions, or nil when pTUI
+// already runs as root.
+var Elevation *Elevator
+
+func NewElevator(helper string) (*Elevator, error) {
+	if !slices.Contains(Helpers, helper) {
+		return nil, fmt.Errorf("unsupported elevation helper %q, expected one of %s", helper, strings.Join(Helpers, ", "))
+	}
+
+	return &Elevator{Helper: helper}, nil
+}
+
+// Wrap returns the program and arguments which run binary as root.
+func (e *Elevator) Wrap(binary string, args []string) (string, []string) {
+	var wrapped []string
+	switch e.Helper {
+	case "sudo":
+		wrapped = []string{"-S", "-p", sudoPrompt, "--", binary}
+	case "doas":
+		wrapped = []string{"--", binary}
+	default:
+		wrapped = []string{binary}
+	}
+
+	return e.Helper, append(wrapped, args...)
+}
+
+func isPasswordPrompt(line string) bool {
+	return strings.HasPrefix(line, sudoPromptPrefix)
+}
@@ -0,0 +1,163 @@
+package command
+
+import (
+	"io"
+	"os"
+	"os/exec"
+)
+
+// Executor starts package manager processes on behalf of a Command.
+// The default executor runs pacman directly, but wrappers such as
+// paru or yay, or a scripted fake in tests, can be substituted.
+type Executor interface {
+	Start(args []string) (Process, error)
+}
+
+// Process is a started package manager invocation. Both output streams
+// must remain readable until they reach EOF, after which Wait reports
+// how the process exited. Anything written to Stdin is forwarded to the
+// process, which is how prompts are answered.
+type Process interface {
+	Stdin() io.Writer
+	Stdout() io.Reader
+	Stderr() io.Reader
+	Signal(sig os.Signal) error
+	Wait() error
+}
+
+// DefaultExecutor is used by any Command which hasn't been given an
+// executor of its own.
+var DefaultExecutor Executor = NewProcessExecutor("pacman")
+
+// TransactionExecutor is used instead of DefaultExecutor for commands
+// which change the system. It's nil when pTUI already runs as root.
+var TransactionExecutor Executor
+
+// ProcessExecutor runs a pacman-compatible binary as a child process,
+// optionally through a privilege elevation helper.
+type ProcessExecutor struct {
+	Binary   string
+	Elevator *Elevator
+}
+
+func NewProcessExecutor(binary string) *ProcessExecutor {
+	return &ProcessExecutor{Binary: binary}
+}
+
+func NewElevatedExecutor(elevator *Elevator, binary string) *ProcessExecutor {
+	return &ProcessExecutor{Binary: binary, Elevator: elevator}
+}
+
+// NewFakerootExecutor runs binary under fakeroot, which is enough for
+// pacman to refresh a database the user owns without root, as
+// checkupdates does.
+func NewFakerootExecutor(binary string) *ProcessExecutor {
+	return &ProcessExecutor{Binary: binary, Elevator: &Elevator{Helper: "fakeroot"}}
+}
+
+// commandLiner is implemented by executors which can tell what they
+// would run for args.
+type commandLiner interface {
+	CommandLine(args []string) []string
+}
+
+// commandLine returns what executor runs for args, or just args if
+// it can't tell.
+func commandLine(executor Executor, args []string) []string {
+	if liner, ok := executor.(commandLiner); ok {
+		return liner.CommandLine(args)
+	}
+	return args
+}
+
+// isElevated reports whether executor runs commands through an
+// elevation helper, which may ask for a password.
+func isElevated(e Executor) bool {
+	return elevatorOf(e) != nil
+}
+
+// elevatorOf returns the helper executor runs commands through, if any.
+func elevatorOf(e Executor) *Elevator {
+	switch e := e.(type) {
+	case *ProcessExecutor:
+		return e.Elevator
+	case *TerminalExecutor:
+		return e.Elevator
+	default:
+		return nil
+	}
+}
+
+// processId returns the id of proc, if it's a real process.
+func processId(proc Process) (int, bool) {
+	if proc, ok := proc.(interface{ Pid() int }); ok {
+		return proc.Pid(), true
+	}
+	return 0, false
+}
+
+func wrappedCommandLine(elevator *Elevator, binary string, args []string) []string {
+	if elevator == nil {
+		return append([]string{binary}, args...)
+	}
+
+	name, args := elevator.Wrap(binary, args)
+	return append([]string{name}, args...)
+}
+
+func (e *ProcessExecutor) CommandLine(args []string) []string {
+	return wrappedCommandLine(e.Elevator, e.Binary, args)
+}
+
+func (e *ProcessExecutor) Start(args []string) (Process, error) {
+	name, args := e.Binary, args
+	if e.Elevator != nil {
+		name, args = e.Elevator.Wrap(e.Binary, args)
+	}
+
+	cmd := exec.Command(name, args...)
+
+	// Output is parsed, so it mustn't be translated.
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &execProcess{cmd: cmd, stdin: stdin, stdout: stdout, stderr: stderr}, nil
+}
+
+type execProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.Reader
+	stderr io.Reader
+}
+
+func (p *execProcess) Stdin() io.Writer  { return p.stdin }
+func (p *execProcess) Stdout() io.Reader { return p.stdout }
+func (p *execProcess) Stderr() io.Reader { return p.stderr }
+func (p *execProcess) Wait() error       { return p.cmd.Wait() }
+
+func (p *execProcess) Signal(sig os.Signal) error {
+	return p.cmd.Process.Signal(sig)
+}
+
+func (p *execProcess) Pid() int {
+	return p.cmd.Process.Pid
+}
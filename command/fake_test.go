@@ -0,0 +1,230 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fakeScript is what a fakeProcess prints, and how it exits.
+type fakeScript struct {
+	stdout string
+	stderr string
+	err    error
+
+	// The process keeps running after printing until it's signalled,
+	// then exits with signalErr.
+	untilSignalled bool
+	signalErr      error
+
+	// Signals are refused, as they are for a process which has become
+	// root.
+	refuseSignals bool
+}
+
+// fakeExecutor starts a fakeProcess for each script in turn, recording
+// the arguments it was started with.
+type fakeExecutor struct {
+	mutex    sync.Mutex
+	scripts  []fakeScript
+	started  [][]string
+	startErr error
+	procs    []*fakeProcess
+}
+
+func (e *fakeExecutor) Start(args []string) (Process, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.started = append(e.started, args)
+	if e.startErr != nil {
+		return nil, e.startErr
+	}
+	if len(e.scripts) == 0 {
+		return nil, errors.New("fake executor has no scripts left")
+	}
+
+	script := e.scripts[0]
+	e.scripts = e.scripts[1:]
+
+	proc := newFakeProcess(script)
+	e.procs = append(e.procs, proc)
+	return proc, nil
+}
+
+// fakePid is the process id every fakeProcess reports.
+const fakePid = 4321
+
+type fakeProcess struct {
+	refuseSignals bool
+
+	stdout *io.PipeReader
+	stderr *io.PipeReader
+
+	mutex   sync.Mutex
+	stdin   bytes.Buffer
+	signals []os.Signal
+
+	signalled chan struct{}
+	done      chan struct{}
+	err       error
+}
+
+func newFakeProcess(script fakeScript) *fakeProcess {
+	stdout, stdoutWriter := io.Pipe()
+	stderr, stderrWriter := io.Pipe()
+
+	p := &fakeProcess{
+		refuseSignals: script.refuseSignals,
+		stdout:        stdout,
+		stderr:        stderr,
+		signalled:     make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	// Both streams are written at once, like a real process would, so
+	// that neither blocks the other. Even an empty write to a pipe
+	// waits for a reader, so those are skipped.
+	var output sync.WaitGroup
+	write := func(w io.Writer, s string) {
+		output.Add(1)
+		go func() {
+			defer output.Done()
+			if s != "" {
+				io.WriteString(w, s)
+			}
+		}()
+	}
+	write(stdoutWriter, script.stdout)
+	write(stderrWriter, script.stderr)
+
+	go func() {
+		output.Wait()
+
+		p.err = script.err
+		if script.untilSignalled {
+			<-p.signalled
+			p.err = script.signalErr
+		}
+
+		stdoutWriter.Close()
+		stderrWriter.Close()
+		close(p.done)
+	}()
+
+	return p
+}
+
+func (p *fakeProcess) Stdin() io.Writer {
+	return writerFunc(func(b []byte) (int, error) {
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		return p.stdin.Write(b)
+	})
+}
+
+func (p *fakeProcess) Stdout() io.Reader { return p.stdout }
+func (p *fakeProcess) Stderr() io.Reader { return p.stderr }
+
+func (p *fakeProcess) Signal(sig os.Signal) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.refuseSignals {
+		return &os.SyscallError{Syscall: "kill", Err: syscall.EPERM}
+	}
+
+	select {
+	case <-p.done:
+		return os.ErrProcessDone
+	default:
+	}
+
+	if len(p.signals) == 0 {
+		close(p.signalled)
+	}
+	p.signals = append(p.signals, sig)
+	return nil
+}
+
+func (p *fakeProcess) Pid() int { return fakePid }
+
+func (p *fakeProcess) Wait() error {
+	<-p.done
+	return p.err
+}
+
+func (p *fakeProcess) Signals() []os.Signal {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]os.Signal(nil), p.signals...)
+}
+
+// Input is everything written to the process, such as prompt answers.
+func (p *fakeProcess) Input() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.stdin.String()
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) { return f(b) }
+
+// recorder stands in for the Bubble Tea program, keeping every message
+// a command sends.
+type recorder struct {
+	mutex sync.Mutex
+	msgs  []tea.Msg
+	done  chan CommandDoneMsg
+}
+
+func (r *recorder) Send(msg tea.Msg) {
+	r.mutex.Lock()
+	r.msgs = append(r.msgs, msg)
+	r.mutex.Unlock()
+
+	if done, ok := msg.(CommandDoneMsg); ok {
+		r.done <- done
+	}
+}
+
+func (r *recorder) Messages() []tea.Msg {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]tea.Msg(nil), r.msgs...)
+}
+
+// WaitDone returns the CommandDoneMsg sent once a command finishes.
+func (r *recorder) WaitDone(t *testing.T) CommandDoneMsg {
+	t.Helper()
+
+	select {
+	case done := <-r.done:
+		return done
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the command to finish")
+		return CommandDoneMsg{}
+	}
+}
+
+// useRecorder sends the messages of commands run by the test to a new
+// recorder.
+func useRecorder(t *testing.T) *recorder {
+	t.Helper()
+
+	r := &recorder{done: make(chan CommandDoneMsg, 16)}
+
+	previous := Program
+	Program = r
+	t.Cleanup(func() { Program = previous })
+
+	return r
+}
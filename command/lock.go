@@ -0,0 +1,269 @@
+package command
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultLockPath = "/var/lib/pacman/db.lck"
+
+var (
+	ErrLockTimeout  = errors.New("timed out waiting for package database lock")
+	ErrStaleLock    = errors.New("package database lock is stale")
+	ErrLockNotStale = errors.New("package database lock is not stale")
+)
+
+type LockState uint8
+
+const (
+	LockFree LockState = iota
+	LockHeld
+	LockStale
+)
+
+// LockStatus describes the package database lock at a point in time.
+// Holders lists the processes known to be keeping the lock, which may
+// be empty even when held if /proc can't be fully inspected.
+type LockStatus struct {
+	State   LockState
+	Holders []int
+}
+
+// LockManager understands libalpm's locking scheme. A transaction
+// creates the lock file exclusively, keeps it open for its duration and
+// unlinks it once finished. The file doesn't contain a PID, so holders
+// are found by looking for processes with it open. A lock file which
+// nobody holds was left behind by a transaction that crashed, but that
+// can only be known when every process could be inspected.
+//
+// pacman takes the lock itself, so the manager never creates the file;
+// it only waits until a new transaction would be able to.
+type LockManager struct {
+	Path         string
+	Timeout      time.Duration
+	PollInterval time.Duration
+
+	// A lock file younger than this is never considered stale, as its
+	// owner may not have been scheduled since creating it.
+	GracePeriod time.Duration
+
+	// Where processes are looked up, which is only changed by tests.
+	ProcDir string
+}
+
+var Lock = NewLockManager(DefaultLockPath)
+
+func NewLockManager(path string) *LockManager {
+	return &LockManager{
+		Path:         path,
+		Timeout:      2 * time.Minute,
+		PollInterval: 500 * time.Millisecond,
+		GracePeriod:  2 * time.Second,
+		ProcDir:      "/proc",
+	}
+}
+
+func (l *LockManager) Inspect() LockStatus {
+	info, err := os.Stat(l.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return LockStatus{State: LockFree}
+	}
+
+	holders, complete := findLockHolders(l.ProcDir, l.Path)
+	if len(holders) > 0 {
+		return LockStatus{State: LockHeld, Holders: holders}
+	}
+
+	// Any process that couldn't be inspected might be a frontend other
+	// than pacman, such as a package daemon, holding the lock. Removing
+	// the file from under it would corrupt its transaction.
+	if !complete {
+		return LockStatus{State: LockHeld}
+	}
+
+	if err == nil && time.Since(info.ModTime()) < l.GracePeriod {
+		return LockStatus{State: LockHeld}
+	}
+
+	// The transaction may have finished while /proc was being scanned.
+	if _, err := os.Stat(l.Path); errors.Is(err, os.ErrNotExist) {
+		return LockStatus{State: LockFree}
+	}
+
+	return LockStatus{State: LockStale}
+}
+
+// Wait blocks until the lock is free or cancel is closed, calling
+// onWait whenever the set of holders changes. A stale lock is reported
+// immediately rather than waited on, since it will never be released.
+func (l *LockManager) Wait(cancel <-chan struct{}, onWait func(LockStatus)) (LockStatus, error) {
+	deadline := time.Now().Add(l.Timeout)
+
+	var lastHolders []int
+	notified := false
+
+	for {
+		status := l.Inspect()
+		switch status.State {
+		case LockFree:
+			return status, nil
+		case LockStale:
+			return status, ErrStaleLock
+		}
+
+		if !notified || !slices.Equal(lastHolders, status.Holders) {
+			if onWait != nil {
+				onWait(status)
+			}
+			lastHolders = status.Holders
+			notified = true
+		}
+
+		if time.Now().After(deadline) {
+			return status, ErrLockTimeout
+		}
+
+		select {
+		case <-cancel:
+			return status, ErrCancelled
+		case <-time.After(l.PollInterval):
+		}
+	}
+}
+
+// ClearStale removes the lock file, but only after checking again that
+// nothing holds it.
+func (l *LockManager) ClearStale() error {
+	if l.Inspect().State != LockStale {
+		return ErrLockNotStale
+	}
+
+	return os.Remove(l.Path)
+}
+
+// findLockHolders scans procDir for processes with the lock file open.
+// Unprivileged users can't read the descriptors of root's processes, so
+// any running pacman transaction is also assumed to be a holder. Other
+// pacman processes, such as pTUI's own queries, never take the lock.
+// The scan is only complete if every process's descriptors were read.
+func findLockHolders(procDir string, lockPath string) (holders []int, complete bool) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil, false
+	}
+
+	complete = true
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+
+		dir := filepath.Join(procDir, entry.Name())
+		isOpen, inspected := hasFileOpen(dir, lockPath)
+		if !inspected {
+			complete = false
+		}
+
+		if isOpen || isPacmanTransaction(dir) {
+			holders = append(holders, pid)
+		}
+	}
+
+	return holders, complete
+}
+
+// hasFileOpen reports whether a process has path open, and whether its
+// descriptors could be read. Processes which exit during the scan
+// can't be holding anything.
+func hasFileOpen(procDir string, path string) (isOpen bool, inspected bool) {
+	fds, err := os.ReadDir(filepath.Join(procDir, "fd"))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, true
+	}
+	if err != nil {
+		return false, false
+	}
+
+	for _, fd := range fds {
+		target, err := os.Readlink(filepath.Join(procDir, "fd", fd.Name()))
+		if err == nil && target == path {
+			return true, true
+		}
+	}
+
+	return false, true
+}
+
+// isPacmanTransaction reports whether the process is pacman running an
+// operation which takes the lock, judged by its command line.
+func isPacmanTransaction(procDir string) bool {
+	comm, err := os.ReadFile(filepath.Join(procDir, "comm"))
+	if err != nil || strings.TrimSpace(string(comm)) != "pacman" {
+		return false
+	}
+
+	cmdline, err := os.ReadFile(filepath.Join(procDir, "cmdline"))
+	if err != nil {
+		return false
+	}
+
+	argv := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+	if len(argv) == 0 {
+		return false
+	}
+
+	return commandFromArgs(argv[1:]).isTransaction()
+}
+
+// Long options which stand for an operation, or one of the options
+// isTransaction looks at.
+var longOptions = map[string]string{
+	"--remove":  "R",
+	"--sync":    "S",
+	"--upgrade": "U",
+	"--refresh": "y",
+	"--search":  "s",
+	"--info":    "i",
+	"--list":    "l",
+	"--groups":  "g",
+}
+
+// commandFromArgs rebuilds a command from pacman's arguments, so that
+// it can be classified like the commands pTUI runs itself.
+func commandFromArgs(args []string) *Command {
+	c := NewCommand()
+
+	addFlags := func(flags string) {
+		for _, flag := range flags {
+			if c.operation == "" && flag >= 'A' && flag <= 'Z' {
+				c.operation = "-" + string(flag)
+			} else {
+				c.options = append(c.options, string(flag))
+			}
+		}
+	}
+
+	for _, arg := range args {
+		switch {
+		case arg == "--":
+			return c
+		case strings.HasPrefix(arg, "--"):
+			name, _, _ := strings.Cut(arg, "=")
+			if flag, exists := longOptions[name]; exists {
+				addFlags(flag)
+			} else {
+				c.args = append(c.args, arg)
+			}
+		case strings.HasPrefix(arg, "-"):
+			addFlags(arg[1:])
+		}
+	}
+
+	return c
+}
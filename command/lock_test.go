@@ -0,0 +1,303 @@
+package command
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProc builds a directory laid out like /proc, holding only the
+// processes a test adds to it.
+type fakeProc struct {
+	t   *testing.T
+	dir string
+}
+
+func newFakeProc(t *testing.T) *fakeProc {
+	return &fakeProc{t: t, dir: t.TempDir()}
+}
+
+// add creates a process with the given command line, with descriptors
+// pointing at openFiles.
+func (p *fakeProc) add(pid int, argv []string, openFiles ...string) string {
+	p.t.Helper()
+
+	dir := filepath.Join(p.dir, strconv.Itoa(pid))
+	if err := os.MkdirAll(filepath.Join(dir, "fd"), 0o755); err != nil {
+		p.t.Fatal(err)
+	}
+
+	comm := filepath.Base(argv[0]) + "\n"
+	cmdline := strings.Join(argv, "\x00") + "\x00"
+	if err := os.WriteFile(filepath.Join(dir, "comm"), []byte(comm), 0o644); err != nil {
+		p.t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0o644); err != nil {
+		p.t.Fatal(err)
+	}
+
+	for i, file := range openFiles {
+		if err := os.Symlink(file, filepath.Join(dir, "fd", strconv.Itoa(i))); err != nil {
+			p.t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// hide makes a process's descriptors unreadable, as they are for
+// another user's processes. Tests may run as root, which permissions
+// don't stop, so the fd directory is replaced by a file.
+func (p *fakeProc) hide(pid int) {
+	p.t.Helper()
+
+	fd := filepath.Join(p.dir, strconv.Itoa(pid), "fd")
+	if err := os.RemoveAll(fd); err != nil {
+		p.t.Fatal(err)
+	}
+	if err := os.WriteFile(fd, nil, 0o644); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+// newTestLock returns a lock manager whose lock file, if created, is
+// older than its grace period.
+func newTestLock(t *testing.T, proc *fakeProc, create bool) *LockManager {
+	t.Helper()
+
+	l := NewLockManager(filepath.Join(t.TempDir(), "db.lck"))
+	l.ProcDir = proc.dir
+	l.Timeout = 50 * time.Millisecond
+	l.PollInterval = time.Millisecond
+	l.GracePeriod = time.Minute
+
+	if create {
+		if err := os.WriteFile(l.Path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		old := time.Now().Add(-time.Hour)
+		if err := os.Chtimes(l.Path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return l
+}
+
+func TestInspect(t *testing.T) {
+	t.Run("free", func(t *testing.T) {
+		l := newTestLock(t, newFakeProc(t), false)
+		if status := l.Inspect(); status.State != LockFree {
+			t.Errorf("state = %v, want free", status.State)
+		}
+	})
+
+	t.Run("held by open descriptor", func(t *testing.T) {
+		proc := newFakeProc(t)
+		l := newTestLock(t, proc, true)
+		proc.add(100, []string{"/usr/bin/pamac-daemon"}, "/dev/null", l.Path)
+		proc.add(101, []string{"bash"}, "/dev/null")
+
+		status := l.Inspect()
+		if status.State != LockHeld || !slices.Equal(status.Holders, []int{100}) {
+			t.Errorf("status = %+v, want held by 100", status)
+		}
+	})
+
+	t.Run("held by pacman transaction", func(t *testing.T) {
+		proc := newFakeProc(t)
+		l := newTestLock(t, proc, true)
+		proc.add(200, []string{"pacman", "-Syu"})
+		proc.add(201, []string{"pacman", "-Qi", "vim"})
+
+		status := l.Inspect()
+		if status.State != LockHeld || !slices.Equal(status.Holders, []int{200}) {
+			t.Errorf("status = %+v, want held by 200 only", status)
+		}
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		proc := newFakeProc(t)
+		l := newTestLock(t, proc, true)
+		proc.add(300, []string{"pacman", "-Ss", "vim"})
+
+		if status := l.Inspect(); status.State != LockStale {
+			t.Errorf("status = %+v, want stale", status)
+		}
+	})
+
+	// Within the grace period, the lock's owner may not have opened it
+	// yet.
+	t.Run("grace period", func(t *testing.T) {
+		proc := newFakeProc(t)
+		l := newTestLock(t, proc, true)
+		if err := os.Chtimes(l.Path, time.Now(), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+
+		if status := l.Inspect(); status.State != LockHeld || len(status.Holders) != 0 {
+			t.Errorf("status = %+v, want held without holders", status)
+		}
+	})
+
+	// A process whose descriptors can't be read might be holding the
+	// lock, so it's never reported stale.
+	t.Run("uninspectable process", func(t *testing.T) {
+		proc := newFakeProc(t)
+		l := newTestLock(t, proc, true)
+		proc.add(400, []string{"/usr/lib/packagekitd"})
+		proc.hide(400)
+
+		if status := l.Inspect(); status.State != LockHeld {
+			t.Errorf("status = %+v, want held", status)
+		}
+		if err := l.ClearStale(); !errors.Is(err, ErrLockNotStale) {
+			t.Errorf("ClearStale = %v, want ErrLockNotStale", err)
+		}
+		if _, err := os.Stat(l.Path); err != nil {
+			t.Errorf("lock file was removed: %v", err)
+		}
+	})
+
+	t.Run("unreadable proc", func(t *testing.T) {
+		proc := newFakeProc(t)
+		l := newTestLock(t, proc, true)
+		l.ProcDir = filepath.Join(proc.dir, "missing")
+
+		if status := l.Inspect(); status.State != LockHeld {
+			t.Errorf("status = %+v, want held", status)
+		}
+	})
+}
+
+func TestWaitTimeout(t *testing.T) {
+	proc := newFakeProc(t)
+	l := newTestLock(t, proc, true)
+	proc.add(500, []string{"pacman", "-S", "vim"})
+
+	var notified []LockStatus
+	status, err := l.Wait(nil, func(status LockStatus) { notified = append(notified, status) })
+	if !errors.Is(err, ErrLockTimeout) || status.State != LockHeld {
+		t.Errorf("Wait = %+v, %v, want ErrLockTimeout", status, err)
+	}
+
+	// The holders didn't change, so they're only reported once.
+	if len(notified) != 1 || !slices.Equal(notified[0].Holders, []int{500}) {
+		t.Errorf("notified %+v, want holder 500 once", notified)
+	}
+}
+
+func TestWaitStale(t *testing.T) {
+	l := newTestLock(t, newFakeProc(t), true)
+
+	if _, err := l.Wait(nil, nil); !errors.Is(err, ErrStaleLock) {
+		t.Errorf("err = %v, want ErrStaleLock", err)
+	}
+	if err := l.ClearStale(); err != nil {
+		t.Fatalf("ClearStale: %v", err)
+	}
+	if _, err := l.Wait(nil, nil); err != nil {
+		t.Errorf("err = %v after clearing, want nil", err)
+	}
+}
+
+func TestWaitReleased(t *testing.T) {
+	proc := newFakeProc(t)
+	l := newTestLock(t, proc, true)
+	l.Timeout = 5 * time.Second
+	proc.add(600, []string{"pacman", "-R", "vim"})
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		os.Remove(l.Path)
+	}()
+
+	if status, err := l.Wait(nil, nil); err != nil || status.State != LockFree {
+		t.Errorf("Wait = %+v, %v, want free", status, err)
+	}
+}
+
+func TestCommandFromArgs(t *testing.T) {
+	tests := []struct {
+		args          []string
+		isTransaction bool
+	}{
+		{[]string{"-Qi", "vim"}, false},
+		{[]string{"-Ss", "vim"}, false},
+		{[]string{"-Si", "vim"}, false},
+		{[]string{"-Sl"}, false},
+		{[]string{"-Sg", "gnome"}, false},
+		{[]string{"-S", "vim"}, true},
+		{[]string{"-Syu"}, true},
+		{[]string{"-Sy", "--print"}, true},
+		{[]string{"-S", "--print", "vim"}, false},
+		{[]string{"-Sp", "vim"}, false},
+		{[]string{"-R", "-s", "vim"}, true},
+		{[]string{"-Rsp", "vim"}, false},
+		{[]string{"--noconfirm", "-U", "vim-9.1-1-x86_64.pkg.tar.zst"}, true},
+		{[]string{"--sync", "--refresh", "--sysupgrade"}, true},
+		{[]string{"--sync", "--search", "vim"}, false},
+		{[]string{"--remove", "vim"}, true},
+		{[]string{"--upgrade", "--print", "vim.pkg.tar.zst"}, false},
+		{[]string{"--query", "--info", "vim"}, false},
+		{[]string{"--config=/etc/pacman.conf", "-S", "vim"}, true},
+		{[]string{"-S", "--", "-Rs"}, true},
+		// checkupdates refreshes a copy of the databases.
+		{[]string{"-Sy", "--dbpath", "/tmp/checkup-db-1000", "--logfile", "/dev/null"}, false},
+		{[]string{"-Syu", "--dbpath=/tmp/checkup-db-1000"}, false},
+		{[]string{"-Q", "--", "-S"}, false},
+		{nil, false},
+	}
+
+	for _, test := range tests {
+		if got := commandFromArgs(test.args).isTransaction(); got != test.isTransaction {
+			t.Errorf("commandFromArgs(%q).isTransaction() = %v, want %v", test.args, got, test.isTransaction)
+		}
+	}
+}
+
+func TestCommandFromArgsOperation(t *testing.T) {
+	c := commandFromArgs([]string{"--sync", "-u", "--refresh", "--needed", "vim"})
+	if c.operation != "-S" || !slices.Equal(c.options, []string{"u", "y"}) || !slices.Equal(c.args, []string{"--needed"}) {
+		t.Errorf("command = %q %q %q", c.operation, c.options, c.args)
+	}
+
+	for long, flag := range longOptions {
+		if len(flag) != 1 {
+			t.Errorf("%s stands for %q, want a single flag", long, flag)
+		}
+	}
+}
+
+func TestIsPacmanTransaction(t *testing.T) {
+	proc := newFakeProc(t)
+
+	tests := []struct {
+		argv []string
+		want bool
+	}{
+		{[]string{"pacman", "-Syu", "--noconfirm"}, true},
+		{[]string{"/usr/bin/pacman", "-Rns", "vim"}, true},
+		{[]string{"pacman", "-Qi", "vim"}, false},
+		{[]string{"pacman", "-Ss", "vim"}, false},
+		{[]string{"pacman"}, false},
+		{[]string{"yay", "-Syu"}, false},
+		{[]string{"pamac-daemon"}, false},
+	}
+
+	for i, test := range tests {
+		dir := proc.add(1000+i, test.argv)
+		if got := isPacmanTransaction(dir); got != test.want {
+			t.Errorf("isPacmanTransaction(%q) = %v, want %v", test.argv, got, test.want)
+		}
+	}
+
+	if isPacmanTransaction(filepath.Join(proc.dir, "999")) {
+		t.Error("a process which has exited is a transaction")
+	}
+}
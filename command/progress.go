@@ -0,0 +1,180 @@
+package command
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"ptui/types"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type ProgressKind uint8
+
+const (
+	DownloadProgress ProgressKind = iota
+	TransactionProgress
+)
+
+// ProgressMsg is sent as pacman redraws one of its progress bars. They
+// are only drawn when pacman runs in a terminal.
+type ProgressMsg struct {
+	CommandId int
+	Target    types.StreamTarget
+	Kind      ProgressKind
+
+	// For downloads, the file being fetched. Otherwise the step being
+	// run, such as "installing firefox".
+	Item    string
+	Percent float64
+
+	// The position of a transaction step, as in "(2/5)", or of the
+	// whole download when IsTotal is set.
+	Current int
+	Total   int
+	IsTotal bool
+
+	// Download statistics, in bytes and bytes per second.
+	Transferred int64
+	Rate        int64
+	ETA         string
+}
+
+// HookMsg is sent as pacman runs each of the pre- or post-transaction
+// hooks.
+type HookMsg struct {
+	CommandId int
+	Target    types.StreamTarget
+	Stage     string
+	Name      string
+	Current   int
+	Total     int
+}
+
+var (
+	// " firefox-130.0-1-x86_64   68.2 MiB  10.4 MiB/s 00:07 [#####-----]  50%"
+	//
+	// Units are padded to three characters, so bytes are "0.0   B", and
+	// long downloads show hours in their ETA.
+	downloadPattern = regexp.MustCompile(
+		`^\s*(.+?)\s+(\d+(?:\.\d+)?)\s*([KMGT]?i?B)\s+(\d+(?:\.\d+)?)\s*([KMGT]?i?B)/s\s+(\d+:\d+(?::\d+)?|--:--)\s+\[[^\]]*\]\s+(\d+)%\s*$`)
+
+	// "Total (1/3)"
+	downloadTotalPattern = regexp.MustCompile(`^Total \((\d+)/(\d+)\)$`)
+
+	// "(2/5) installing firefox                [#####-----]  50%"
+	transactionPattern = regexp.MustCompile(`^\s*\((\d+)/(\d+)\) (.+?)\s+\[[^\]]*\]\s+(\d+)%\s*$`)
+
+	// ":: Running post-transaction hooks..."
+	hookStagePattern = regexp.MustCompile(`^:: Running (pre|post)-transaction hooks\.\.\.$`)
+	hookPattern      = regexp.MustCompile(`^\((\d+)/(\d+)\) (.+)$`)
+
+	// Printed between the pre-transaction hooks and the first step.
+	processingPattern = regexp.MustCompile(`^:: Processing package changes\.\.\.$`)
+)
+
+var sizeUnits = map[string]int64{
+	"B":   1,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"TiB": 1 << 40,
+}
+
+// progressParser picks progress out of one output stream. Hooks are
+// numbered like transaction steps, so it has to remember whether the
+// hooks have started.
+type progressParser struct {
+	hookStage string
+	lastKept  string
+}
+
+// parse returns a message describing line, if it's progress, and
+// whether the line should still be passed on as output. Progress bars
+// are redrawn many times, so only the finished bar is kept.
+func (p *progressParser) parse(id int, target types.StreamTarget, line string) (tea.Msg, bool) {
+	if match := hookStagePattern.FindStringSubmatch(line); match != nil {
+		p.hookStage = match[1] + "-transaction"
+		return nil, true
+	}
+
+	if processingPattern.MatchString(line) {
+		p.hookStage = ""
+		return nil, true
+	}
+
+	if match := transactionPattern.FindStringSubmatch(line); match != nil {
+		current, _ := strconv.Atoi(match[1])
+		total, _ := strconv.Atoi(match[2])
+		percent, _ := strconv.Atoi(match[4])
+
+		return ProgressMsg{
+			CommandId: id,
+			Target:    target,
+			Kind:      TransactionProgress,
+			Item:      match[3],
+			Percent:   float64(percent) / 100,
+			Current:   current,
+			Total:     total,
+		}, p.keep(line, percent)
+	}
+
+	if match := downloadPattern.FindStringSubmatch(line); match != nil {
+		percent, _ := strconv.Atoi(match[7])
+		msg := ProgressMsg{
+			CommandId:   id,
+			Target:      target,
+			Kind:        DownloadProgress,
+			Item:        match[1],
+			Percent:     float64(percent) / 100,
+			Transferred: parseSize(match[2], match[3]),
+			Rate:        parseSize(match[4], match[5]),
+			ETA:         match[6],
+		}
+
+		if total := downloadTotalPattern.FindStringSubmatch(msg.Item); total != nil {
+			msg.Current, _ = strconv.Atoi(total[1])
+			msg.Total, _ = strconv.Atoi(total[2])
+			msg.IsTotal = true
+		}
+
+		return msg, p.keep(line, percent)
+	}
+
+	if p.hookStage != "" {
+		if match := hookPattern.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			current, _ := strconv.Atoi(match[1])
+			total, _ := strconv.Atoi(match[2])
+
+			return HookMsg{
+				CommandId: id,
+				Target:    target,
+				Stage:     p.hookStage,
+				Name:      strings.TrimSuffix(match[3], "..."),
+				Current:   current,
+				Total:     total,
+			}, true
+		}
+	}
+
+	return nil, true
+}
+
+func (p *progressParser) keep(line string, percent int) bool {
+	if percent < 100 || line == p.lastKept {
+		return false
+	}
+
+	p.lastKept = line
+	return true
+}
+
+func parseSize(value string, unit string) int64 {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+
+	return int64(number * float64(sizeUnits[unit]))
+}
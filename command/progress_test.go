@@ -0,0 +1,208 @@
+package command
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Lines are as pacman 6 draws them in an 80 column terminal, after
+// escapes have been stripped.
+func TestParseDownloadProgress(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want ProgressMsg
+	}{
+		{
+			"package",
+			" firefox-130.0-1-x86_64       68.2 MiB  10.4 MiB/s 00:07 [###########-----------]  50%",
+			ProgressMsg{Item: "firefox-130.0-1-x86_64", Percent: 0.5, Transferred: 71512883, Rate: 10905190, ETA: "00:07"},
+		},
+		{
+			"database",
+			" core                       130.6 KiB   652 KiB/s 00:00 [######################] 100%",
+			ProgressMsg{Item: "core", Percent: 1, Transferred: 133734, Rate: 667648, ETA: "00:00"},
+		},
+		{
+			// Units are padded to three characters.
+			"bytes",
+			" extra-downloads              0.0   B  0.00   B/s --:-- [----------------------]   0%",
+			ProgressMsg{Item: "extra-downloads", ETA: "--:--"},
+		},
+		{
+			"gibibytes with hours left",
+			" texlive-fontsextra-2024...    1.2 GiB   512 KiB/s 01:02:03 [##------------------]   9%",
+			ProgressMsg{Item: "texlive-fontsextra-2024...", Percent: 0.09, Transferred: 1288490188, Rate: 524288, ETA: "01:02:03"},
+		},
+		{
+			"total",
+			" Total (2/3)                 68.3 MiB  10.4 MiB/s 00:07 [###########-----------]  50%",
+			ProgressMsg{
+				Item: "Total (2/3)", Percent: 0.5, Transferred: 71617740, Rate: 10905190, ETA: "00:07",
+				Current: 2, Total: 3, IsTotal: true,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var p progressParser
+			msg, _ := p.parse(1, testTarget, test.line)
+
+			progress, ok := msg.(ProgressMsg)
+			if !ok {
+				t.Fatalf("parse returned %#v, want a ProgressMsg", msg)
+			}
+
+			want := test.want
+			want.CommandId, want.Target, want.Kind = 1, testTarget, DownloadProgress
+			if progress != want {
+				t.Errorf("parse = %+v\nwant    %+v", progress, want)
+			}
+		})
+	}
+}
+
+func TestParseTransactionProgress(t *testing.T) {
+	tests := []struct {
+		line string
+		want ProgressMsg
+	}{
+		{
+			"(1/2) upgrading linux-firmware                     [######################] 100%",
+			ProgressMsg{Item: "upgrading linux-firmware", Percent: 1, Current: 1, Total: 2},
+		},
+		{
+			"(12/40) installing python-pytest-asyncio-0.23.5... [##########------------]  45%",
+			ProgressMsg{Item: "installing python-pytest-asyncio-0.23.5...", Percent: 0.45, Current: 12, Total: 40},
+		},
+		{
+			"(1/1) checking keys in keyring                     [----------------------]   0%",
+			ProgressMsg{Item: "checking keys in keyring", Current: 1, Total: 1},
+		},
+	}
+
+	for _, test := range tests {
+		var p progressParser
+		msg, _ := p.parse(1, testTarget, test.line)
+
+		want := test.want
+		want.CommandId, want.Target, want.Kind = 1, testTarget, TransactionProgress
+		if msg != want {
+			t.Errorf("parse(%q) = %+v\nwant %+v", test.line, msg, want)
+		}
+	}
+}
+
+// Hooks are numbered like steps, but only have a bar while they're
+// steps, so the parser remembers which it's looking at.
+func TestParseHooks(t *testing.T) {
+	lines := []struct {
+		line string
+		want tea.Msg
+	}{
+		{":: Running pre-transaction hooks...", nil},
+		{"(1/1) Removing linux initcpios...", HookMsg{Stage: "pre-transaction", Name: "Removing linux initcpios", Current: 1, Total: 1}},
+		{":: Processing package changes...", nil},
+		{"(1/1) removing linux", nil},
+		{"(1/1) removing linux                               [######################] 100%", ProgressMsg{
+			Kind: TransactionProgress, Item: "removing linux", Percent: 1, Current: 1, Total: 1,
+		}},
+		{":: Running post-transaction hooks...", nil},
+		{"(1/2) Arming ConditionNeedsUpdate...", HookMsg{Stage: "post-transaction", Name: "Arming ConditionNeedsUpdate", Current: 1, Total: 2}},
+		// A bar makes a step even while hooks are running.
+		{"(1/1) upgrading mkinitcpio                         [######################] 100%", ProgressMsg{
+			Kind: TransactionProgress, Item: "upgrading mkinitcpio", Percent: 1, Current: 1, Total: 1,
+		}},
+		{"(2/2) Updating module dependencies...", HookMsg{Stage: "post-transaction", Name: "Updating module dependencies", Current: 2, Total: 2}},
+		{"  -> Running build hook: [base]", nil},
+	}
+
+	var p progressParser
+	for _, line := range lines {
+		msg, keep := p.parse(1, testTarget, line.line)
+		if !keep {
+			t.Errorf("parse(%q) dropped the line", line.line)
+		}
+
+		switch want := line.want.(type) {
+		case HookMsg:
+			want.CommandId, want.Target = 1, testTarget
+			line.want = want
+		case ProgressMsg:
+			want.CommandId, want.Target = 1, testTarget
+			line.want = want
+		}
+		if msg != line.want {
+			t.Errorf("parse(%q) = %#v\nwant %#v", line.line, msg, line.want)
+		}
+	}
+}
+
+// Only a finished bar is passed on as output, and only once however
+// often it's redrawn.
+func TestParseKeep(t *testing.T) {
+	lines := []struct {
+		line string
+		keep bool
+	}{
+		{" core                       130.6 KiB   652 KiB/s 00:00 [###########-----------]  50%", false},
+		{" core                       130.6 KiB   652 KiB/s 00:00 [######################] 100%", true},
+		{" core                       130.6 KiB   652 KiB/s 00:00 [######################] 100%", false},
+		{"(1/1) upgrading vim                                [#####-----------------]  25%", false},
+		{"(1/1) upgrading vim                                [######################] 100%", true},
+		{"(1/1) upgrading vim                                [######################] 100%", false},
+		{"warning: /etc/vimrc installed as /etc/vimrc.pacnew", true},
+		{"", true},
+	}
+
+	var p progressParser
+	for _, line := range lines {
+		if _, keep := p.parse(1, testTarget, line.line); keep != line.keep {
+			t.Errorf("parse(%q) keep = %v, want %v", line.line, keep, line.keep)
+		}
+	}
+}
+
+func TestParseNotProgress(t *testing.T) {
+	lines := []string{
+		"resolving dependencies...",
+		"looking for conflicting packages...",
+		"Packages (1) vim-9.1.0-1",
+		"Total Download Size:   1.85 MiB",
+		":: Proceed with installation? [Y/n]",
+		// Without any hook stage, a numbered line isn't a hook.
+		"(1/1) Arming ConditionNeedsUpdate...",
+	}
+
+	var p progressParser
+	for _, line := range lines {
+		if msg, keep := p.parse(1, testTarget, line); msg != nil || !keep {
+			t.Errorf("parse(%q) = %#v, %v, want plain output", line, msg, keep)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value string
+		unit  string
+		want  int64
+	}{
+		{"0.0", "B", 0},
+		{"512", "B", 512},
+		{"1.5", "KiB", 1536},
+		{"2.0", "MiB", 2 << 20},
+		{"1.0", "GiB", 1 << 30},
+		{"1.0", "TiB", 1 << 40},
+		{"1.0", "XB", 0},
+		{"many", "MiB", 0},
+	}
+
+	for _, test := range tests {
+		if got := parseSize(test.value, test.unit); got != test.want {
+			t.Errorf("parseSize(%q, %q) = %d, want %d", test.value, test.unit, got, test.want)
+		}
+	}
+}
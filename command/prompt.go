@@ -0,0 +1,101 @@
+package command
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PromptChoice is one of the fixed answers to a prompt.
+type PromptChoice struct {
+	Answer string
+	Label  string
+}
+
+// pacman's questions as printed to a terminal, e.g.
+//
+//	:: Proceed with installation? [Y/n]
+//	Enter a number (default=1):
+//	Enter a selection (default=all):
+var (
+	yesNoPattern     = regexp.MustCompile(`^(.*?)\s*\[(Y/n|y/N)\]\s*$`)
+	numberPattern    = regexp.MustCompile(`^Enter a number \(default=(\d+)\):\s*$`)
+	selectionPattern = regexp.MustCompile(`^Enter a selection \(default=([^)]*)\):\s*$`)
+
+	// Providers are listed as "   1) foo  2) bar  3) baz".
+	numberedChoicePattern = regexp.MustCompile(`(\d+)\) (\S+)`)
+
+	escapePattern = regexp.MustCompile(`\x1b(\[[0-9;?]*[ -/]*[@-~]|\][^\x07]*\x07|[()][0-9A-Za-z])`)
+)
+
+// Beyond this many providers there aren't enough number keys, so the
+// answer is typed instead.
+const maxPromptChoices = 9
+
+// parsePrompt recognises a line which asks for input. Context is the
+// output printed since the previous prompt, which is where pacman
+// lists the options.
+func parsePrompt(line string, context []string) (PromptMsg, bool) {
+	if isPasswordPrompt(line) {
+		return PromptMsg{Text: strings.TrimPrefix(line, sudoPromptPrefix), Secret: true}, true
+	}
+
+	trimmed := strings.TrimSpace(line)
+
+	// Helpers other than sudo can only be given a password through
+	// the terminal, where they print their own prompt.
+	if strings.Contains(strings.ToLower(trimmed), "password") && strings.HasSuffix(trimmed, ":") {
+		return PromptMsg{Text: trimmed, Secret: true}, true
+	}
+
+	if match := yesNoPattern.FindStringSubmatch(trimmed); match != nil {
+		yes := PromptChoice{Answer: "y", Label: "Yes"}
+		no := PromptChoice{Answer: "n", Label: "No"}
+
+		msg := PromptMsg{Text: strings.TrimSpace(strings.TrimPrefix(match[1], "::")), Context: context}
+		if match[2] == "Y/n" {
+			msg.Default, msg.Choices = "y", []PromptChoice{yes, no}
+		} else {
+			msg.Default, msg.Choices = "n", []PromptChoice{no, yes}
+		}
+
+		return msg, true
+	}
+
+	if match := numberPattern.FindStringSubmatch(trimmed); match != nil {
+		msg := PromptMsg{Text: trimmed, Context: context, Default: match[1]}
+
+		var choices []PromptChoice
+		for _, line := range context {
+			for _, choice := range numberedChoicePattern.FindAllStringSubmatch(line, -1) {
+				choices = append(choices, PromptChoice{Answer: choice[1], Label: choice[2]})
+			}
+		}
+
+		if len(choices) <= maxPromptChoices {
+			msg.Choices = choices
+		}
+
+		return msg, true
+	}
+
+	if match := selectionPattern.FindStringSubmatch(trimmed); match != nil {
+		return PromptMsg{Text: trimmed, Context: context, Default: match[1]}, true
+	}
+
+	return PromptMsg{}, false
+}
+
+func isPrompt(line string) bool {
+	_, ok := parsePrompt(line, nil)
+	return ok
+}
+
+// stripEscapes removes the colours and cursor movement pacman emits
+// when it's attached to a terminal.
+func stripEscapes(line string) string {
+	if !strings.Contains(line, "\x1b") {
+		return line
+	}
+
+	return escapePattern.ReplaceAllString(line, "")
+}
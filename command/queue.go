@@ -0,0 +1,230 @@
+package command
+
+import (
+	"errors"
+	"slices"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type JobStatus uint8
+
+const (
+	JobPending JobStatus = iota
+	JobRunning
+	JobFailed
+	JobDone
+)
+
+func (s JobStatus) String() string {
+	switch s {
+	case JobRunning:
+		return "running"
+	case JobFailed:
+		return "failed"
+	case JobDone:
+		return "done"
+	default:
+		return "pending"
+	}
+}
+
+var (
+	ErrNoSuchJob     = errors.New("no such job")
+	ErrJobNotPending = errors.New("only pending jobs can be changed")
+	ErrJobNotFailed  = errors.New("only failed jobs can be retried")
+)
+
+// Job is a command waiting in, or run from, the queue.
+type Job struct {
+	Id          int
+	Description string
+	Status      JobStatus
+	Err         error
+
+	// The id of the command's messages once it's running.
+	CommandId int
+
+	command *Command
+}
+
+// Queue runs transactions one after another, in an order the user can
+// change while they wait. Queries don't go through it.
+type Queue struct {
+	mutex     sync.Mutex
+	jobs      []*Job
+	nextJobId int
+	running   *Job
+}
+
+var Jobs = &Queue{}
+
+// Enqueue adds c to the end of the queue, and starts it if nothing
+// else is running.
+func (q *Queue) Enqueue(description string, c *Command) tea.Cmd {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.nextJobId++
+	q.jobs = append(q.jobs, &Job{Id: q.nextJobId, Description: description, command: c})
+
+	return q.next()
+}
+
+// Finished records how a job's command ended and starts the next one.
+// Every CommandDoneMsg should be passed here.
+func (q *Queue) Finished(msg CommandDoneMsg) tea.Cmd {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.running == nil || q.running.CommandId != msg.CommandId {
+		return nil
+	}
+
+	q.running.Err = msg.Err
+	if msg.Err != nil || msg.Interrupted {
+		q.running.Status = JobFailed
+	} else {
+		q.running.Status = JobDone
+	}
+	q.running = nil
+
+	return q.next()
+}
+
+func (q *Queue) next() tea.Cmd {
+	if q.running != nil {
+		return nil
+	}
+
+	for _, job := range q.jobs {
+		if job.Status != JobPending {
+			continue
+		}
+
+		var run tea.Cmd
+		job.CommandId, run = job.command.start()
+		job.Status = JobRunning
+		job.Err = nil
+		q.running = job
+
+		return run
+	}
+
+	return nil
+}
+
+// Jobs returns a copy of every job, in queue order.
+func (q *Queue) Jobs() []Job {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	jobs := make([]Job, len(q.jobs))
+	for i, job := range q.jobs {
+		jobs[i] = *job
+	}
+	return jobs
+}
+
+func (q *Queue) Pending() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	count := 0
+	for _, job := range q.jobs {
+		if job.Status == JobPending {
+			count++
+		}
+	}
+	return count
+}
+
+// Move shifts a pending job by offset places, skipping over jobs that
+// have already run.
+func (q *Queue) Move(id int, offset int) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	i := q.index(id)
+	if i < 0 {
+		return ErrNoSuchJob
+	}
+	if q.jobs[i].Status != JobPending {
+		return ErrJobNotPending
+	}
+
+	step := 1
+	if offset < 0 {
+		step, offset = -1, -offset
+	}
+
+	for ; offset > 0; offset-- {
+		j := i + step
+		for j >= 0 && j < len(q.jobs) && q.jobs[j].Status != JobPending {
+			j += step
+		}
+		if j < 0 || j >= len(q.jobs) {
+			break
+		}
+
+		q.jobs[i], q.jobs[j] = q.jobs[j], q.jobs[i]
+		i = j
+	}
+
+	return nil
+}
+
+// Drop removes a pending job.
+func (q *Queue) Drop(id int) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	i := q.index(id)
+	if i < 0 {
+		return ErrNoSuchJob
+	}
+	if q.jobs[i].Status != JobPending {
+		return ErrJobNotPending
+	}
+
+	q.jobs = slices.Delete(q.jobs, i, i+1)
+	return nil
+}
+
+// Retry puts a failed job back at the end of the queue.
+func (q *Queue) Retry(id int) (tea.Cmd, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	i := q.index(id)
+	if i < 0 {
+		return nil, ErrNoSuchJob
+	}
+
+	job := q.jobs[i]
+	if job.Status != JobFailed {
+		return nil, ErrJobNotFailed
+	}
+
+	job.Status = JobPending
+	q.jobs = append(slices.Delete(q.jobs, i, i+1), job)
+
+	return q.next(), nil
+}
+
+// ClearFinished forgets jobs which have completed successfully.
+func (q *Queue) ClearFinished() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.jobs = slices.DeleteFunc(q.jobs, func(job *Job) bool {
+		return job.Status == JobDone
+	})
+}
+
+func (q *Queue) index(id int) int {
+	return slices.IndexFunc(q.jobs, func(job *Job) bool {
+		return job.Id == id
+	})
+}
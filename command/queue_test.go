@@ -0,0 +1,229 @@
+package command
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// enqueue adds an install of each package to q, returning the job ids.
+// The commands are never run, so no executor is needed.
+func enqueue(q *Queue, names ...string) []int {
+	var ids []int
+	for _, name := range names {
+		q.Enqueue("install "+name, NewCommand().Operation("S").Arguments(name))
+		ids = append(ids, q.Jobs()[len(q.Jobs())-1].Id)
+	}
+	return ids
+}
+
+// state describes the queue as "description:status" in order.
+func state(q *Queue) []string {
+	var jobs []string
+	for _, job := range q.Jobs() {
+		jobs = append(jobs, job.Description[len("install "):]+":"+job.Status.String())
+	}
+	return jobs
+}
+
+func checkState(t *testing.T, q *Queue, want ...string) {
+	t.Helper()
+	if got := state(q); !slices.Equal(got, want) {
+		t.Errorf("queue = %v, want %v", got, want)
+	}
+}
+
+func job(q *Queue, id int) Job {
+	for _, job := range q.Jobs() {
+		if job.Id == id {
+			return job
+		}
+	}
+	return Job{}
+}
+
+func TestQueueEnqueue(t *testing.T) {
+	q := &Queue{}
+
+	if run := q.Enqueue("install vim", NewCommand().Operation("S").Arguments("vim")); run == nil {
+		t.Error("the first job wasn't started")
+	}
+	if run := q.Enqueue("install git", NewCommand().Operation("S").Arguments("git")); run != nil {
+		t.Error("the second job was started while the first was running")
+	}
+
+	checkState(t, q, "vim:running", "git:pending")
+	if q.Pending() != 1 {
+		t.Errorf("pending = %d, want 1", q.Pending())
+	}
+}
+
+// Each job starts once the one before it finishes, whether or not it
+// succeeded.
+func TestQueueRunsSequentially(t *testing.T) {
+	q := &Queue{}
+	ids := enqueue(q, "vim", "git", "tmux")
+
+	if run := q.Finished(CommandDoneMsg{CommandId: job(q, ids[0]).CommandId}); run == nil {
+		t.Error("finishing didn't start the next job")
+	}
+	checkState(t, q, "vim:done", "git:running", "tmux:pending")
+
+	failure := errors.New("exit status 1")
+	if run := q.Finished(CommandDoneMsg{CommandId: job(q, ids[1]).CommandId, Err: failure}); run == nil {
+		t.Error("failing didn't start the next job")
+	}
+	checkState(t, q, "vim:done", "git:failed", "tmux:running")
+	if !errors.Is(job(q, ids[1]).Err, failure) {
+		t.Errorf("git error = %v, want %v", job(q, ids[1]).Err, failure)
+	}
+
+	// A cancelled command counts as failed, even if pacman exited
+	// cleanly.
+	if run := q.Finished(CommandDoneMsg{CommandId: job(q, ids[2]).CommandId, Interrupted: true}); run != nil {
+		t.Error("a job was started with nothing pending")
+	}
+	checkState(t, q, "vim:done", "git:failed", "tmux:failed")
+}
+
+func TestQueueFinishedIgnoresOtherCommands(t *testing.T) {
+	q := &Queue{}
+	ids := enqueue(q, "vim", "git")
+
+	// Queries finish all the time, and must not end the running job.
+	if run := q.Finished(CommandDoneMsg{CommandId: job(q, ids[0]).CommandId + 1000}); run != nil {
+		t.Error("an unrelated command started a job")
+	}
+	checkState(t, q, "vim:running", "git:pending")
+
+	// Nor can a job be finished twice.
+	q.Finished(CommandDoneMsg{CommandId: job(q, ids[0]).CommandId})
+	q.Finished(CommandDoneMsg{CommandId: job(q, ids[0]).CommandId, Err: errors.New("late")})
+	checkState(t, q, "vim:done", "git:running")
+}
+
+func TestQueueMove(t *testing.T) {
+	q := &Queue{}
+	ids := enqueue(q, "vim", "git", "tmux", "htop")
+
+	if err := q.Move(ids[3], -1); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	checkState(t, q, "vim:running", "git:pending", "htop:pending", "tmux:pending")
+
+	// Moving past the front stops before the running job.
+	if err := q.Move(ids[3], -10); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	checkState(t, q, "vim:running", "htop:pending", "git:pending", "tmux:pending")
+
+	// Moving past the end stops at the end.
+	if err := q.Move(ids[3], 10); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	checkState(t, q, "vim:running", "git:pending", "tmux:pending", "htop:pending")
+
+	if err := q.Move(ids[0], 1); !errors.Is(err, ErrJobNotPending) {
+		t.Errorf("moving the running job = %v, want ErrJobNotPending", err)
+	}
+	if err := q.Move(1000, 1); !errors.Is(err, ErrNoSuchJob) {
+		t.Errorf("moving a missing job = %v, want ErrNoSuchJob", err)
+	}
+}
+
+// Pending jobs skip over those that have already run.
+func TestQueueMoveSkipsFinished(t *testing.T) {
+	q := &Queue{}
+	ids := enqueue(q, "vim", "git", "tmux")
+
+	q.Finished(CommandDoneMsg{CommandId: job(q, ids[0]).CommandId, Err: errors.New("failed")})
+	ids = append(ids, enqueue(q, "htop")...)
+	checkState(t, q, "vim:failed", "git:running", "tmux:pending", "htop:pending")
+
+	if err := q.Move(ids[3], -1); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	checkState(t, q, "vim:failed", "git:running", "htop:pending", "tmux:pending")
+}
+
+func TestQueueDrop(t *testing.T) {
+	q := &Queue{}
+	ids := enqueue(q, "vim", "git", "tmux")
+
+	if err := q.Drop(ids[0]); !errors.Is(err, ErrJobNotPending) {
+		t.Errorf("dropping the running job = %v, want ErrJobNotPending", err)
+	}
+	if err := q.Drop(ids[1]); err != nil {
+		t.Fatalf("Drop: %v", err)
+	}
+	checkState(t, q, "vim:running", "tmux:pending")
+
+	if err := q.Drop(ids[1]); !errors.Is(err, ErrNoSuchJob) {
+		t.Errorf("dropping twice = %v, want ErrNoSuchJob", err)
+	}
+
+	// The dropped job is never started.
+	q.Finished(CommandDoneMsg{CommandId: job(q, ids[0]).CommandId})
+	checkState(t, q, "vim:done", "tmux:running")
+}
+
+func TestQueueRetry(t *testing.T) {
+	q := &Queue{}
+	ids := enqueue(q, "vim", "git")
+
+	if _, err := q.Retry(ids[0]); !errors.Is(err, ErrJobNotFailed) {
+		t.Errorf("retrying a running job = %v, want ErrJobNotFailed", err)
+	}
+	if _, err := q.Retry(ids[1]); !errors.Is(err, ErrJobNotFailed) {
+		t.Errorf("retrying a pending job = %v, want ErrJobNotFailed", err)
+	}
+	if _, err := q.Retry(1000); !errors.Is(err, ErrNoSuchJob) {
+		t.Errorf("retrying a missing job = %v, want ErrNoSuchJob", err)
+	}
+
+	failure := errors.New("exit status 1")
+	q.Finished(CommandDoneMsg{CommandId: job(q, ids[0]).CommandId, Err: failure})
+
+	// Another job is running, so the retry waits at the end.
+	run, err := q.Retry(ids[0])
+	if err != nil || run != nil {
+		t.Fatalf("Retry = %v, %v, want it queued", run, err)
+	}
+	checkState(t, q, "git:running", "vim:pending")
+
+	q.Finished(CommandDoneMsg{CommandId: job(q, ids[1]).CommandId})
+	checkState(t, q, "git:done", "vim:running")
+	if job(q, ids[0]).Err != nil {
+		t.Errorf("retried job kept its error %v", job(q, ids[0]).Err)
+	}
+
+	if _, err := q.Retry(ids[1]); !errors.Is(err, ErrJobNotFailed) {
+		t.Errorf("retrying a done job = %v, want ErrJobNotFailed", err)
+	}
+}
+
+// With nothing else running, a retried job starts straight away.
+func TestQueueRetryWhenIdle(t *testing.T) {
+	q := &Queue{}
+	ids := enqueue(q, "vim")
+	q.Finished(CommandDoneMsg{CommandId: job(q, ids[0]).CommandId, Err: errors.New("failed")})
+
+	run, err := q.Retry(ids[0])
+	if err != nil || run == nil {
+		t.Fatalf("Retry = %v, %v, want it started", run, err)
+	}
+	checkState(t, q, "vim:running")
+}
+
+func TestQueueClearFinished(t *testing.T) {
+	q := &Queue{}
+	ids := enqueue(q, "vim", "git", "tmux", "htop")
+
+	q.Finished(CommandDoneMsg{CommandId: job(q, ids[0]).CommandId})
+	q.Finished(CommandDoneMsg{CommandId: job(q, ids[1]).CommandId, Err: errors.New("failed")})
+	checkState(t, q, "vim:done", "git:failed", "tmux:running", "htop:pending")
+
+	// Failed jobs stay so that they can be retried.
+	q.ClearFinished()
+	checkState(t, q, "git:failed", "tmux:running", "htop:pending")
+}
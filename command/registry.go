@@ -0,0 +1,206 @@
+package command
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"ptui/types"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ErrCancelled is reported for a command cancelled before it started,
+// such as a transaction waiting for the database lock.
+var ErrCancelled = errors.New("cancelled before it started")
+
+// Handle refers to a running command so that it can be stopped. It's
+// registered as soon as the command is run, so a transaction can be
+// cancelled while it waits for the database lock, and is delivered with
+// CommandStartMsg once the process starts. It stays valid until the
+// matching CommandDoneMsg.
+type Handle struct {
+	CommandId int
+	Target    types.StreamTarget
+	Args      []string
+
+	// Argv is the full command line, including the binary and any
+	// elevation helper.
+	Argv []string
+
+	// IsTransaction is set for commands which change the system.
+	IsTransaction bool
+
+	// IsTerminal is set for commands run in a pseudo-terminal, which
+	// merges stderr into stdout.
+	IsTerminal bool
+
+	elevator *Elevator
+
+	mutex       sync.Mutex
+	proc        Process
+	cancelled   chan struct{}
+	cancelOnce  sync.Once
+	cancelCount atomic.Int32
+}
+
+func newHandle(id int, target types.StreamTarget, args []string, argv []string, isTransaction bool, elevator *Elevator) *Handle {
+	return &Handle{
+		CommandId:     id,
+		Target:        target,
+		Args:          args,
+		Argv:          argv,
+		IsTransaction: isTransaction,
+		elevator:      elevator,
+		cancelled:     make(chan struct{}),
+	}
+}
+
+// Cancel asks the command to stop. The first call sends SIGINT, which
+// lets pacman roll back cleanly; later calls escalate to SIGTERM. A
+// command which hasn't started yet is never started, and no signal is
+// sent.
+//
+// doas and pkexec become the root process they run, rather than
+// relaying signals to it like sudo, so signalling those fails with a
+// permission error. SignalThroughHelper can send the signal instead.
+func (h *Handle) Cancel() (os.Signal, error) {
+	var sig os.Signal = syscall.SIGINT
+	if h.cancelCount.Add(1) > 1 {
+		sig = syscall.SIGTERM
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.proc == nil {
+		h.cancelOnce.Do(func() { close(h.cancelled) })
+		return nil, nil
+	}
+
+	return sig, h.proc.Signal(sig)
+}
+
+func (h *Handle) WasCancelled() bool {
+	return h.cancelCount.Load() > 0
+}
+
+// signalNames are the signals Cancel sends, as kill names them.
+var signalNames = map[os.Signal]string{
+	syscall.SIGINT:  "INT",
+	syscall.SIGTERM: "TERM",
+}
+
+// SignalThroughHelper sends sig to the command by running kill through
+// the elevation helper the command was run with, streaming its output
+// to target.
+func (h *Handle) SignalThroughHelper(sig os.Signal, target types.StreamTarget) (tea.Cmd, error) {
+	kill, err := h.killCommand(sig)
+	if err != nil {
+		return nil, err
+	}
+	return kill.Target(target).Run(), nil
+}
+
+func (h *Handle) killCommand(sig os.Signal) (*Command, error) {
+	h.mutex.Lock()
+	proc := h.proc
+	h.mutex.Unlock()
+
+	pid, hasPid := processId(proc)
+	name, isKnown := signalNames[sig]
+	if h.elevator == nil || !hasPid || !isKnown {
+		return nil, errors.New("command can't be signalled through a helper")
+	}
+
+	return NewCommand().
+		Executor(NewElevatedExecutor(h.elevator, "kill")).
+		Arguments("-s", name, "--", strconv.Itoa(pid)), nil
+}
+
+// start records the process once it's started, unless the command was
+// cancelled first.
+func (h *Handle) start(executor Executor, args []string) (Process, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.WasCancelled() {
+		return nil, ErrCancelled
+	}
+
+	proc, err := executor.Start(args)
+	h.proc = proc
+	return proc, err
+}
+
+type registry struct {
+	mutex   sync.Mutex
+	handles map[int]*Handle
+}
+
+var running = registry{handles: make(map[int]*Handle)}
+
+func (r *registry) add(h *Handle) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.handles[h.CommandId] = h
+}
+
+func (r *registry) remove(id int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.handles, id)
+}
+
+// Running returns the handles of all running commands, oldest first,
+// including any still waiting for the database lock.
+func Running() []*Handle {
+	running.mutex.Lock()
+	defer running.mutex.Unlock()
+
+	handles := make([]*Handle, 0, len(running.handles))
+	for _, h := range running.handles {
+		handles = append(handles, h)
+	}
+
+	slices.SortFunc(handles, func(a, b *Handle) int { return a.CommandId - b.CommandId })
+	return handles
+}
+
+func Lookup(id int) (*Handle, bool) {
+	running.mutex.Lock()
+	defer running.mutex.Unlock()
+
+	h, exists := running.handles[id]
+	return h, exists
+}
+
+// LatestTransaction returns the most recently run transaction.
+func LatestTransaction() (*Handle, bool) {
+	handles := Running()
+	for i := len(handles) - 1; i >= 0; i-- {
+		if handles[i].IsTransaction {
+			return handles[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// Latest returns the most recently started command streaming to target.
+func Latest(target types.StreamTarget) (*Handle, bool) {
+	handles := Running()
+	for i := len(handles) - 1; i >= 0; i-- {
+		if handles[i].Target == target {
+			return handles[i], true
+		}
+	}
+
+	return nil, false
+}
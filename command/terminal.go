@@ -0,0 +1,138 @@
+package command
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ErrTerminalUnsupported is returned when pseudo-terminals can't be
+// opened on this platform.
+var ErrTerminalUnsupported = errors.New("pseudo-terminals are not supported on this platform")
+
+// The size reported to processes run in a terminal. pacman fits its
+// progress bars to the width.
+const (
+	terminalRows    = 24
+	terminalColumns = 120
+)
+
+// How long output is still read after a terminal process exits. Helpers
+// it started, such as gpg-agent, can keep the terminal open
+// indefinitely.
+const terminalDrainTimeout = time.Second
+
+// TerminalExecutor runs a pacman-compatible binary in a pseudo-terminal
+// rather than with pipes. pacman only asks questions, such as which
+// provider to install or whether to import a key, when it's attached
+// to a terminal, so commands run this way can do without --noconfirm.
+// Everything the process prints arrives on Stdout.
+type TerminalExecutor struct {
+	Binary   string
+	Elevator *Elevator
+}
+
+func NewTerminalExecutor(elevator *Elevator, binary string) *TerminalExecutor {
+	return &TerminalExecutor{Binary: binary, Elevator: elevator}
+}
+
+func (e *TerminalExecutor) CommandLine(args []string) []string {
+	return wrappedCommandLine(e.Elevator, e.Binary, args)
+}
+
+func (e *TerminalExecutor) Start(args []string) (Process, error) {
+	name, args := e.Binary, args
+	if e.Elevator != nil {
+		name, args = e.Elevator.Wrap(e.Binary, args)
+	}
+
+	master, slave, err := openTerminal()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	setControllingTerminal(cmd)
+
+	err = cmd.Start()
+
+	// The child has its own copy now. Ours has to be closed, or reading
+	// the master would never reach the end.
+	slave.Close()
+
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	proc := &terminalProcess{cmd: cmd, master: master, exited: make(chan struct{})}
+	go proc.wait()
+
+	return proc, nil
+}
+
+// isTerminal reports whether commands run by e can prompt the user.
+func isTerminal(e Executor) bool {
+	_, ok := e.(*TerminalExecutor)
+	return ok
+}
+
+type terminalProcess struct {
+	cmd    *exec.Cmd
+	master *os.File
+
+	exited chan struct{}
+	err    error
+}
+
+func (p *terminalProcess) wait() {
+	p.err = p.cmd.Wait()
+	close(p.exited)
+
+	time.AfterFunc(terminalDrainTimeout, func() { p.master.Close() })
+}
+
+func (p *terminalProcess) Stdin() io.Writer  { return p.master }
+func (p *terminalProcess) Stdout() io.Reader { return terminalReader{p.master} }
+func (p *terminalProcess) Stderr() io.Reader { return strings.NewReader("") }
+
+func (p *terminalProcess) Wait() error {
+	<-p.exited
+	return p.err
+}
+
+// Signal signals the process directly. That isn't allowed once a helper
+// such as doas has become root, but an interrupt can still be sent by
+// typing ^C into the terminal, as a user would.
+func (p *terminalProcess) Signal(sig os.Signal) error {
+	err := p.cmd.Process.Signal(sig)
+	if errors.Is(err, os.ErrPermission) && sig == os.Interrupt {
+		_, err = p.master.Write([]byte{0x03})
+	}
+	return err
+}
+
+func (p *terminalProcess) Pid() int {
+	return p.cmd.Process.Pid
+}
+
+// terminalReader reports the end of the output as EOF. Linux fails
+// reads with EIO once the other end of the terminal is closed, and the
+// master may have been closed after the drain timeout.
+type terminalReader struct {
+	file *os.File
+}
+
+func (r terminalReader) Read(p []byte) (int, error) {
+	n, err := r.file.Read(p)
+	if err != nil && (isTerminalClosed(err) || errors.Is(err, os.ErrClosed)) {
+		return n, io.EOF
+	}
+
+	return n, err
+}
@@ -0,0 +1,92 @@
+//go:build linux
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+const TerminalSupported = true
+
+// openTerminal allocates a pseudo-terminal pair. Echo is turned off so
+// that answers written to the master aren't read back as output.
+func openTerminal() (master *os.File, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			master.Close()
+		}
+	}()
+
+	var unlock int32
+	if err = ioctl(master, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		return nil, nil, err
+	}
+
+	var number uint32
+	if err = ioctl(master, syscall.TIOCGPTN, unsafe.Pointer(&number)); err != nil {
+		return nil, nil, err
+	}
+
+	slave, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", number), os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var termios syscall.Termios
+	if err = ioctl(slave, syscall.TCGETS, unsafe.Pointer(&termios)); err == nil {
+		termios.Lflag &^= syscall.ECHO
+		err = ioctl(slave, syscall.TCSETS, unsafe.Pointer(&termios))
+	}
+
+	if err == nil {
+		size := struct{ rows, columns, x, y uint16 }{terminalRows, terminalColumns, 0, 0}
+		err = ioctl(slave, syscall.TIOCSWINSZ, unsafe.Pointer(&size))
+	}
+
+	if err != nil {
+		slave.Close()
+		return nil, nil, err
+	}
+
+	return master, slave, nil
+}
+
+// setControllingTerminal starts cmd in a new session with its stdin as
+// the controlling terminal, so that it's detached from pTUI's own.
+func setControllingTerminal(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+}
+
+func isTerminalClosed(err error) bool {
+	return errors.Is(err, syscall.EIO)
+}
+
+func ioctl(file *os.File, request uintptr, arg unsafe.Pointer) error {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg))
+	})
+
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
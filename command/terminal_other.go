@@ -0,0 +1,20 @@
+//go:build !linux
+
+package command
+
+import (
+	"os"
+	"os/exec"
+)
+
+const TerminalSupported = false
+
+func openTerminal() (master *os.File, slave *os.File, err error) {
+	return nil, nil, ErrTerminalUnsupported
+}
+
+func setControllingTerminal(cmd *exec.Cmd) {}
+
+func isTerminalClosed(err error) bool {
+	return false
+}
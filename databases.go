@@ -0,0 +1,166 @@
+package main
+
+import (
+	"time"
+
+	"ptui/alpm"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type localDbLoadedMsg struct {
+	db  *alpm.LocalDB
+	err error
+
+	// pacman's log is optional, so this can be nil.
+	log *alpm.PackageLog
+}
+
+type syncIndexLoadedMsg struct {
+	idx *alpm.SyncIndex
+	err error
+}
+
+// packageDatabases is shared by every tab, so each database is read
+// once by whichever tab needs it first. The root model stores loaded
+// databases before passing the message on to the selected tab.
+type packageDatabases struct {
+	local *alpm.LocalDB
+
+	// Once the Updates tab has refreshed them, sync databases are read
+	// from its copy, so they may be newer than the system's.
+	sync *alpm.SyncIndex
+
+	log *alpm.PackageLog
+
+	// Without a readable database, tabs fall back to running pacman.
+	isLocalUnavailable bool
+	isSyncUnavailable  bool
+}
+
+func (d *packageDatabases) Update(msg tea.Msg) {
+	switch msg := msg.(type) {
+	case localDbLoadedMsg:
+		d.local = msg.db
+		d.isLocalUnavailable = msg.err != nil
+		d.log = msg.log
+
+	case syncIndexLoadedMsg:
+		d.sync = msg.idx
+		d.isSyncUnavailable = msg.err != nil
+	}
+}
+
+func loadLocalDb() tea.Msg {
+	db, err := alpm.ReadLocalDB(dbPath)
+	if err != nil {
+		return localDbLoadedMsg{err: err}
+	}
+
+	log, _ := alpm.ReadPackageLog(logPath)
+	return localDbLoadedMsg{db: db, log: log}
+}
+
+func loadSyncIndex() tea.Msg {
+	idx, err := alpm.ReadSyncIndex(dbPath, configPath)
+	return syncIndexLoadedMsg{idx: idx, err: err}
+}
+
+// loadRefreshedSyncIndex loads sync databases refreshed under path,
+// rather than the system's.
+func loadRefreshedSyncIndex(path string) tea.Cmd {
+	return func() tea.Msg {
+		idx, err := alpm.ReadSyncIndex(path, configPath)
+		return syncIndexLoadedMsg{idx: idx, err: err}
+	}
+}
+
+// needsSyncIndex reports whether the sync index should be loaded.
+func (d *packageDatabases) needsSyncIndex() bool {
+	return d.sync == nil && !d.isSyncUnavailable
+}
+
+// upgradeFor returns the newer sync package for an installed one, if
+// there is one.
+func (d *packageDatabases) upgradeFor(pkg *alpm.Package) (*alpm.Package, bool) {
+	if d.sync == nil {
+		return nil, false
+	}
+
+	newer, exists := d.sync.Package(pkg.Name)
+	if !exists || alpm.Vercmp(newer.Version, pkg.Version) <= 0 {
+		return nil, false
+	}
+
+	return newer, true
+}
+
+// installedPackage returns the local copy of a package, if it's
+// installed.
+func (d *packageDatabases) installedPackage(name string) (*alpm.Package, bool) {
+	if d.local == nil {
+		return nil, false
+	}
+	return d.local.Package(name)
+}
+
+// repository names the repository a package comes from. Installed
+// packages that aren't in any sync database are foreign.
+func (d *packageDatabases) repository(pkg *alpm.Package) string {
+	if pkg.Repository != "" || d.sync == nil {
+		return pkg.Repository
+	}
+
+	if syncPkg, exists := d.sync.Package(pkg.Name); exists {
+		return syncPkg.Repository
+	}
+	return "foreign"
+}
+
+// lastUpgrade is when pacman's log says a package was last upgraded.
+func (d *packageDatabases) lastUpgrade(pkg *alpm.Package) time.Time {
+	if d.log == nil {
+		return time.Time{}
+	}
+	return d.log.Upgraded[pkg.Name]
+}
+
+// upgradeMarker flags installed packages which have a newer version in
+// the sync databases, for display after their name in package lists.
+func (d *packageDatabases) upgradeMarker(name string) string {
+	if d.local == nil {
+		return ""
+	}
+
+	pkg, installed := d.local.Package(name)
+	if !installed {
+		return ""
+	}
+
+	if newer, exists := d.upgradeFor(pkg); exists {
+		return defaultStyle.Foreground(yellow).Render(" ↑ " + newer.Version)
+	}
+	return ""
+}
+
+// resolveDependency finds the package which satisfies dep, preferring
+// an installed one. Missing dependencies are looked up in the sync
+// databases if they're loaded, and are nil otherwise.
+func (d *packageDatabases) resolveDependency(dep string) (pkg *alpm.Package, isInstalled bool) {
+	if d.local != nil {
+		if satisfiers := d.local.Satisfiers(dep); len(satisfiers) > 0 {
+			return satisfiers[0], true
+		}
+	}
+
+	if d.sync != nil {
+		if pkg, exists := d.sync.Package(alpm.DependName(dep)); exists {
+			return pkg, false
+		}
+		if satisfiers := d.sync.Satisfiers(dep); len(satisfiers) > 0 {
+			return satisfiers[0], false
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"ptui/alpm"
+	"ptui/styles"
+)
+
+// dependencyTree shows a package's dependencies, and theirs in turn,
+// like pactree. Nodes are only expanded when asked, so that trees stay
+// small enough to read and quick to build.
+type dependencyTree struct {
+	root     *alpm.Package
+	nodes    []dependencyNode
+	expanded map[string]bool
+	cursor   int
+
+	// Reversed trees show the installed packages which need the root
+	// instead, like pactree -r.
+	isReversed bool
+
+	// For reversed trees, every package needing the root, however
+	// indirectly.
+	required []*alpm.Package
+	optional []*alpm.Package
+}
+
+// dependencyNode is a line of the tree.
+type dependencyNode struct {
+	// The dependency as its parent declares it, and the package which
+	// satisfies it, if one could be found. In reversed trees, the
+	// dependency is the name of a package needing its parent.
+	dep string
+	pkg *alpm.Package
+
+	// The names leading to the node from the root, which identify it
+	// across rebuilds.
+	key    string
+	prefix string
+
+	isOptional  bool
+	isInstalled bool
+	hasChildren bool
+
+	// The package is one of the node's ancestors, so expanding it would
+	// never end.
+	isCycle bool
+}
+
+// SetRoot shows the dependencies of pkg. The tree keeps its expanded
+// nodes when the same package is set again, which happens whenever the
+// local database is reloaded.
+func (t *dependencyTree) SetRoot(pkg *alpm.Package, dbs *packageDatabases) {
+	if t.root == nil || pkg == nil || t.root.Name != pkg.Name {
+		t.expanded = make(map[string]bool)
+		t.cursor = 0
+
+		if pkg != nil {
+			t.expanded[pkg.Name] = true
+		}
+	}
+
+	t.root = pkg
+	t.Build(dbs)
+}
+
+func (t *dependencyTree) Build(dbs *packageDatabases) {
+	t.nodes = t.nodes[:0]
+	if t.root == nil {
+		return
+	}
+
+	if t.isReversed && dbs.local != nil {
+		t.required, t.optional = dbs.local.Dependents(t.root)
+	}
+
+	root := dependencyNode{
+		dep:         t.root.Name,
+		pkg:         t.root,
+		key:         t.root.Name,
+		isInstalled: true,
+		hasChildren: t.hasChildren(t.root),
+	}
+	t.nodes = append(t.nodes, root)
+
+	if t.expanded[root.key] {
+		t.addChildren(root, map[string]bool{t.root.Name: true}, "", dbs)
+	}
+
+	t.cursor = min(t.cursor, len(t.nodes)-1)
+}
+
+func (t *dependencyTree) addChildren(parent dependencyNode, ancestors map[string]bool, prefix string, dbs *packageDatabases) {
+	type dependency struct {
+		dep        string
+		isOptional bool
+	}
+
+	var deps []dependency
+	if t.isReversed {
+		for _, name := range parent.pkg.RequiredBy {
+			deps = append(deps, dependency{dep: name})
+		}
+		for _, name := range parent.pkg.OptionalFor {
+			deps = append(deps, dependency{dep: name, isOptional: true})
+		}
+	} else {
+		for _, dep := range parent.pkg.Depends {
+			deps = append(deps, dependency{dep: dep})
+		}
+		for _, opt := range parent.pkg.OptDepends {
+			deps = append(deps, dependency{dep: opt.Name, isOptional: true})
+		}
+	}
+
+	for i, d := range deps {
+		branch, indent := "├─", "│ "
+		if i == len(deps)-1 {
+			branch, indent = "└─", "  "
+		}
+
+		node := dependencyNode{
+			dep:        d.dep,
+			prefix:     prefix + branch,
+			isOptional: d.isOptional,
+		}
+		node.pkg, node.isInstalled = dbs.resolveDependency(d.dep)
+		node.key = parent.key + "/" + nodeName(node.dep, node.pkg)
+
+		if node.pkg != nil {
+			node.isCycle = ancestors[node.pkg.Name]
+			node.hasChildren = !node.isCycle && t.hasChildren(node.pkg)
+		}
+
+		t.nodes = append(t.nodes, node)
+
+		if node.hasChildren && t.expanded[node.key] {
+			ancestors[node.pkg.Name] = true
+			t.addChildren(node, ancestors, prefix+indent, dbs)
+			delete(ancestors, node.pkg.Name)
+		}
+	}
+}
+
+func (t *dependencyTree) hasChildren(pkg *alpm.Package) bool {
+	if t.isReversed {
+		return len(pkg.RequiredBy) > 0 || len(pkg.OptionalFor) > 0
+	}
+	return len(pkg.Depends) > 0 || len(pkg.OptDepends) > 0
+}
+
+// nodeName is the package a node stands for, or the name it depends on
+// when nothing satisfies it.
+func nodeName(dep string, pkg *alpm.Package) string {
+	if pkg != nil {
+		return pkg.Name
+	}
+	return alpm.DependName(dep)
+}
+
+func (t *dependencyTree) Next() {
+	if t.cursor < len(t.nodes)-1 {
+		t.cursor++
+	}
+}
+
+func (t *dependencyTree) Previous() {
+	if t.cursor > 0 {
+		t.cursor--
+	}
+}
+
+func (t *dependencyTree) Selected() (dependencyNode, bool) {
+	if t.cursor < 0 || t.cursor >= len(t.nodes) {
+		return dependencyNode{}, false
+	}
+	return t.nodes[t.cursor], true
+}
+
+// Toggle expands or collapses the selected node.
+func (t *dependencyTree) Toggle(dbs *packageDatabases) {
+	node, selected := t.Selected()
+	if !selected || !node.hasChildren {
+		return
+	}
+
+	t.expanded[node.key] = !t.expanded[node.key]
+	t.Build(dbs)
+}
+
+// ExpandAll expands the required dependencies, or dependents, below the
+// selected node. Like pactree, a package is only expanded the first
+// time it's reached, and optional ones are left for the user to open.
+func (t *dependencyTree) ExpandAll(dbs *packageDatabases) {
+	node, selected := t.Selected()
+	if !selected || !node.hasChildren {
+		return
+	}
+
+	visited := make(map[string]bool)
+
+	var expand func(key string, pkg *alpm.Package)
+	expand = func(key string, pkg *alpm.Package) {
+		if visited[pkg.Name] {
+			return
+		}
+		visited[pkg.Name] = true
+		t.expanded[key] = true
+
+		deps := pkg.Depends
+		if t.isReversed {
+			deps = pkg.RequiredBy
+		}
+
+		for _, dep := range deps {
+			if child, _ := dbs.resolveDependency(dep); child != nil {
+				expand(key+"/"+child.Name, child)
+			}
+		}
+	}
+	expand(node.key, node.pkg)
+
+	t.Build(dbs)
+}
+
+// Render draws the tree, returning the line the cursor is on.
+func (t *dependencyTree) Render(width int) (string, int) {
+	if t.root == nil {
+		return "", -1
+	}
+
+	var builder strings.Builder
+	cursorLine := t.cursor
+
+	if t.isReversed {
+		builder.WriteString(reducedEmphasisStyle.Render(t.summary()) + "\n")
+		cursorLine++
+	}
+
+	for i, node := range t.nodes {
+		toggle := "  "
+		if node.hasChildren {
+			toggle = "▸ "
+			if t.expanded[node.key] {
+				toggle = "▾ "
+			}
+		}
+
+		label := node.dep
+		if i == 0 {
+			label += " " + node.pkg.Version
+		} else if node.pkg != nil && node.pkg.Name != alpm.DependName(node.dep) {
+			label += " → " + node.pkg.Name
+		}
+
+		if i == t.cursor {
+			label = selectedStyle.Render(label)
+		} else if node.isOptional {
+			label = reducedEmphasisStyle.Render(label)
+		}
+
+		line := reducedEmphasisStyle.Render(node.prefix) + toggle + label + t.nodeStatus(node, i == 0)
+		builder.WriteString(defaultStyle.MaxWidth(width).Render(line) + "\n")
+	}
+
+	return builder.String(), cursorLine
+}
+
+// summary counts the packages which need the root of a reversed tree,
+// including those further up than the tree is expanded.
+func (t *dependencyTree) summary() string {
+	if len(t.required) == 0 && len(t.optional) == 0 {
+		return fmt.Sprintf("Nothing installed needs %s", t.root.Name)
+	}
+
+	return fmt.Sprintf(
+		"%d packages need %s, %d more can use it optionally",
+		len(t.required),
+		t.root.Name,
+		len(t.optional),
+	)
+}
+
+// nodeStatus marks whether a dependency is installed. Optional
+// dependencies that aren't installed are expected, so aren't flagged
+// as missing. Dependents are always installed, so aren't marked.
+func (t *dependencyTree) nodeStatus(node dependencyNode, isRoot bool) string {
+	var status string
+	switch {
+	case isRoot:
+		return ""
+	case t.isReversed:
+	case node.isInstalled:
+		status = styles.SuccessStyle.Render(" ✓")
+	case node.isOptional:
+		status = reducedEmphasisStyle.Render(" ○ not installed")
+	case node.pkg == nil:
+		status = styles.ErrorStyle.Render(" ✗ not found")
+	default:
+		status = styles.ErrorStyle.Render(fmt.Sprintf(" ✗ missing, in %s", node.pkg.Repository))
+	}
+
+	if node.isOptional {
+		status += reducedEmphasisStyle.Render(" (optional)")
+	}
+	if node.isCycle {
+		status += reducedEmphasisStyle.Render(" ↻ cycle")
+	}
+
+	return status
+}
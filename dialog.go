@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	cmd "ptui/command"
+	"ptui/styles"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// openDialogMsg asks the root model to show a modal dialog. While a
+// dialog is open it receives all key presses.
+type openDialogMsg struct {
+	dialog *dialogModel
+}
+
+// dialogOption is chosen by pressing its key. Options without a label
+// are left out of the options row, for menus which list them in the
+// body instead.
+type dialogOption struct {
+	key    string
+	label  string
+	action func() tea.Cmd
+}
+
+type dialogModel struct {
+	title   string
+	body    string
+	options []dialogOption
+
+	// Dialogs with an input submit its value with enter instead of
+	// offering options.
+	input    *textinput.Model
+	onSubmit func(value string) tea.Cmd
+
+	// Run when the dialog is dismissed without choosing an option.
+	onCancel func() tea.Cmd
+
+	// Bodies too tall for the screen scroll with the arrow keys.
+	scroll int
+
+	// Set for dialogs answering a prompt from a running command.
+	isPrompt  bool
+	commandId int
+
+	isClosed bool
+}
+
+func newConfirmDialog(title string, body string, onConfirm func() tea.Cmd) *dialogModel {
+	return &dialogModel{
+		title: title,
+		body:  body,
+		options: []dialogOption{
+			{key: "y", label: "Yes", action: onConfirm},
+			{key: "n", label: "No"},
+		},
+	}
+}
+
+// newMessageDialog only informs, so its single option just closes it.
+func newMessageDialog(title string, body string) *dialogModel {
+	return &dialogModel{
+		title:   title,
+		body:    body,
+		options: []dialogOption{{key: "enter", label: "Close"}},
+	}
+}
+
+func newInputDialog(title string, prompt string, onSubmit func(string) tea.Cmd, onCancel func() tea.Cmd) *dialogModel {
+	input := textinput.New()
+	input.Prompt = ""
+	input.Focus()
+
+	return &dialogModel{
+		title:    title,
+		body:     prompt,
+		input:    &input,
+		onSubmit: onSubmit,
+		onCancel: onCancel,
+	}
+}
+
+func newPasswordDialog(title string, prompt string, onSubmit func(string) tea.Cmd, onCancel func() tea.Cmd) *dialogModel {
+	d := newInputDialog(title, prompt, onSubmit, onCancel)
+	d.input.EchoMode = textinput.EchoPassword
+	d.input.EchoCharacter = '•'
+
+	return d
+}
+
+func openDialog(d *dialogModel) tea.Cmd {
+	return func() tea.Msg { return openDialogMsg{dialog: d} }
+}
+
+func (d *dialogModel) Update(msg tea.KeyMsg) tea.Cmd {
+	key := msg.String()
+
+	if key == "esc" {
+		d.isClosed = true
+		if d.onCancel != nil {
+			return d.onCancel()
+		}
+		return nil
+	}
+
+	if d.input != nil {
+		if key == "enter" {
+			d.isClosed = true
+			return d.onSubmit(d.input.Value())
+		}
+
+		updated, cmd := d.input.Update(msg)
+		*d.input = updated
+		return cmd
+	}
+
+	switch key {
+	case "up", "k":
+		d.scroll = max(0, d.scroll-1)
+		return nil
+	case "down", "j":
+		d.scroll++
+		return nil
+	}
+
+	for _, opt := range d.options {
+		if key != opt.key && !(key == "enter" && opt.key == d.options[0].key) {
+			continue
+		}
+
+		d.isClosed = true
+		if opt.action != nil {
+			return opt.action()
+		}
+		return nil
+	}
+
+	return nil
+}
+
+func (d *dialogModel) View(width int, height int) string {
+	innerWidth := max(20, min(width-BORDER_WIDTH-4, 72))
+
+	// Leave room for the border, title, options and spacing.
+	bodyHeight := max(3, height-BORDER_WIDTH-4)
+	if d.input != nil {
+		bodyHeight--
+	}
+
+	var optionsRow strings.Builder
+	if d.input != nil {
+		optionsRow.WriteString("Submit")
+		optionsRow.WriteString(styles.HotkeyStyle.Render("enter"))
+	}
+
+	for _, opt := range d.options {
+		if opt.label == "" {
+			continue
+		}
+
+		if optionsRow.Len() > 0 {
+			optionsRow.WriteString("  ")
+		}
+		optionsRow.WriteString(opt.label)
+		optionsRow.WriteString(styles.HotkeyStyle.Render(opt.key))
+	}
+	if len(d.options) != 1 {
+		if optionsRow.Len() > 0 {
+			optionsRow.WriteString("  ")
+		}
+		optionsRow.WriteString("Cancel")
+		optionsRow.WriteString(styles.HotkeyStyle.Render("esc"))
+	}
+
+	body := defaultStyle.Width(innerWidth).Render(d.body)
+	if bodyLines := strings.Split(body, "\n"); len(bodyLines) > bodyHeight {
+		// One line is given up to show the scroll position.
+		visible := bodyHeight - 1
+		d.scroll = min(d.scroll, len(bodyLines)-visible)
+
+		body = strings.Join(bodyLines[d.scroll:d.scroll+visible], "\n")
+		body += "\n" + reducedEmphasisStyle.Render(fmt.Sprintf(
+			"↑↓ %d-%d of %d lines", d.scroll+1, d.scroll+visible, len(bodyLines)))
+	}
+
+	rows := []string{
+		defaultStyle.Foreground(yellow).Render(d.title),
+		"",
+		body,
+	}
+
+	if d.input != nil {
+		d.input.Width = innerWidth
+		rows = append(rows, d.input.View())
+	}
+
+	rows = append(rows, "", reducedEmphasisStyle.Render(optionsRow.String()))
+	content := lipgloss.JoinVertical(lipgloss.Left, rows...)
+
+	return panelStyle.Padding(0, 1).Render(content)
+}
+
+// newPromptDialog asks the user to answer a prompt from a running
+// command. Dismissing it cancels the command, since the process would
+// otherwise wait forever.
+func newPromptDialog(msg cmd.PromptMsg) *dialogModel {
+	cancel := func() tea.Cmd {
+		if handle, exists := cmd.Lookup(msg.CommandId); exists {
+			handle.Cancel()
+		}
+		return nil
+	}
+
+	reply := func(answer string) tea.Cmd {
+		if err := msg.Reply(answer); err != nil {
+			return setStatus(fmt.Sprintf("Could not answer prompt: %s", err))
+		}
+		return nil
+	}
+
+	var d *dialogModel
+	switch {
+	case msg.Secret:
+		d = newPasswordDialog("Authentication required", msg.Text, reply, cancel)
+
+	case len(msg.Choices) == 0:
+		d = newInputDialog("pacman needs an answer", promptBody(msg), reply, cancel)
+		d.input.Placeholder = msg.Default
+
+	default:
+		d = &dialogModel{title: "pacman needs an answer", body: promptBody(msg), onCancel: cancel}
+		for _, choice := range msg.Choices {
+			d.options = append(d.options, dialogOption{
+				key:    choice.Answer,
+				label:  choice.Label,
+				action: func() tea.Cmd { return reply(choice.Answer) },
+			})
+		}
+	}
+
+	d.isPrompt, d.commandId = true, msg.CommandId
+	return d
+}
+
+// promptBody shows a prompt after the output leading up to it.
+func promptBody(msg cmd.PromptMsg) string {
+	if len(msg.Context) == 0 {
+		return msg.Text
+	}
+	return strings.Join(msg.Context, "\n") + "\n\n" + msg.Text
+}
+
+func (d *dialogModel) isPromptFor(commandId int) bool {
+	return d.isPrompt && d.commandId == commandId
+}
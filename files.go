@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"ptui/alpm"
+)
+
+// fileTree shows the files a package installed as a directory tree,
+// with the total size of each directory. Searching swaps the tree for
+// a flat list of matching paths, ranked like the package lists.
+type fileTree struct {
+	pkgName string
+	files   []alpm.File
+
+	// Directory sizes are the sum of the files below them.
+	dirSizes  map[string]int64
+	collapsed map[string]bool
+
+	rows   []fileRow
+	cursor int
+
+	// Sizes are only known when the files were read from the local
+	// database, rather than listed by pacman -Ql.
+	hasSizes bool
+
+	isSearching bool
+	searchError error
+}
+
+type fileRow struct {
+	file      alpm.File
+	depth     int
+	positions []int
+}
+
+// SetFiles shows the files of a package. Collapsed directories are kept
+// when the same package is set again.
+func (t *fileTree) SetFiles(pkgName string, files []alpm.File, hasSizes bool) {
+	if pkgName != t.pkgName {
+		t.collapsed = make(map[string]bool)
+		t.cursor = 0
+	}
+
+	t.pkgName = pkgName
+	t.files = files
+	t.hasSizes = hasSizes
+
+	t.dirSizes = make(map[string]int64)
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		for dir := path.Dir(file.Path); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			t.dirSizes[dir+"/"] += file.Size
+		}
+	}
+}
+
+// SetFilesFromLines parses pacman -Ql output, which gives paths but not
+// sizes.
+func (t *fileTree) SetFilesFromLines(pkgName string, lines []string) {
+	var files []alpm.File
+	for _, line := range lines {
+		_, filePath, found := strings.Cut(strings.TrimSpace(line), " ")
+		if !found {
+			continue
+		}
+		files = append(files, alpm.File{Path: strings.TrimPrefix(filePath, "/")})
+	}
+
+	t.SetFiles(pkgName, files, false)
+}
+
+// Build lists the rows to show, either the tree without the contents
+// of collapsed directories or the files matching the search.
+func (t *fileTree) Build(pattern string, mode searchMode) {
+	t.rows = t.rows[:0]
+	t.isSearching = pattern != ""
+	t.searchError = nil
+
+	if t.isSearching {
+		lines := make([]string, len(t.files))
+		for i, file := range t.files {
+			lines[i] = "/" + file.Path + "\n"
+		}
+
+		matches, err := searchLines(lines, pattern, mode)
+		if err != nil {
+			t.searchError = err
+		}
+
+		for _, match := range matches {
+			t.rows = append(t.rows, fileRow{file: t.files[match.index], positions: match.positions})
+		}
+	} else {
+		hiddenUnder := ""
+		for _, file := range t.files {
+			if hiddenUnder != "" && strings.HasPrefix(file.Path, hiddenUnder) {
+				continue
+			}
+			hiddenUnder = ""
+
+			depth := strings.Count(strings.TrimSuffix(file.Path, "/"), "/")
+			t.rows = append(t.rows, fileRow{file: file, depth: depth})
+
+			if file.IsDir() && t.collapsed[file.Path] {
+				hiddenUnder = file.Path
+			}
+		}
+	}
+
+	t.cursor = max(0, min(t.cursor, len(t.rows)-1))
+}
+
+func (t *fileTree) Next() {
+	if t.cursor < len(t.rows)-1 {
+		t.cursor++
+	}
+}
+
+func (t *fileTree) Previous() {
+	if t.cursor > 0 {
+		t.cursor--
+	}
+}
+
+// SelectedPath is the absolute path of the file under the cursor.
+func (t *fileTree) SelectedPath() (string, bool) {
+	if t.cursor >= len(t.rows) {
+		return "", false
+	}
+	return "/" + strings.TrimSuffix(t.rows[t.cursor].file.Path, "/"), true
+}
+
+// Toggle collapses or expands the directory under the cursor.
+func (t *fileTree) Toggle() {
+	if t.isSearching || t.cursor >= len(t.rows) {
+		return
+	}
+
+	if file := t.rows[t.cursor].file; file.IsDir() {
+		t.collapsed[file.Path] = !t.collapsed[file.Path]
+	}
+}
+
+func (t *fileTree) ExpandAll() {
+	clear(t.collapsed)
+}
+
+// Render draws the rows below a header, returning the line the cursor
+// is on.
+func (t *fileTree) Render(width int, header string) (string, int) {
+	var builder strings.Builder
+	builder.WriteString(header + "\n")
+
+	switch {
+	case t.searchError != nil:
+		fmt.Fprintf(&builder, "Invalid regular expression: %s\n", t.searchError)
+		return builder.String(), -1
+	case len(t.rows) == 0 && t.isSearching:
+		builder.WriteString(reducedEmphasisStyle.Render("No files match") + "\n")
+		return builder.String(), -1
+	case len(t.rows) == 0:
+		builder.WriteString(reducedEmphasisStyle.Render("This package has no files") + "\n")
+		return builder.String(), -1
+	}
+
+	sizeWidth := 0
+	if t.hasSizes {
+		sizeWidth = 12
+	}
+
+	for i, row := range t.rows {
+		style, highlight := defaultStyle, matchStyle
+		if i == t.cursor {
+			style, highlight = selectedStyle, selectedMatchStyle
+		}
+
+		var name string
+		var size int64
+		if t.isSearching {
+			name = highlightMatches("/"+row.file.Path, row.positions, style, highlight)
+		} else {
+			toggle := "  "
+			if row.file.IsDir() {
+				toggle = "▾ "
+				if t.collapsed[row.file.Path] {
+					toggle = "▸ "
+				}
+			}
+
+			base := path.Base(row.file.Path)
+			if row.file.IsDir() {
+				base += "/"
+			}
+			name = strings.Repeat("  ", row.depth) + toggle + style.Render(base)
+		}
+
+		if row.file.IsDir() {
+			size = t.dirSizes[row.file.Path]
+		} else {
+			size = row.file.Size
+		}
+
+		if row.file.LinkTarget != "" {
+			name += reducedEmphasisStyle.Render(" → " + row.file.LinkTarget)
+		}
+
+		name = defaultStyle.MaxWidth(max(0, width-sizeWidth)).Render(name)
+
+		var sizeText string
+		if t.hasSizes {
+			sizeText = reducedEmphasisStyle.Render(fmt.Sprintf("%*s", sizeWidth, formatSize(size)))
+		}
+
+		builder.WriteString(padRight(name, width-sizeWidth) + sizeText + "\n")
+	}
+
+	return builder.String(), t.cursor + 1
+}
+
+// Summary counts the package's files, and their size if it's known.
+func (t *fileTree) Summary() string {
+	var count int
+	var size int64
+	for _, file := range t.files {
+		if !file.IsDir() {
+			count++
+			size += file.Size
+		}
+	}
+
+	summary := fmt.Sprintf("%s: %d files", t.pkgName, count)
+	if t.hasSizes {
+		summary += ", " + formatSize(size)
+	}
+	if t.isSearching {
+		summary += fmt.Sprintf(", %d matching", len(t.rows))
+	}
+
+	return summary
+}
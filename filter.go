@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ptui/alpm"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type reasonFilter uint8
+
+const (
+	anyReason reasonFilter = iota
+	explicitOnly
+	dependenciesOnly
+	orphansOnly
+)
+
+type originFilter uint8
+
+const (
+	anyOrigin originFilter = iota
+	foreignOnly
+	nativeOnly
+	repositoryOnly
+)
+
+// packageFilter narrows the Installed list the way pacman -Q's filter
+// options do. The parts combine, so explicitly installed foreign
+// packages are -Qem.
+type packageFilter struct {
+	reason     reasonFilter
+	origin     originFilter
+	repository string
+
+	isUpgradableOnly bool
+}
+
+func (f packageFilter) String() string {
+	var parts []string
+
+	switch f.reason {
+	case explicitOnly:
+		parts = append(parts, "Explicit")
+	case dependenciesOnly:
+		parts = append(parts, "Dependencies")
+	case orphansOnly:
+		parts = append(parts, "Orphans")
+	}
+
+	switch f.origin {
+	case foreignOnly:
+		parts = append(parts, "Foreign")
+	case nativeOnly:
+		parts = append(parts, "Native")
+	case repositoryOnly:
+		parts = append(parts, f.repository)
+	}
+
+	if f.isUpgradableOnly {
+		parts = append(parts, "Upgradable")
+	}
+
+	if len(parts) == 0 {
+		return "All"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// needsSyncIndex reports whether the filter compares installed packages
+// with the sync databases.
+func (f packageFilter) needsSyncIndex() bool {
+	return f.origin != anyOrigin || f.isUpgradableOnly
+}
+
+func (f packageFilter) matches(pkg *alpm.Package, dbs *packageDatabases) bool {
+	switch f.reason {
+	case explicitOnly:
+		if pkg.Reason != alpm.ReasonExplicit {
+			return false
+		}
+	case dependenciesOnly:
+		if pkg.Reason != alpm.ReasonDependency {
+			return false
+		}
+	case orphansOnly:
+		if !alpm.IsOrphan(pkg) {
+			return false
+		}
+	}
+
+	if f.needsSyncIndex() && dbs.sync == nil {
+		return false
+	}
+
+	switch f.origin {
+	case foreignOnly, nativeOnly:
+		_, isNative := dbs.sync.Package(pkg.Name)
+		if isNative != (f.origin == nativeOnly) {
+			return false
+		}
+	case repositoryOnly:
+		if dbs.repository(pkg) != f.repository {
+			return false
+		}
+	}
+
+	if f.isUpgradableOnly {
+		if _, exists := dbs.upgradeFor(pkg); !exists {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pacmanOptions are the -Q options which filter the same way, for when
+// the local database can't be read. pacman can't filter by repository.
+func (f packageFilter) pacmanOptions() []string {
+	var opts []string
+
+	switch f.reason {
+	case explicitOnly:
+		opts = append(opts, "e")
+	case dependenciesOnly:
+		opts = append(opts, "d")
+	case orphansOnly:
+		opts = append(opts, "d", "t")
+	}
+
+	switch f.origin {
+	case foreignOnly:
+		opts = append(opts, "m")
+	case nativeOnly:
+		opts = append(opts, "n")
+	}
+
+	if f.isUpgradableOnly {
+		opts = append(opts, "u")
+	}
+
+	return opts
+}
+
+// newFilterDialog lets the user build a filter. Choosing an option that
+// is already set clears it, and the dialog reopens after each change so
+// that filters can be combined.
+func newFilterDialog(filter *packageFilter, repositories []string, onChange func() tea.Cmd) *dialogModel {
+	d := &dialogModel{
+		title:   "Filter",
+		options: []dialogOption{{key: "enter", label: "Done"}},
+	}
+
+	var body strings.Builder
+	addOption := func(key string, label string, isSet bool, toggle func()) {
+		check := "( )"
+		if isSet {
+			check = "(•)"
+		}
+		fmt.Fprintf(&body, "%s  %s %s\n", key, check, label)
+
+		d.options = append(d.options, dialogOption{
+			key: key,
+			action: func() tea.Cmd {
+				toggle()
+				return tea.Batch(onChange(), openDialog(newFilterDialog(filter, repositories, onChange)))
+			},
+		})
+	}
+
+	setReason := func(reason reasonFilter) func() {
+		return func() {
+			if filter.reason == reason {
+				filter.reason = anyReason
+			} else {
+				filter.reason = reason
+			}
+		}
+	}
+
+	setOrigin := func(origin originFilter, repository string) func() {
+		return func() {
+			if filter.origin == origin && filter.repository == repository {
+				filter.origin, filter.repository = anyOrigin, ""
+			} else {
+				filter.origin, filter.repository = origin, repository
+			}
+		}
+	}
+
+	addOption("a", "All", *filter == packageFilter{}, func() { *filter = packageFilter{} })
+	body.WriteString("\n")
+
+	addOption("e", "Explicit", filter.reason == explicitOnly, setReason(explicitOnly))
+	addOption("d", "Dependencies", filter.reason == dependenciesOnly, setReason(dependenciesOnly))
+	addOption("o", "Orphans", filter.reason == orphansOnly, setReason(orphansOnly))
+	body.WriteString("\n")
+
+	addOption("f", "Foreign", filter.origin == foreignOnly, setOrigin(foreignOnly, ""))
+	addOption("n", "Native", filter.origin == nativeOnly, setOrigin(nativeOnly, ""))
+	for i, repository := range repositories[:min(len(repositories), 9)] {
+		isSet := filter.origin == repositoryOnly && filter.repository == repository
+		addOption(strconv.Itoa(i+1), repository, isSet, setOrigin(repositoryOnly, repository))
+	}
+	body.WriteString("\n")
+
+	addOption("u", "Upgradable", filter.isUpgradableOnly, func() { filter.isUpgradableOnly = !filter.isUpgradableOnly })
+
+	d.body = strings.TrimSuffix(body.String(), "\n")
+	return d
+}
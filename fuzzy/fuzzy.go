@@ -0,0 +1,194 @@
+// Package fuzzy scores strings against a search pattern in the same
+// way as fzf's default algorithm: the pattern's characters must appear
+// in order, and matches are ranked higher when the characters are
+// consecutive or start words.
+package fuzzy
+
+import (
+	"unicode"
+)
+
+// Scores and bonuses, as tuned by fzf.
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+
+	// Matching the start of a word is worth as much as half a match.
+	bonusBoundary = scoreMatch / 2
+	bonusNonWord  = scoreMatch / 2
+
+	// "fooBar" and "foo123" have a boundary before the B and the 1,
+	// but it's weaker than one marked by punctuation.
+	bonusCamel123 = bonusBoundary + scoreGapExtension
+
+	// A run of matches should be worth at least as much as a gap
+	// costs, or "foobar" would rank below "f-o-o-b-a-r".
+	bonusConsecutive = -(scoreGapStart + scoreGapExtension)
+
+	// The first character of the pattern counts double.
+	bonusFirstCharMultiplier = 2
+)
+
+type charClass uint8
+
+const (
+	charNonWord charClass = iota
+	charLower
+	charUpper
+	charLetter
+	charNumber
+)
+
+// Match is where and how well a pattern matched.
+type Match struct {
+	Score int
+
+	// The rune offsets of the matched characters.
+	Positions []int
+}
+
+// Find matches pattern against text, ignoring case. An empty pattern
+// matches everything with no score.
+func Find(pattern string, text string) (Match, bool) {
+	patternRunes := []rune(pattern)
+	for i, r := range patternRunes {
+		patternRunes[i] = unicode.ToLower(r)
+	}
+
+	if len(patternRunes) == 0 {
+		return Match{}, true
+	}
+
+	textRunes := []rune(text)
+
+	// Find the first occurrence of the whole pattern...
+	start, end, pidx := -1, -1, 0
+	for i, r := range textRunes {
+		if unicode.ToLower(r) != patternRunes[pidx] {
+			continue
+		}
+
+		if start < 0 {
+			start = i
+		}
+
+		pidx++
+		if pidx == len(patternRunes) {
+			end = i + 1
+			break
+		}
+	}
+
+	if end < 0 {
+		return Match{}, false
+	}
+
+	// ...then work backwards from its end to find the shortest
+	// substring that still contains it.
+	pidx--
+	for i := end - 1; i >= start; i-- {
+		if unicode.ToLower(textRunes[i]) != patternRunes[pidx] {
+			continue
+		}
+
+		pidx--
+		if pidx < 0 {
+			start = i
+			break
+		}
+	}
+
+	return score(textRunes, patternRunes, start, end), true
+}
+
+func score(text []rune, pattern []rune, start int, end int) Match {
+	var match Match
+	pidx, consecutive, firstBonus := 0, 0, 0
+	inGap := false
+
+	prevClass := charNonWord
+	if start > 0 {
+		prevClass = classOf(text[start-1])
+	}
+
+	for i := start; i < end; i++ {
+		r := text[i]
+		class := classOf(r)
+
+		if pidx < len(pattern) && unicode.ToLower(r) == pattern[pidx] {
+			match.Positions = append(match.Positions, i)
+			match.Score += scoreMatch
+
+			bonus := bonusFor(prevClass, class)
+			if consecutive == 0 {
+				firstBonus = bonus
+			} else {
+				// A boundary within a run carries its bonus onwards.
+				if bonus >= bonusBoundary && bonus > firstBonus {
+					firstBonus = bonus
+				}
+				bonus = max(bonus, firstBonus, bonusConsecutive)
+			}
+
+			if pidx == 0 {
+				match.Score += bonus * bonusFirstCharMultiplier
+			} else {
+				match.Score += bonus
+			}
+
+			inGap = false
+			consecutive++
+			pidx++
+		} else {
+			if inGap {
+				match.Score += scoreGapExtension
+			} else {
+				match.Score += scoreGapStart
+			}
+
+			inGap = true
+			consecutive = 0
+			firstBonus = 0
+		}
+
+		prevClass = class
+	}
+
+	return match
+}
+
+func classOf(r rune) charClass {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return charLower
+	case r >= 'A' && r <= 'Z':
+		return charUpper
+	case r >= '0' && r <= '9':
+		return charNumber
+	case unicode.IsLower(r):
+		return charLower
+	case unicode.IsUpper(r):
+		return charUpper
+	case unicode.IsLetter(r):
+		return charLetter
+	case unicode.IsNumber(r):
+		return charNumber
+	default:
+		return charNonWord
+	}
+}
+
+func bonusFor(prevClass charClass, class charClass) int {
+	switch {
+	case prevClass == charNonWord && class != charNonWord:
+		return bonusBoundary
+	case prevClass == charLower && class == charUpper,
+		prevClass != charNumber && class == charNumber:
+		return bonusCamel123
+	case class == charNonWord:
+		return bonusNonWord
+	default:
+		return 0
+	}
+}
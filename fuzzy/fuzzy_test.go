@@ -0,0 +1,141 @@
+package fuzzy
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFindPositions(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		text      string
+		positions []int
+	}{
+		{"vim", "vim", []int{0, 1, 2}},
+		{"fb", "foo-bar", []int{0, 4}},
+		{"gtk", "libgtk-3", []int{3, 4, 5}},
+		// The shortest run containing the pattern is used, rather than
+		// the first characters that would do.
+		{"ab", "a-a-b", []int{2, 4}},
+		{"pyyaml", "python-pyyaml", []int{7, 8, 9, 10, 11, 12}},
+		// Positions count runes, not bytes.
+		{"ñu", "año-ñu", []int{4, 5}},
+		{"fonts", "日本語-fonts", []int{4, 5, 6, 7, 8}},
+	}
+
+	for _, test := range tests {
+		match, ok := Find(test.pattern, test.text)
+		if !ok {
+			t.Errorf("Find(%q, %q) didn't match", test.pattern, test.text)
+			continue
+		}
+		if !slices.Equal(match.Positions, test.positions) {
+			t.Errorf("Find(%q, %q) positions = %v, want %v", test.pattern, test.text, match.Positions, test.positions)
+		}
+	}
+}
+
+func TestFindIgnoresCase(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+	}{
+		{"git", "LibGit2"},
+		{"GIT", "libgit2"},
+		{"Qt", "qt6-BASE"},
+		{"ÄB", "äb"},
+	}
+
+	for _, test := range tests {
+		match, ok := Find(test.pattern, test.text)
+		if !ok {
+			t.Errorf("Find(%q, %q) didn't match", test.pattern, test.text)
+			continue
+		}
+
+		// Case changes bonuses, but never whether or where it matched.
+		if len(match.Positions) != len([]rune(test.pattern)) {
+			t.Errorf("Find(%q, %q) positions = %v", test.pattern, test.text, match.Positions)
+		}
+	}
+}
+
+func TestFindNoMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+	}{
+		{"xyz", "git"},
+		// Characters must appear in order.
+		{"tig", "git"},
+		{"gitt", "git"},
+		{"git", ""},
+	}
+
+	for _, test := range tests {
+		if match, ok := Find(test.pattern, test.text); ok {
+			t.Errorf("Find(%q, %q) = %+v, want no match", test.pattern, test.text, match)
+		}
+	}
+}
+
+func TestFindEmptyPattern(t *testing.T) {
+	match, ok := Find("", "anything")
+	if !ok || match.Score != 0 || len(match.Positions) != 0 {
+		t.Errorf("Find(\"\", ...) = %+v, %v, want an empty match", match, ok)
+	}
+}
+
+// Each pair is in order of how well the pattern should match.
+func TestFindScoreOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		better  string
+		worse   string
+	}{
+		{"prefix over middle", "git", "git-lfs", "legit"},
+		{"word boundary over middle", "lib", "lib32-glibc", "glibc"},
+		{"after a dash over middle", "bar", "foo-bar", "foobar"},
+		{"consecutive over spread out", "foo", "foobar", "f-o-o"},
+		{"consecutive over scattered", "vim", "neovim", "nevxixm"},
+		{"camel case over middle", "gb", "GtkBuilder", "gtkbuilder"},
+		{"shorter gap", "ab", "a-b", "a---b"},
+		{"first character boundary counts double", "fz", "fzf", "afzf"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			better, ok := Find(test.pattern, test.better)
+			if !ok {
+				t.Fatalf("Find(%q, %q) didn't match", test.pattern, test.better)
+			}
+			worse, ok := Find(test.pattern, test.worse)
+			if !ok {
+				t.Fatalf("Find(%q, %q) didn't match", test.pattern, test.worse)
+			}
+
+			if better.Score <= worse.Score {
+				t.Errorf("%q scores %d against %q, but %d against %q",
+					test.pattern, better.Score, test.better, worse.Score, test.worse)
+			}
+		})
+	}
+}
+
+func TestFindScore(t *testing.T) {
+	// f starts the text, so gets a boundary bonus counted double. The
+	// o's carry it on as part of the run.
+	match, _ := Find("foo", "foobar")
+	want := (scoreMatch + bonusBoundary*bonusFirstCharMultiplier) + 2*(scoreMatch+bonusBoundary)
+	if match.Score != want {
+		t.Errorf("score = %d, want %d", match.Score, want)
+	}
+
+	// The gap costs more for its start than for each character after.
+	match, _ = Find("ab", "a--b")
+	want = (scoreMatch + bonusBoundary*bonusFirstCharMultiplier) + scoreGapStart + scoreGapExtension + (scoreMatch + bonusBoundary)
+	if match.Score != want {
+		t.Errorf("score = %d, want %d", match.Score, want)
+	}
+}
@@ -2,7 +2,10 @@ module ptui
 
 go 1.25.4
 
-require github.com/atotto/clipboard v0.1.4 // indirect
+require (
+	github.com/atotto/clipboard v0.1.4
+	github.com/charmbracelet/harmonica v0.2.0 // indirect
+)
 
 require (
 	github.com/aymanbagabas/go-osc52/v2 v2.0.1 // indirect
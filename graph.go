@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"ptui/alpm"
+)
+
+// dependencyGraph is the part of the local database reachable from
+// some packages through their dependencies, in the form it's exported
+// in. Optional dependencies are only followed when they're installed.
+type dependencyGraph struct {
+	Roots        []string          `json:"roots"`
+	Packages     []graphPackage    `json:"packages"`
+	Dependencies []graphDependency `json:"dependencies"`
+}
+
+type graphPackage struct {
+	Name        string `json:"name"`
+	Version     string `json:"version,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	IsInstalled bool   `json:"installed"`
+}
+
+type graphDependency struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// As the package declares it, with any version constraint.
+	Dependency string `json:"dependency"`
+	IsOptional bool   `json:"optional"`
+}
+
+func newDependencyGraph(roots []*alpm.Package, dbs *packageDatabases) *dependencyGraph {
+	graph := &dependencyGraph{
+		Roots:        make([]string, 0, len(roots)),
+		Packages:     []graphPackage{},
+		Dependencies: []graphDependency{},
+	}
+
+	seen := make(map[string]bool)
+	var queue []*alpm.Package
+
+	addPackage := func(name string, pkg *alpm.Package, isInstalled bool) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+
+		node := graphPackage{Name: name, IsInstalled: isInstalled}
+		if isInstalled {
+			node.Version = pkg.Version
+			node.Reason = columnReason.value(pkg, dbs)
+			queue = append(queue, pkg)
+		}
+		graph.Packages = append(graph.Packages, node)
+	}
+
+	for _, pkg := range roots {
+		graph.Roots = append(graph.Roots, pkg.Name)
+		addPackage(pkg.Name, pkg, true)
+	}
+
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+
+		for _, dep := range pkg.Depends {
+			satisfier, isInstalled := dbs.resolveDependency(dep)
+			name := nodeName(dep, satisfier)
+
+			addPackage(name, satisfier, isInstalled)
+			graph.Dependencies = append(graph.Dependencies, graphDependency{From: pkg.Name, To: name, Dependency: dep})
+		}
+
+		for _, opt := range pkg.OptDepends {
+			satisfier, isInstalled := dbs.resolveDependency(opt.Name)
+			if !isInstalled {
+				continue
+			}
+
+			addPackage(satisfier.Name, satisfier, true)
+			graph.Dependencies = append(graph.Dependencies, graphDependency{
+				From:       pkg.Name,
+				To:         satisfier.Name,
+				Dependency: opt.Name,
+				IsOptional: true,
+			})
+		}
+	}
+
+	return graph
+}
+
+// DOT renders the graph for Graphviz. The packages it was built from
+// are drawn in bold, missing dependencies in red and optional ones
+// dashed.
+func (g *dependencyGraph) DOT() string {
+	var builder strings.Builder
+	builder.WriteString("digraph dependencies {\n")
+	builder.WriteString("\trankdir=LR;\n")
+	builder.WriteString("\tnode [shape=box];\n\n")
+
+	for _, pkg := range g.Packages {
+		var attributes []string
+
+		label := pkg.Name
+		if pkg.Version != "" {
+			label += "\n" + pkg.Version
+		}
+		attributes = append(attributes, "label="+strconv.Quote(label))
+
+		switch {
+		case slices.Contains(g.Roots, pkg.Name):
+			attributes = append(attributes, "style=bold")
+		case !pkg.IsInstalled:
+			attributes = append(attributes, "color=red", "fontcolor=red")
+		}
+
+		fmt.Fprintf(&builder, "\t%s [%s];\n", strconv.Quote(pkg.Name), strings.Join(attributes, ", "))
+	}
+
+	builder.WriteString("\n")
+
+	for _, dep := range g.Dependencies {
+		fmt.Fprintf(&builder, "\t%s -> %s", strconv.Quote(dep.From), strconv.Quote(dep.To))
+		if dep.IsOptional {
+			builder.WriteString(" [style=dashed]")
+		}
+		builder.WriteString(";\n")
+	}
+
+	builder.WriteString("}\n")
+	return builder.String()
+}
+
+// Export writes the graph as both DOT and JSON, to files named after
+// path with its extension replaced. It returns the files written.
+func (g *dependencyGraph) Export(path string) ([]string, error) {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	dotPath, jsonPath := base+".dot", base+".json"
+
+	encoded, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(dotPath, []byte(g.DOT()), 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(jsonPath, append(encoded, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+
+	return []string{dotPath, jsonPath}, nil
+}
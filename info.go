@@ -0,0 +1,241 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"ptui/alpm"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// packageInfoView renders a typed package record for the info panels
+// of both tabs. Dependency names are links, which can be stepped
+// through and followed to the package they refer to.
+type packageInfoView struct {
+	pkg        *alpm.Package
+	links      []string
+	linkCursor int
+}
+
+type infoRow struct {
+	key   string
+	value string
+
+	// Rows with links render one link per entry.
+	entries []string
+	targets []string
+}
+
+func (v *packageInfoView) SetPackage(pkg *alpm.Package) {
+	v.pkg = pkg
+	v.links = v.links[:0]
+	v.linkCursor = -1
+
+	if pkg == nil {
+		return
+	}
+
+	for _, row := range infoRows(pkg) {
+		v.links = append(v.links, row.targets...)
+	}
+}
+
+// SetFromLines parses streamed pacman -Qi or -Si output, falling back
+// to showing it raw if no package could be parsed from it.
+func (v *packageInfoView) SetFromLines(lines []string) {
+	pkgs, _ := alpm.ParseInfo(strings.NewReader(strings.Join(lines, "")))
+	if len(pkgs) == 0 {
+		v.SetPackage(nil)
+		return
+	}
+
+	cursor := v.linkCursor
+	v.SetPackage(pkgs[0])
+	if cursor < len(v.links) {
+		v.linkCursor = cursor
+	}
+}
+
+func (v *packageInfoView) NextLink() {
+	if len(v.links) > 0 {
+		v.linkCursor = (v.linkCursor + 1) % len(v.links)
+	}
+}
+
+func (v *packageInfoView) PreviousLink() {
+	if len(v.links) == 0 {
+		return
+	}
+
+	if v.linkCursor <= 0 {
+		v.linkCursor = len(v.links) - 1
+	} else {
+		v.linkCursor--
+	}
+}
+
+func (v *packageInfoView) SelectedLink() (string, bool) {
+	if v.linkCursor < 0 || v.linkCursor >= len(v.links) {
+		return "", false
+	}
+	return v.links[v.linkCursor], true
+}
+
+// Render lays the record out as two columns, wrapping values to width.
+// Without a record, the raw lines are shown instead. The returned line
+// is where the selected link is, or -1 if none is.
+func (v *packageInfoView) Render(width int, rawLines []string) (string, int) {
+	if v.pkg == nil {
+		return strings.Join(rawLines, ""), -1
+	}
+
+	leftColWidth := 18
+	rightColWidth := width - leftColWidth
+	if rightColWidth <= 0 {
+		return "", -1
+	}
+
+	rightStyle := defaultStyle.Width(rightColWidth)
+	keyStyle := defaultStyle.Width(leftColWidth)
+
+	var builder strings.Builder
+	lineCount := 0
+	selectedLine := -1
+	linkIdx := 0
+
+	for _, row := range infoRows(v.pkg) {
+		var value string
+		switch {
+		case row.entries != nil:
+			rendered := make([]string, len(row.entries))
+			for i, entry := range row.entries {
+				if linkIdx == v.linkCursor {
+					entry = selectedStyle.Render(entry)
+					selectedLine = lineCount
+				}
+
+				rendered[i] = entry
+				linkIdx++
+			}
+
+			// Optional dependencies carry a reason, so each gets a line.
+			if row.key == "Optional Deps" {
+				value = rightStyle.Render(strings.Join(rendered, "\n"))
+			} else {
+				value = rightStyle.Render(strings.Join(rendered, "  "))
+			}
+
+		case isUrl(row.value):
+			// Lipgloss's auto-wrapping destroys URLs for most terminals.
+			// Prefer to make the line hard-to-read than useless.
+			value = row.value
+
+		default:
+			value = rightStyle.Render(row.value)
+		}
+
+		rendered := lipgloss.JoinHorizontal(lipgloss.Top, keyStyle.Render(row.key), value)
+		builder.WriteString(rendered + "\n")
+		lineCount += lipgloss.Height(rendered)
+	}
+
+	return builder.String(), selectedLine
+}
+
+// infoRows lays out pkg in the same order as pacman -Qi, or as pacman
+// -Si for packages from a sync database.
+func infoRows(pkg *alpm.Package) []infoRow {
+	isSync := pkg.Repository != ""
+
+	var rows []infoRow
+	field := func(key string, value string) {
+		if value == "" {
+			value = "None"
+		}
+		rows = append(rows, infoRow{key: key, value: value})
+	}
+
+	list := func(key string, values []string) {
+		field(key, strings.Join(values, "  "))
+	}
+
+	links := func(key string, entries []string, targets []string) {
+		if len(entries) == 0 {
+			field(key, "")
+			return
+		}
+		rows = append(rows, infoRow{key: key, entries: entries, targets: targets})
+	}
+
+	names := func(deps []string) []string {
+		targets := make([]string, len(deps))
+		for i, dep := range deps {
+			targets[i] = alpm.DependName(dep)
+		}
+		return targets
+	}
+
+	if isSync {
+		field("Repository", pkg.Repository)
+	}
+
+	field("Name", pkg.Name)
+	field("Version", pkg.Version)
+	field("Description", pkg.Description)
+	field("Architecture", pkg.Arch)
+	field("URL", pkg.URL)
+	list("Licenses", pkg.Licenses)
+	list("Groups", pkg.Groups)
+	list("Provides", pkg.Provides)
+	links("Depends On", pkg.Depends, names(pkg.Depends))
+
+	var optEntries, optTargets []string
+	for _, opt := range pkg.OptDepends {
+		entry := opt.Name
+		if opt.Reason != "" {
+			entry += ": " + opt.Reason
+		}
+		if opt.IsInstalled {
+			entry += " [installed]"
+		}
+
+		optEntries = append(optEntries, entry)
+		optTargets = append(optTargets, alpm.DependName(opt.Name))
+	}
+	links("Optional Deps", optEntries, optTargets)
+
+	if !isSync {
+		links("Required By", pkg.RequiredBy, slices.Clone(pkg.RequiredBy))
+		links("Optional For", pkg.OptionalFor, slices.Clone(pkg.OptionalFor))
+	}
+
+	list("Conflicts With", pkg.Conflicts)
+	list("Replaces", pkg.Replaces)
+
+	if isSync {
+		field("Download Size", formatSize(pkg.DownloadSize))
+	}
+
+	field("Installed Size", formatSize(pkg.InstalledSize))
+	field("Packager", pkg.Packager)
+	field("Build Date", formatDate(pkg.BuildDate))
+
+	if isSync {
+		list("Validated By", pkg.Validation)
+		return rows
+	}
+
+	field("Install Date", formatDate(pkg.InstallDate))
+	field("Install Reason", pkg.Reason.String())
+
+	if pkg.HasScript {
+		field("Install Script", "Yes")
+	} else {
+		field("Install Script", "No")
+	}
+
+	list("Validated By", pkg.Validation)
+
+	return rows
+}
@@ -7,10 +7,13 @@ import (
 	"math"
 	"slices"
 	"strings"
+	"time"
 
+	"ptui/alpm"
 	cmd "ptui/command"
 	"ptui/types"
 
+	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
@@ -21,6 +24,16 @@ const NUM_COLUMNS = 2
 
 type installedInitMsg struct{} // Indicate tab setup I/O
 
+// infoPanel is what the panel beside the package list shows.
+type infoPanel uint8
+
+const (
+	infoPanelDetails infoPanel = iota
+	infoPanelDependencies
+	infoPanelDependents
+	infoPanelFiles
+)
+
 type installedModel struct {
 	title string
 
@@ -31,17 +44,45 @@ type installedModel struct {
 
 	packageLines        []string
 	visiblePackageLines []int
-	infoLines           []string
+
+	// The characters the search matched in each visible line.
+	searchMode    searchMode
+	searchMatches [][]int
+
+	infoLines []string
+	info      packageInfoView
+	infoPanel infoPanel
+
+	dependencies dependencyTree
+	dependents   dependencyTree
+
+	// The file list has its own search, which takes over the search
+	// hotkeys while the list is shown.
+	files           fileTree
+	fileSearchInput textinput.Model
+	fileLines       []string
+	filesPkgName    string
+
+	// When the local database can be read directly, pacman is only
+	// needed for transactions.
+	dbs      *packageDatabases
+	sortMode sortMode
+	marks    markSet
+	table    packageTable
+
+	isSortReversed bool
 
 	fullHeight int
 	listCursor int
 	listCmdId  int
 	infoCmdId  int
+	filesCmdId int
+
+	hasViewportDimensions  bool
+	isViewingHotkeyPanel   bool
+	isFinishedReadingLines bool
 
-	hasViewportDimensions      bool
-	isViewingHotkeyPanel       bool
-	isFinishedReadingLines     bool
-	isFilteringExplicitInstall bool
+	filter packageFilter
 
 	cmds []tea.Cmd
 
@@ -53,12 +94,16 @@ type installedModel struct {
 	doneRoutes  types.MessageRouter[*installedModel, cmd.CommandDoneMsg]
 }
 
-func initialInstalledModel() *installedModel {
+func initialInstalledModel(dbs *packageDatabases) *installedModel {
 	model := installedModel{
 		title:               "Installed",
+		dbs:                 dbs,
 		packageLines:        make([]string, 0, 2048),
 		visiblePackageLines: make([]int, 0, 2048),
 		infoLines:           make([]string, 0, 100),
+		marks:               make(markSet),
+		table:               newPackageTable(0, columnVersion),
+		dependents:          dependencyTree{isReversed: true},
 
 		listCursor:             0,
 		hasViewportDimensions:  false,
@@ -83,6 +128,11 @@ func initialInstalledModel() *installedModel {
 				m.infoLines = m.infoLines[:0]
 				return nil
 			},
+			PackageFiles: func(m *installedModel, msg cmd.CommandStartMsg) tea.Cmd {
+				m.filesCmdId = msg.CommandId
+				m.fileLines = m.fileLines[:0]
+				return nil
+			},
 		},
 
 		chunkRoutes: types.MessageRouter[*installedModel, cmd.CommandChunkMsg]{
@@ -101,14 +151,24 @@ func initialInstalledModel() *installedModel {
 				}
 
 				m.infoLines = append(m.infoLines, msg.Lines...)
+				m.info.SetFromLines(m.infoLines)
 				m.buildInfoList()
 				return nil
 			},
+			PackageFiles: func(m *installedModel, msg cmd.CommandChunkMsg) tea.Cmd {
+				if msg.CommandId != m.filesCmdId || msg.IsError {
+					return nil
+				}
+
+				m.fileLines = append(m.fileLines, msg.Lines...)
+				return nil
+			},
 		},
 
 		doneRoutes: types.MessageRouter[*installedModel, cmd.CommandDoneMsg]{
 			PackageList: func(m *installedModel, msg cmd.CommandDoneMsg) tea.Cmd {
-				if msg.CommandId == m.listCmdId && msg.Err != nil {
+				// pacman exits with an error when nothing matches a filter.
+				if msg.CommandId == m.listCmdId && msg.Err != nil && len(m.packageLines) > 0 {
 					m.packageLines = append(m.packageLines, fmt.Sprintf("\n%s\n", msg.Err))
 				}
 
@@ -122,10 +182,18 @@ func initialInstalledModel() *installedModel {
 			PackageInfo: func(m *installedModel, msg cmd.CommandDoneMsg) tea.Cmd {
 				if msg.CommandId == m.infoCmdId && msg.Err != nil {
 					m.infoLines = append(m.infoLines, fmt.Sprintf("\n%s\n", msg.Err))
+					m.info.SetPackage(nil)
 					m.buildInfoList()
 				}
 				return nil
 			},
+			PackageFiles: func(m *installedModel, msg cmd.CommandDoneMsg) tea.Cmd {
+				if msg.CommandId == m.filesCmdId {
+					m.files.SetFilesFromLines(m.filesPkgName, m.fileLines)
+					m.buildFileList()
+				}
+				return nil
+			},
 		},
 	}
 
@@ -133,8 +201,30 @@ func initialInstalledModel() *installedModel {
 	model.createHotkey("A", "A", "Upgrade All", model.upgradeAll)
 	model.createHotkey("R", "R", "Remove Selected", model.removeSelected)
 	model.createHotkey("E", "E", "Toggle Explicit", model.toggleExplicitFilter)
+	model.createHotkey("F", "F", "Filter", model.chooseFilter)
 	model.createHotkey("H", "H", "Toggle Hotkeys", model.toggleHotkeys)
 	model.createHotkey("U", "U", "Upgrade Selected", model.upgradeSelected)
+	model.createHotkey("X", "X", "Cancel Transaction", cancelTransaction)
+	model.createHotkey("]", "]", "Next Dependency", model.nextLink)
+	model.createHotkey("[", "[", "Previous Dependency", model.previousLink)
+	model.createHotkey("G", "G", "Go To Dependency", model.followLink)
+	model.createHotkey("S", "S", "Cycle Sort", model.cycleSort)
+	model.createHotkey(" ", "Space", "Toggle Mark", model.toggleMark)
+	model.createHotkey("M", "M", "Mark All Visible", model.markAllVisible)
+	model.createHotkey("V", "V", "Invert Marks", model.invertMarks)
+	model.createHotkey("C", "C", "Clear Marks", model.clearMarks)
+	model.createHotkey("ctrl+t", "Ctrl+T", "Cycle Search Mode", model.cycleSearchMode)
+	model.createHotkey("L", "L", "Choose Columns", model.chooseColumns)
+	model.createHotkey("<", "<", "Sort By Previous Column", model.sortByPreviousColumn)
+	model.createHotkey(">", ">", "Sort By Next Column", model.sortByNextColumn)
+	model.createHotkey("~", "~", "Reverse Sort", model.reverseSort)
+	model.createHotkey("T", "T", "Toggle Dependency Tree", model.toggleDependencyTree)
+	model.createHotkey("D", "D", "Toggle Dependents Tree", model.toggleDependentsTree)
+	model.createHotkey("W", "W", "Export Dependency Graph", model.exportGraph)
+	model.createHotkey("B", "B", "Toggle File List", model.toggleFileList)
+	model.createHotkey("Y", "Y", "Copy Path", model.copyPath)
+	model.createHotkey("O", "O", "Toggle Tree Node", model.toggleTreeNode)
+	model.createHotkey("*", "*", "Expand All Dependencies", model.expandAllTreeNodes)
 
 	slices.SortFunc(model.hotkeysOrdered, func(a, b string) int {
 		hotkeyA := model.hotkeys[a]
@@ -162,6 +252,29 @@ func (m *installedModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case installedInitMsg:
 		m.cmds = append(m.cmds, m.getInstalledPackages())
+		if m.dbs.needsSyncIndex() {
+			m.cmds = append(m.cmds, loadSyncIndex)
+		}
+
+	case localDbLoadedMsg:
+		if msg.err != nil || m.isFilteringWithPacman() {
+			m.cmds = append(m.cmds, m.getInstalledPackages())
+			break
+		}
+
+		m.listLocalPackages()
+
+	case syncIndexLoadedMsg:
+		// Upgradable packages can only be flagged, and some filters
+		// applied, once it's loaded.
+		if m.filter.needsSyncIndex() {
+			m.cmds = append(m.cmds, m.getInstalledPackages())
+		}
+		m.buildPackageList()
+
+		// Missing dependencies can be looked up now too.
+		m.dependencies.Build(m.dbs)
+		m.buildInfoList()
 
 	case cmd.CommandStartMsg:
 		handler, exists := m.startRoutes[msg.Target]
@@ -197,14 +310,21 @@ func (m *installedModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.infoViewport = viewport.New(rw, msg.Height+1)
 
 			m.searchInput = textinput.New()
+			m.searchInput.Prompt = m.searchMode.prompt()
 			m.searchInput.Width = lw
 
+			m.fileSearchInput = textinput.New()
+			m.fileSearchInput.Prompt = m.searchMode.prompt()
+			m.table.SetWidth(lw)
+
 			m.hasViewportDimensions = true
 		} else {
 			m.hotkeyViewport.Width = lw
 			m.hotkeyViewport.Height = len(m.hotkeys)
 
 			m.listViewport.Width = lw
+			m.table.SetWidth(lw)
+			m.buildPackageList()
 
 			if m.isViewingHotkeyPanel {
 				m.listViewport.Height = msg.Height - len(m.hotkeys) - 1
@@ -222,6 +342,17 @@ func (m *installedModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		handleHotkeyAndSearch(m, msg)
 
+		// While searching files, the cursor keys move through them.
+		if m.infoPanel == infoPanelFiles && m.fileSearchInput.Focused() {
+			switch msg.String() {
+			case "up":
+				m.previousLink()
+			case "down":
+				m.nextLink()
+			}
+			break
+		}
+
 		switch msg.String() {
 		case "up", "k":
 			if m.listCursor > 0 {
@@ -253,7 +384,7 @@ func (m *installedModel) View() string {
 
 	packageListViewport := m.listViewport.View()
 
-	var packageListTopRow string
+	packageListTopRow := m.table.Header(m.sortMode, m.isSortReversed)
 	if m.searchInput.Focused() {
 		packageListTopRow = defaultStyle.Render(m.searchInput.View())
 		packageListViewport = reducedEmphasisStyle.Render(packageListViewport)
@@ -270,16 +401,16 @@ func (m *installedModel) View() string {
 	packageListViewport = lipgloss.JoinHorizontal(lipgloss.Left, packageListViewport, packageListScrollbar)
 	packageListPanel := lipgloss.JoinVertical(lipgloss.Left, packageListTopRow, packageListViewport)
 
-	var filterMode string
-	if m.isFilteringExplicitInstall {
-		filterMode = "Explicit"
-	} else {
-		filterMode = "All"
-	}
-
 	var cursorPositionText string
 	if len(m.visiblePackageLines) > 0 {
-		cursorPositionText = fmt.Sprintf(" %d of %d (%s)", m.listCursor+1, len(m.visiblePackageLines), filterMode)
+		cursorPositionText = fmt.Sprintf(
+			" %d of %d (%s) %s%s",
+			m.listCursor+1,
+			len(m.visiblePackageLines),
+			m.filter,
+			sortBorderText(m.sortMode, m.isSortReversed),
+			m.marks.borderText(),
+		)
 	} else {
 		cursorPositionText = " No results "
 	}
@@ -308,11 +439,87 @@ func (m *installedModel) toggleExplicitFilter() tea.Cmd {
 		return nil
 	}
 
-	m.isFilteringExplicitInstall = !m.isFilteringExplicitInstall
+	if m.filter.reason == explicitOnly {
+		m.filter.reason = anyReason
+	} else {
+		m.filter.reason = explicitOnly
+	}
 
 	return m.getInstalledPackages()
 }
 
+func (m *installedModel) chooseFilter() tea.Cmd {
+	if m.searchInput.Focused() {
+		return nil
+	}
+
+	// pacman can't filter by repository, so they're only offered when
+	// the databases can be read.
+	var repositories []string
+	if m.dbs.local != nil && m.dbs.sync != nil {
+		repositories = m.dbs.sync.Repos
+	}
+
+	return openDialog(newFilterDialog(&m.filter, repositories, m.getInstalledPackages))
+}
+
+// sortBy reorders the list, which needs the versions and other
+// details only the local database provides.
+func (m *installedModel) sortBy(mode sortMode, isReversed bool) tea.Cmd {
+	if m.searchInput.Focused() {
+		return nil
+	}
+
+	if m.dbs.local == nil {
+		return setStatus("Sorting needs the local database")
+	}
+
+	m.sortMode, m.isSortReversed = mode, isReversed
+	m.listLocalPackages()
+
+	return setStatus(sortStatus(m.sortMode, m.isSortReversed))
+}
+
+// installedSortModes are the modes Cycle Sort steps through. Upgrade
+// dates are only known if pacman's log can be read.
+var installedSortModes = []sortMode{
+	sortByName,
+	sortByVersion,
+	sortBySize,
+	sortByInstallDate,
+	sortByLastUpgrade,
+	sortByDependents,
+}
+
+func (m *installedModel) cycleSort() tea.Cmd {
+	mode := nextSortMode(m.sortMode, installedSortModes)
+	if mode == sortByLastUpgrade && m.dbs.log == nil {
+		mode = nextSortMode(mode, installedSortModes)
+	}
+
+	return m.sortBy(mode, false)
+}
+
+func (m *installedModel) sortByPreviousColumn() tea.Cmd {
+	return m.sortBy(m.table.adjacentSortMode(m.sortMode, -1), false)
+}
+
+func (m *installedModel) sortByNextColumn() tea.Cmd {
+	return m.sortBy(m.table.adjacentSortMode(m.sortMode, 1), false)
+}
+
+func (m *installedModel) reverseSort() tea.Cmd {
+	return m.sortBy(m.sortMode, !m.isSortReversed)
+}
+
+func (m *installedModel) chooseColumns() tea.Cmd {
+	if m.searchInput.Focused() {
+		return nil
+	}
+
+	return openDialog(newColumnDialog(&m.table, m.buildPackageList))
+}
+
 func (m *installedModel) toggleHotkeys() tea.Cmd {
 	if m.searchInput.Focused() {
 		return nil
@@ -332,6 +539,17 @@ func (m *installedModel) toggleHotkeys() tea.Cmd {
 }
 
 func (m *installedModel) toggleSearch() tea.Cmd {
+	if m.infoPanel == infoPanelFiles {
+		if m.fileSearchInput.Focused() {
+			m.fileSearchInput.Blur()
+		} else {
+			m.fileSearchInput.Focus()
+		}
+
+		m.buildInfoList()
+		return nil
+	}
+
 	if m.searchInput.Focused() {
 		m.searchInput.Blur()
 	} else {
@@ -342,68 +560,345 @@ func (m *installedModel) toggleSearch() tea.Cmd {
 	return nil
 }
 
+func (m *installedModel) cycleSearchMode() tea.Cmd {
+	m.searchMode = nextSearchMode(m.searchMode)
+	m.searchInput.Prompt = m.searchMode.prompt()
+	m.fileSearchInput.Prompt = m.searchMode.prompt()
+	m.listCursor = 0
+	m.buildPackageList()
+	m.buildFileList()
+
+	return setStatus(fmt.Sprintf("%s search", m.searchMode))
+}
+
 func (m *installedModel) buildPackageList() {
 	m.visiblePackageLines = m.visiblePackageLines[:0]
-	searchText := m.searchInput.Value()
-	for i, line := range m.packageLines {
-		if matchesSearch(line, searchText) {
-			m.visiblePackageLines = append(m.visiblePackageLines, i)
-		}
+	m.searchMatches = m.searchMatches[:0]
+
+	matches, err := searchLines(m.packageLines, m.searchInput.Value(), m.searchMode)
+	for _, match := range matches {
+		m.visiblePackageLines = append(m.visiblePackageLines, match.index)
+		m.searchMatches = append(m.searchMatches, match.positions)
 	}
 
 	if m.listCursor >= len(m.visiblePackageLines) {
 		m.listCursor = 0
 	}
 
+	if err != nil {
+		m.listViewport.SetContent(fmt.Sprintf("Invalid regular expression: %s", err))
+		return
+	}
+
 	var builder strings.Builder
 	for i, lineIdx := range m.visiblePackageLines {
 		name, _, _ := strings.Cut(m.packageLines[lineIdx], "\n")
-		if m.listCursor == i {
-			builder.WriteString(selectedStyle.Render(name) + "\n")
-		} else {
-			builder.WriteString(m.packageLines[lineIdx])
-		}
+		pkg, _ := m.dbs.installedPackage(name)
+
+		builder.WriteString(m.table.Row(
+			name,
+			m.searchMatches[i],
+			m.marks.prefix(name),
+			m.dbs.upgradeMarker(name),
+			m.listCursor == i,
+			pkg,
+			m.dbs,
+		))
+		builder.WriteString("\n")
 	}
 
 	m.listViewport.SetContent(builder.String())
 }
 
 func (m *installedModel) buildInfoList() {
-	var builder strings.Builder
-	maxWidth := m.infoViewport.Width
+	var content string
+	var selectedLine int
+
+	switch tree := m.activeTree(); {
+	case m.infoPanel == infoPanelFiles:
+		header := reducedEmphasisStyle.Render(m.files.Summary())
+		if m.fileSearchInput.Focused() {
+			header = m.fileSearchInput.View()
+		}
+		content, selectedLine = m.files.Render(m.infoViewport.Width, header)
+	case tree != nil:
+		content, selectedLine = tree.Render(m.infoViewport.Width)
+	default:
+		content, selectedLine = m.info.Render(m.infoViewport.Width, m.infoLines)
+	}
 
-	leftColWidth := 18
-	rightColWidth := maxWidth - leftColWidth
-	rightStyle := defaultStyle.Width(rightColWidth)
+	m.infoViewport.SetContent(content)
 
-	if rightColWidth <= 0 {
-		return
+	if selectedLine >= 0 {
+		scrollIntoView(&m.infoViewport, selectedLine)
 	}
+}
 
-	for _, line := range m.infoLines {
-		// Lipgloss's auto-wrapping destroys URLs for most terminals.
-		// Prefer to make the line hard-to-read than useless.
+// activeTree is the tree shown in the info panel, if one is.
+func (m *installedModel) activeTree() *dependencyTree {
+	switch m.infoPanel {
+	case infoPanelDependencies:
+		return &m.dependencies
+	case infoPanelDependents:
+		return &m.dependents
+	default:
+		return nil
+	}
+}
 
-		if isUrl(line) {
-			builder.WriteString(line)
-			continue
+// nextLink steps through the dependencies in the info panel, or the
+// nodes of a tree or the file list when one is shown.
+func (m *installedModel) nextLink() tea.Cmd {
+	if m.infoPanel == infoPanelFiles {
+		m.files.Next()
+	} else if tree := m.activeTree(); tree != nil {
+		tree.Next()
+	} else {
+		m.info.NextLink()
+	}
+
+	m.buildInfoList()
+	return nil
+}
+
+func (m *installedModel) previousLink() tea.Cmd {
+	if m.infoPanel == infoPanelFiles {
+		m.files.Previous()
+	} else if tree := m.activeTree(); tree != nil {
+		tree.Previous()
+	} else {
+		m.info.PreviousLink()
+	}
+
+	m.buildInfoList()
+	return nil
+}
+
+func (m *installedModel) toggleDependencyTree() tea.Cmd {
+	return m.toggleInfoPanel(infoPanelDependencies)
+}
+
+func (m *installedModel) toggleDependentsTree() tea.Cmd {
+	return m.toggleInfoPanel(infoPanelDependents)
+}
+
+func (m *installedModel) toggleFileList() tea.Cmd {
+	return m.toggleInfoPanel(infoPanelFiles)
+}
+
+// toggleInfoPanel swaps the package details for one of the trees or the
+// file list, or back again. The trees are built from the databases.
+func (m *installedModel) toggleInfoPanel(panel infoPanel) tea.Cmd {
+	if m.searchInput.Focused() {
+		return nil
+	}
+
+	m.fileSearchInput.Blur()
+
+	if m.infoPanel == panel {
+		m.infoPanel = infoPanelDetails
+		m.buildInfoList()
+		return nil
+	}
+
+	if panel != infoPanelFiles && m.dbs.local == nil {
+		return setStatus("Dependency trees need the local database")
+	}
+
+	m.infoPanel = panel
+	return m.getPackageInfo()
+}
+
+func (m *installedModel) toggleTreeNode() tea.Cmd {
+	if m.infoPanel == infoPanelFiles {
+		m.files.Toggle()
+		m.buildFileList()
+		return nil
+	}
+
+	tree := m.activeTree()
+	if tree == nil {
+		return nil
+	}
+
+	tree.Toggle(m.dbs)
+	m.buildInfoList()
+	return nil
+}
+
+func (m *installedModel) expandAllTreeNodes() tea.Cmd {
+	if m.infoPanel == infoPanelFiles {
+		m.files.ExpandAll()
+		m.buildFileList()
+		return nil
+	}
+
+	tree := m.activeTree()
+	if tree == nil {
+		return nil
+	}
+
+	tree.ExpandAll(m.dbs)
+	m.buildInfoList()
+	return nil
+}
+
+// followLink moves the list cursor to the package satisfying the
+// selected dependency, or the selected node of a tree.
+func (m *installedModel) followLink() tea.Cmd {
+	if tree := m.activeTree(); tree != nil {
+		node, selected := tree.Selected()
+		if !selected {
+			return nil
 		}
 
-		halves := strings.Split(line, " : ")
-		if len(halves) != 2 {
-			builder.WriteString(line)
-			continue
+		if !node.isInstalled {
+			return setStatus(fmt.Sprintf("%s is not installed", nodeName(node.dep, node.pkg)))
+		}
+		return m.selectPackage(node.pkg.Name)
+	}
+
+	target, selected := m.info.SelectedLink()
+	if !selected {
+		return nil
+	}
+
+	if m.dbs.local != nil {
+		satisfiers := m.dbs.local.Satisfiers(target)
+		if len(satisfiers) == 0 {
+			return setStatus(fmt.Sprintf("%s is not installed", target))
+		}
+		target = satisfiers[0].Name
+	}
+
+	return m.selectPackage(target)
+}
+
+func (m *installedModel) buildFileList() {
+	m.files.Build(m.fileSearchInput.Value(), m.searchMode)
+	m.buildInfoList()
+}
+
+func (m *installedModel) copyPath() tea.Cmd {
+	if m.infoPanel != infoPanelFiles {
+		return setStatus("Paths can be copied from the file list")
+	}
+
+	path, selected := m.files.SelectedPath()
+	if !selected {
+		return nil
+	}
+
+	if err := clipboard.WriteAll(path); err != nil {
+		return setStatus(fmt.Sprintf("Could not copy path: %s", err))
+	}
+	return setStatus(fmt.Sprintf("Copied %s", path))
+}
+
+// selectPackage moves the list cursor to an installed package, clearing
+// anything that would hide it.
+func (m *installedModel) selectPackage(target string) tea.Cmd {
+	if pkg, installed := m.dbs.installedPackage(target); installed && !m.filter.matches(pkg, m.dbs) {
+		m.filter = packageFilter{}
+		m.listLocalPackages()
+	}
+
+	m.searchInput.SetValue("")
+	m.buildPackageList()
+
+	i := slices.IndexFunc(m.visiblePackageLines, func(lineIdx int) bool {
+		return m.packageLines[lineIdx] == target+"\n"
+	})
+	if i < 0 {
+		return setStatus(fmt.Sprintf("%s is not in the list", target))
+	}
+
+	m.listCursor = i
+	m.buildPackageList()
+	scrollIntoView(&m.listViewport, m.listCursor)
+
+	return m.getPackageInfo()
+}
+
+// exportGraph asks which packages to export the dependency graph of,
+// then where to write it.
+func (m *installedModel) exportGraph() tea.Cmd {
+	if m.searchInput.Focused() {
+		return nil
+	}
+
+	if m.dbs.local == nil {
+		return setStatus("Exporting the dependency graph needs the local database")
+	}
+
+	packages := func(names []string) []*alpm.Package {
+		var pkgs []*alpm.Package
+		for _, name := range names {
+			if pkg, exists := m.dbs.local.Package(name); exists {
+				pkgs = append(pkgs, pkg)
+			}
 		}
+		return pkgs
+	}
+
+	d := &dialogModel{title: "Export dependency graph"}
+	var body strings.Builder
+
+	if name, err := m.getSelectedPackageName(); err == nil {
+		fmt.Fprintf(&body, "s  The selected package, %s\n", name)
+		d.options = append(d.options, dialogOption{
+			key:    "s",
+			action: func() tea.Cmd { return m.exportGraphOf(name, packages([]string{name})) },
+		})
+	}
+
+	if len(m.marks) > 0 {
+		fmt.Fprintf(&body, "m  The marked packages (%d)\n", len(m.marks))
+		d.options = append(d.options, dialogOption{
+			key:    "m",
+			action: func() tea.Cmd { return m.exportGraphOf("marked", packages(m.marks.Names())) },
+		})
+	}
+
+	body.WriteString("e  Every explicitly installed package\n")
+	d.options = append(d.options, dialogOption{
+		key: "e",
+		action: func() tea.Cmd {
+			var explicit []*alpm.Package
+			for _, pkg := range m.dbs.local.Packages {
+				if pkg.Reason == alpm.ReasonExplicit {
+					explicit = append(explicit, pkg)
+				}
+			}
+			return m.exportGraphOf("explicit", explicit)
+		},
+	})
+
+	body.WriteString("\nThe graph is written as both DOT and JSON.")
+	d.body = body.String()
 
-		key := halves[0]
-		value := strings.TrimSpace(halves[1])
+	return openDialog(d)
+}
 
-		valueRendered := rightStyle.Render(value)
-		row := lipgloss.JoinHorizontal(lipgloss.Top, key, valueRendered)
-		builder.WriteString(row + "\n")
+func (m *installedModel) exportGraphOf(scope string, roots []*alpm.Package) tea.Cmd {
+	defaultPath := fmt.Sprintf("ptui-deps-%s-%s", scope, time.Now().Format("20060102-150405"))
+
+	export := func(path string) tea.Cmd {
+		if path = strings.TrimSpace(path); path == "" {
+			path = defaultPath
+		}
+
+		written, err := newDependencyGraph(roots, m.dbs).Export(path)
+		if err != nil {
+			return setStatus(fmt.Sprintf("Could not export dependency graph: %s", err))
+		}
+		return setStatus(fmt.Sprintf("Exported dependency graph to %s", strings.Join(written, " and ")))
 	}
 
-	m.infoViewport.SetContent(builder.String())
+	dialog := newInputDialog("Export dependency graph", "Write the graph to, without an extension:", export, nil)
+	dialog.input.Placeholder = defaultPath
+
+	return openDialog(dialog)
 }
 
 func (m *installedModel) Hotkeys() map[string]types.HotkeyBinding {
@@ -411,6 +906,9 @@ func (m *installedModel) Hotkeys() map[string]types.HotkeyBinding {
 }
 
 func (m *installedModel) SearchInput() *textinput.Model {
+	if m.infoPanel == infoPanelFiles {
+		return &m.fileSearchInput
+	}
 	return &m.searchInput
 }
 
@@ -419,6 +917,12 @@ func (m *installedModel) AddCommand(cmd tea.Cmd) {
 }
 
 func (m *installedModel) ResetCursor() {
+	if m.infoPanel == infoPanelFiles {
+		m.files.cursor = 0
+		m.buildFileList()
+		return
+	}
+
 	m.listCursor = 0
 	m.buildPackageList()
 
@@ -426,6 +930,9 @@ func (m *installedModel) ResetCursor() {
 		m.cmds = append(m.cmds, m.getPackageInfo())
 	} else {
 		m.infoLines = m.infoLines[:0]
+		m.info.SetPackage(nil)
+		m.dependencies.SetRoot(nil, m.dbs)
+		m.dependents.SetRoot(nil, m.dbs)
 		m.infoViewport.SetContent("")
 	}
 }
@@ -435,16 +942,48 @@ func (m *installedModel) getInstalledPackages() tea.Cmd {
 		return nil
 	}
 
-	cmd := cmd.NewCommand().
+	if !m.dbs.isLocalUnavailable && !m.isFilteringWithPacman() {
+		return loadLocalDb
+	}
+
+	return cmd.NewCommand().
 		Operation("Q").
 		Options("q").
-		Target(PackageList)
+		Options(m.filter.pacmanOptions()...).
+		Target(PackageList).
+		Run()
+}
+
+// isFilteringWithPacman reports whether the filter needs the sync
+// databases when they can't be read, leaving pacman to apply it.
+func (m *installedModel) isFilteringWithPacman() bool {
+	return m.filter.needsSyncIndex() && m.dbs.isSyncUnavailable
+}
 
-	if m.isFilteringExplicitInstall {
-		cmd.Options("e")
+// listLocalPackages fills the package list from the local database in
+// the same form pacman -Qq would have streamed it.
+func (m *installedModel) listLocalPackages() {
+	var pkgs []*alpm.Package
+	for _, pkg := range m.dbs.local.Packages {
+		if !m.filter.matches(pkg, m.dbs) {
+			continue
+		}
+		pkgs = append(pkgs, pkg)
 	}
 
-	return cmd.Run()
+	m.dbs.sortPackages(pkgs, m.sortMode, m.isSortReversed)
+
+	m.packageLines = m.packageLines[:0]
+	for _, pkg := range pkgs {
+		m.packageLines = append(m.packageLines, pkg.Name+"\n")
+	}
+
+	m.isFinishedReadingLines = true
+	m.buildPackageList()
+
+	if !m.searchInput.Focused() && len(m.visiblePackageLines) > 0 {
+		m.cmds = append(m.cmds, m.getPackageInfo())
+	}
 }
 
 func (m *installedModel) getPackageInfo() tea.Cmd {
@@ -453,26 +992,61 @@ func (m *installedModel) getPackageInfo() tea.Cmd {
 		return nil
 	}
 
+	var filesCmd tea.Cmd
+	if m.infoPanel == infoPanelFiles {
+		filesCmd = m.getPackageFiles(name)
+	}
+
+	if m.dbs.local != nil {
+		if pkg, exists := m.dbs.local.Package(name); exists {
+			m.info.SetPackage(pkg)
+			m.dependencies.SetRoot(pkg, m.dbs)
+			m.dependents.SetRoot(pkg, m.dbs)
+			m.buildInfoList()
+			return filesCmd
+		}
+	}
+
+	return tea.Batch(
+		cmd.NewCommand().
+			Operation("Q").
+			Options("i").
+			Arguments(name).
+			Target(PackageInfo).
+			Run(),
+		filesCmd,
+	)
+}
+
+// getPackageFiles lists a package's files, reading them and their sizes
+// from the local database where it can.
+func (m *installedModel) getPackageFiles(name string) tea.Cmd {
+	if pkg, installed := m.dbs.installedPackage(name); installed {
+		if pkg.Files != nil || m.dbs.local.LoadFiles(pkg) == nil {
+			m.files.SetFiles(name, pkg.Files, true)
+			m.buildFileList()
+			return nil
+		}
+	}
+
+	m.filesPkgName = name
+
 	return cmd.NewCommand().
 		Operation("Q").
-		Options("i").
+		Options("l").
 		Arguments(name).
-		Target(PackageInfo).
+		Target(PackageFiles).
 		Run()
 }
 
+// upgradeAll hands over to the Updates tab, so that the upgrade can be
+// reviewed before it runs.
 func (m *installedModel) upgradeAll() tea.Cmd {
 	if m.searchInput.Focused() {
 		return nil
 	}
 
-	return cmd.NewCommand().
-		Operation("S").
-		Options("y", "u").
-		Arguments("--noconfirm").
-		Target(Background).
-		Callback(m.getInstalledPackages).
-		Run()
+	return selectTab("Updates")
 }
 
 func (m *installedModel) upgradeSelected() tea.Cmd {
@@ -480,17 +1054,18 @@ func (m *installedModel) upgradeSelected() tea.Cmd {
 		return nil
 	}
 
-	name, err := m.getSelectedPackageName()
+	targets, err := m.transactionTargets()
 	if err != nil {
 		return nil
 	}
 
-	return cmd.NewCommand().
-		Operation("S").
-		Options("y", "u").
-		Arguments(name, "--noconfirm").
-		Target(Background).
-		Run()
+	return previewTransaction(transaction{
+		kind:      upgradeTransaction,
+		targets:   targets,
+		refresh:   true,
+		callback:  func() tea.Cmd { return loadLocalDb },
+		onConfirm: func() { m.clearMarks() },
+	})
 }
 
 func (m *installedModel) removeSelected() tea.Cmd {
@@ -498,17 +1073,17 @@ func (m *installedModel) removeSelected() tea.Cmd {
 		return nil
 	}
 
-	name, err := m.getSelectedPackageName()
+	targets, err := m.transactionTargets()
 	if err != nil {
 		return nil
 	}
 
-	return cmd.NewCommand().
-		Operation("R").
-		Options("s").
-		Arguments(name, "--noconfirm").
-		Target(Background).
-		Run()
+	return previewTransaction(transaction{
+		kind:      removeTransaction,
+		targets:   targets,
+		callback:  func() tea.Cmd { return loadLocalDb },
+		onConfirm: func() { m.clearMarks() },
+	})
 }
 
 func (m *installedModel) getSelectedPackageName() (string, error) {
@@ -518,3 +1093,69 @@ func (m *installedModel) getSelectedPackageName() (string, error) {
 
 	return strings.TrimSuffix(m.packageLines[m.visiblePackageLines[m.listCursor]], "\n"), nil
 }
+
+// transactionTargets is every marked package, or the one under the
+// cursor if none are marked.
+func (m *installedModel) transactionTargets() ([]string, error) {
+	if len(m.marks) > 0 {
+		return m.marks.Names(), nil
+	}
+
+	name, err := m.getSelectedPackageName()
+	if err != nil {
+		return nil, err
+	}
+	return []string{name}, nil
+}
+
+func (m *installedModel) visiblePackageNames() []string {
+	names := make([]string, len(m.visiblePackageLines))
+	for i, lineIdx := range m.visiblePackageLines {
+		names[i] = strings.TrimSuffix(m.packageLines[lineIdx], "\n")
+	}
+	return names
+}
+
+func (m *installedModel) toggleMark() tea.Cmd {
+	name, err := m.getSelectedPackageName()
+	if err != nil {
+		return nil
+	}
+
+	m.marks.Toggle(name)
+
+	// Marking moves on, so that runs of packages can be marked quickly.
+	var getInfo tea.Cmd
+	if m.listCursor < len(m.visiblePackageLines)-1 {
+		m.listCursor++
+		getInfo = m.getPackageInfo()
+	}
+
+	m.buildPackageList()
+	scrollIntoView(&m.listViewport, m.listCursor)
+	return getInfo
+}
+
+func (m *installedModel) markAllVisible() tea.Cmd {
+	for _, name := range m.visiblePackageNames() {
+		m.marks[name] = struct{}{}
+	}
+
+	m.buildPackageList()
+	return nil
+}
+
+func (m *installedModel) invertMarks() tea.Cmd {
+	for _, name := range m.visiblePackageNames() {
+		m.marks.Toggle(name)
+	}
+
+	m.buildPackageList()
+	return nil
+}
+
+func (m *installedModel) clearMarks() tea.Cmd {
+	m.marks.Clear()
+	m.buildPackageList()
+	return nil
+}
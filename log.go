@@ -0,0 +1,539 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	cmd "ptui/command"
+	"ptui/styles"
+	"ptui/types"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type logInitMsg struct{}
+
+type logLine struct {
+	time    time.Time
+	text    string
+	isError bool
+}
+
+// logEntry is everything known about one command. Commands which
+// failed before starting only have a finish time.
+type logEntry struct {
+	commandId int
+	argv      []string
+
+	// Queries are hidden unless asked for. Commands run in a terminal
+	// can't tell stderr apart, since the terminal merges both streams.
+	isQuery    bool
+	isTerminal bool
+
+	started  time.Time
+	finished time.Time
+	lines    []logLine
+
+	err         error
+	interrupted bool
+	isDone      bool
+}
+
+// commandLog keeps the output of every command for the rest of the
+// session. The root model records into it whichever tab is selected,
+// and the Log tab displays it.
+type commandLog struct {
+	entries []*logEntry
+	byId    map[int]*logEntry
+
+	queryCount int
+}
+
+func newCommandLog() *commandLog {
+	return &commandLog{byId: make(map[int]*logEntry)}
+}
+
+// The info panels run a query for every cursor movement, so only the
+// most recent are kept.
+const maxLoggedQueries = 200
+
+// Transactions stream to Background, and their previews to Preview.
+// Everything else is a query.
+func isQueryTarget(t types.StreamTarget) bool {
+	return t != Background && t != Preview
+}
+
+func (l *commandLog) entry(id int, target types.StreamTarget) *logEntry {
+	if entry, exists := l.byId[id]; exists {
+		return entry
+	}
+
+	entry := &logEntry{commandId: id, isQuery: isQueryTarget(target)}
+	l.entries = append(l.entries, entry)
+	l.byId[id] = entry
+
+	if entry.isQuery {
+		l.queryCount++
+		if l.queryCount > maxLoggedQueries {
+			l.dropOldestQuery()
+		}
+	}
+
+	return entry
+}
+
+func (l *commandLog) dropOldestQuery() {
+	i := slices.IndexFunc(l.entries, func(e *logEntry) bool { return e.isQuery })
+	delete(l.byId, l.entries[i].commandId)
+	l.entries = slices.Delete(l.entries, i, i+1)
+	l.queryCount--
+}
+
+func (l *commandLog) Record(msg tea.Msg) {
+	now := time.Now()
+
+	switch msg := msg.(type) {
+	case cmd.CommandStartMsg:
+		entry := l.entry(msg.CommandId, msg.Target)
+		entry.started = now
+		if msg.Handle != nil {
+			entry.argv = msg.Handle.Argv
+			entry.isTerminal = msg.Handle.IsTerminal
+		}
+
+	case cmd.CommandChunkMsg:
+		entry := l.entry(msg.CommandId, msg.Target)
+		for _, text := range msg.Lines {
+			entry.lines = append(entry.lines, logLine{time: now, text: strings.TrimSuffix(text, "\n"), isError: msg.IsError})
+		}
+
+	case cmd.CommandDoneMsg:
+		entry := l.entry(msg.CommandId, msg.Target)
+		entry.finished = now
+		entry.err = msg.Err
+		entry.interrupted = msg.Interrupted
+		entry.isDone = true
+		if entry.argv == nil {
+			entry.argv = msg.Argv
+		}
+	}
+}
+
+// status describes how the command ended.
+func (e *logEntry) status() string {
+	if !e.isDone {
+		return "running"
+	}
+
+	var exitErr *exec.ExitError
+	var status string
+	switch {
+	case e.err == nil:
+		status = "exited with status 0"
+	case errors.As(e.err, &exitErr):
+		status = fmt.Sprintf("exited with status %d", exitErr.ExitCode())
+	default:
+		status = fmt.Sprintf("failed: %s", e.err)
+	}
+
+	if e.interrupted {
+		status = "interrupted, " + status
+	}
+
+	if !e.started.IsZero() {
+		status += fmt.Sprintf(" after %s", e.finished.Sub(e.started).Round(100*time.Millisecond))
+	}
+
+	return status
+}
+
+func (e *logEntry) commandText() string {
+	quoted := make([]string, len(e.argv))
+	for i, arg := range e.argv {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'") {
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// terminalNote explains why a command run in a terminal has no errors
+// marked.
+const terminalNote = "Run in a terminal, which mixes errors into the output"
+
+// Export writes the whole log as plain text, regardless of any search.
+func (l *commandLog) Export(path string) error {
+	const layout = "2006-01-02 15:04:05"
+
+	var builder strings.Builder
+	for _, entry := range l.entries {
+		started := entry.started
+		if started.IsZero() {
+			started = entry.finished
+		}
+
+		fmt.Fprintf(&builder, "[%s] $ %s\n", started.Format(layout), entry.commandText())
+		if entry.isTerminal {
+			fmt.Fprintf(&builder, "[%s]   %s\n", started.Format(layout), terminalNote)
+		}
+		for _, line := range entry.lines {
+			marker := " "
+			if line.isError {
+				marker = "!"
+			}
+			fmt.Fprintf(&builder, "[%s] %s %s\n", line.time.Format(layout), marker, line.text)
+		}
+
+		if entry.isDone {
+			fmt.Fprintf(&builder, "[%s] %s\n", entry.finished.Format(layout), entry.status())
+		}
+		builder.WriteString("\n")
+	}
+
+	return os.WriteFile(path, []byte(builder.String()), 0o644)
+}
+
+type logModel struct {
+	title string
+
+	listViewport   viewport.Model
+	hotkeyViewport viewport.Model
+	searchInput    textinput.Model
+
+	log *commandLog
+
+	// The number of lines shown, for the bottom border.
+	visibleLineCount int
+	totalLineCount   int
+
+	fullHeight int
+
+	hasViewportDimensions bool
+	isViewingHotkeys      bool
+	isShowingQueries      bool
+
+	hotkeys        map[string]types.HotkeyBinding
+	hotkeysOrdered []string
+
+	cmds []tea.Cmd
+}
+
+func initialLogModel(log *commandLog) *logModel {
+	model := logModel{
+		title:   "Log",
+		log:     log,
+		hotkeys: make(map[string]types.HotkeyBinding),
+	}
+
+	model.createHotkey("/", "/", "Toggle Search", model.toggleSearch)
+	model.createHotkey("H", "H", "Toggle Hotkeys", model.toggleHotkeys)
+	model.createHotkey("W", "W", "Export Log", model.exportLog)
+	model.createHotkey("Q", "Q", "Toggle Queries", model.toggleQueries)
+	model.createHotkey("X", "X", "Cancel Transaction", cancelTransaction)
+
+	slices.SortFunc(model.hotkeysOrdered, func(a, b string) int {
+		hotkeyA := model.hotkeys[a]
+		hotkeyB := model.hotkeys[b]
+
+		return cmp.Compare(hotkeyA.Description, hotkeyB.Description)
+	})
+
+	return &model
+}
+
+func (m *logModel) createHotkey(key string, displayKey string, description string, action func() tea.Cmd) {
+	m.hotkeys[key] = types.HotkeyBinding{Shortcut: displayKey, Description: description, Command: action}
+	m.hotkeysOrdered = append(m.hotkeysOrdered, key)
+}
+
+func (m *logModel) Init() tea.Cmd {
+	return func() tea.Msg { return logInitMsg{} }
+}
+
+func (m *logModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m.cmds = m.cmds[:0]
+
+	switch msg := msg.(type) {
+	case logInitMsg:
+		m.buildLog(true)
+
+	// The root model has already recorded these.
+	case cmd.CommandStartMsg, cmd.CommandChunkMsg, cmd.CommandDoneMsg:
+		m.buildLog(m.listViewport.AtBottom())
+
+	case types.ContentRectMsg:
+		// One line is kept for the search box.
+		msg.Width -= 4
+		msg.Height -= 1
+
+		if m.hasViewportDimensions {
+			m.fullHeight = msg.Height
+
+			m.listViewport.Width = msg.Width
+			m.listViewport.Height = msg.Height
+			if m.isViewingHotkeys {
+				m.listViewport.Height -= m.hotkeyViewport.Height
+			}
+
+			m.hotkeyViewport.Width = msg.Width
+			m.hotkeyViewport.Height = len(m.hotkeys)
+		} else {
+			m.fullHeight = msg.Height
+			m.listViewport = viewport.New(msg.Width, msg.Height)
+			m.hotkeyViewport = viewport.New(msg.Width, len(m.hotkeys))
+
+			// Log lines are matched as typed, rather than fuzzily.
+			m.searchInput = textinput.New()
+			m.searchInput.Prompt = searchExact.prompt()
+			m.searchInput.Width = msg.Width
+
+			m.hasViewportDimensions = true
+		}
+
+		m.buildLog(true)
+
+	case tea.KeyMsg:
+		handleHotkeyAndSearch(m, msg)
+
+		if m.searchInput.Focused() {
+			break
+		}
+
+		switch msg.String() {
+		case "up", "k":
+			m.listViewport.ScrollUp(1)
+		case "down", "j":
+			m.listViewport.ScrollDown(1)
+		case "pgup":
+			m.listViewport.PageUp()
+		case "pgdown":
+			m.listViewport.PageDown()
+		case "home":
+			m.listViewport.GotoTop()
+		case "end":
+			m.listViewport.GotoBottom()
+		}
+	}
+
+	return m, tea.Batch(m.cmds...)
+}
+
+func (m *logModel) View() string {
+	if !m.hasViewportDimensions {
+		return "Initialising..."
+	}
+
+	listPanel := m.listViewport.View()
+
+	var topRow string
+	if m.searchInput.Focused() || m.searchInput.Value() != "" {
+		topRow = defaultStyle.Render(m.searchInput.View())
+	} else {
+		topRow = reducedEmphasisStyle.Render(fmt.Sprintf("%d commands this session", m.shownEntryCount()))
+	}
+
+	// Scroll positions rather than lines are counted, since there's
+	// no cursor.
+	scrollbar := createScrollbar(
+		2,
+		m.listViewport.YOffset,
+		max(1, m.listViewport.TotalLineCount()-m.listViewport.Height+1),
+		lipgloss.Height(listPanel),
+		true,
+	)
+
+	var hotkeyPanel string
+	if m.isViewingHotkeys {
+		hotkeyPanel = panelStyle.Render(m.hotkeyViewport.View())
+	}
+
+	mainPanel := lipgloss.JoinHorizontal(lipgloss.Left, listPanel, scrollbar)
+	mainPanel = lipgloss.JoinVertical(lipgloss.Left, topRow, mainPanel, hotkeyPanel)
+
+	var statusText string
+	if m.searchInput.Value() != "" {
+		statusText = fmt.Sprintf(" %d of %d lines ", m.visibleLineCount, m.totalLineCount)
+	} else {
+		statusText = fmt.Sprintf(" %d lines ", m.totalLineCount)
+	}
+
+	return createCustomBottomBorder(mainPanel, statusText, false)
+}
+
+func (m *logModel) Title() string {
+	return m.title
+}
+
+// buildLog renders every entry, keeping only the lines that match the
+// search along with the command they came from.
+func (m *logModel) buildLog(follow bool) {
+	if !m.hasViewportDimensions {
+		return
+	}
+
+	searchText := m.searchInput.Value()
+	m.visibleLineCount, m.totalLineCount = 0, 0
+
+	var builder strings.Builder
+	for _, entry := range m.log.entries {
+		if entry.isQuery && !m.isShowingQueries {
+			continue
+		}
+		header := entry.commandText()
+
+		var lines []string
+		for _, line := range entry.lines {
+			m.totalLineCount++
+			if !matchesSearch(line.text, searchText) {
+				continue
+			}
+
+			text := line.time.Format("15:04:05") + "   " + line.text
+			if line.isError {
+				text = styles.ErrorStyle.Render(text)
+			}
+			lines = append(lines, text)
+		}
+
+		if len(lines) == 0 && !matchesSearch(header, searchText) {
+			continue
+		}
+		m.visibleLineCount += len(lines)
+
+		started := entry.started
+		if started.IsZero() {
+			started = entry.finished
+		}
+
+		builder.WriteString(defaultStyle.Foreground(yellow).Render(started.Format("15:04:05") + " $ " + header))
+		builder.WriteString("\n")
+		if entry.isTerminal {
+			builder.WriteString(reducedEmphasisStyle.Render(strings.Repeat(" ", 11) + terminalNote))
+			builder.WriteString("\n")
+		}
+
+		for _, line := range lines {
+			builder.WriteString(line + "\n")
+		}
+
+		status := entry.status()
+		switch {
+		case !entry.isDone:
+			status = reducedEmphasisStyle.Render(status)
+		case entry.err != nil || entry.interrupted:
+			status = styles.ErrorStyle.Render(entry.finished.Format("15:04:05") + " ✗ " + status)
+		default:
+			status = styles.SuccessStyle.Render(entry.finished.Format("15:04:05") + " ✓ " + status)
+		}
+		builder.WriteString(status + "\n\n")
+	}
+
+	if m.shownEntryCount() == 0 {
+		builder.WriteString(reducedEmphasisStyle.Render("Installs, removals and upgrades will be logged here. Press Q to show queries too."))
+	}
+
+	m.listViewport.SetContent(builder.String())
+	if follow {
+		m.listViewport.GotoBottom()
+	}
+}
+
+// shownEntryCount counts the entries shown, before any search.
+func (m *logModel) shownEntryCount() int {
+	if m.isShowingQueries {
+		return len(m.log.entries)
+	}
+	return len(m.log.entries) - m.log.queryCount
+}
+
+func (m *logModel) toggleQueries() tea.Cmd {
+	if m.searchInput.Focused() {
+		return nil
+	}
+
+	m.isShowingQueries = !m.isShowingQueries
+	m.buildLog(true)
+
+	if m.isShowingQueries {
+		return setStatus("Showing queries")
+	}
+	return setStatus("Hiding queries")
+}
+
+func (m *logModel) toggleSearch() tea.Cmd {
+	if m.searchInput.Focused() {
+		m.searchInput.Blur()
+	} else {
+		m.searchInput.Focus()
+		m.searchInput.Width = 10
+	}
+
+	return nil
+}
+
+func (m *logModel) toggleHotkeys() tea.Cmd {
+	m.isViewingHotkeys = !m.isViewingHotkeys
+	if m.isViewingHotkeys {
+		m.listViewport.Height = m.fullHeight - m.hotkeyViewport.Height
+	} else {
+		m.listViewport.Height = m.fullHeight
+	}
+
+	buildSortedHotkeyList(&m.hotkeyViewport, m.hotkeys, m.hotkeysOrdered)
+	return nil
+}
+
+// exportLog asks where to write the log, defaulting to a timestamped
+// file in the working directory.
+func (m *logModel) exportLog() tea.Cmd {
+	if m.searchInput.Focused() {
+		return nil
+	}
+
+	defaultPath := fmt.Sprintf("ptui-%s.log", time.Now().Format("20060102-150405"))
+
+	export := func(path string) tea.Cmd {
+		if path = strings.TrimSpace(path); path == "" {
+			path = defaultPath
+		}
+
+		if err := m.log.Export(path); err != nil {
+			return setStatus(fmt.Sprintf("Could not export log: %s", err))
+		}
+		return setStatus(fmt.Sprintf("Exported log to %s", path))
+	}
+
+	dialog := newInputDialog("Export log", "Write the log to:", export, nil)
+	dialog.input.Placeholder = defaultPath
+
+	return openDialog(dialog)
+}
+
+func (m *logModel) Hotkeys() map[string]types.HotkeyBinding {
+	return m.hotkeys
+}
+
+func (m *logModel) SearchInput() *textinput.Model {
+	return &m.searchInput
+}
+
+func (m *logModel) AddCommand(cmd tea.Cmd) {
+	m.cmds = append(m.cmds, cmd)
+}
+
+func (m *logModel) ResetCursor() {
+	m.buildLog(false)
+	m.listViewport.GotoTop()
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"ptui/alpm"
 	"ptui/command"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -13,10 +16,46 @@ const APP_NAME = "pTUI"
 
 var Program *tea.Program
 
+// Where pacman keeps its databases and configuration, which are read
+// directly where possible.
+var (
+	dbPath     string
+	configPath string
+	logPath    string
+)
+
 func main() {
-	if os.Geteuid() != ROOT_USER_ID {
-		fmt.Printf("%s requires root privileges. Please run as sudo.\n", APP_NAME)
-		os.Exit(1)
+	pacmanBinary := flag.String("pacman", "pacman", "pacman-compatible binary to run, e.g. paru or yay")
+	flag.StringVar(&dbPath, "dbpath", alpm.DefaultDBPath, "pacman database directory")
+	flag.StringVar(&configPath, "config", alpm.DefaultConfigPath, "pacman configuration file")
+	flag.StringVar(&logPath, "logfile", alpm.DefaultLogPath, "pacman log file, read for upgrade dates")
+	helper := flag.String("elevate", "sudo", "helper used to run transactions as root: sudo, doas, pkexec, run0 or none")
+	interactive := flag.Bool("interactive", command.TerminalSupported, "run transactions in a pseudo-terminal and answer pacman's questions instead of passing --noconfirm")
+	flag.Parse()
+
+	command.DefaultExecutor = command.NewProcessExecutor(*pacmanBinary)
+	command.Lock = command.NewLockManager(filepath.Join(dbPath, "db.lck"))
+
+	// Queries run as the current user. Only transactions need root, and
+	// AUR helpers like paru elevate by themselves, hence "none".
+	if os.Geteuid() != ROOT_USER_ID && *helper != "none" {
+		elevator, err := command.NewElevator(*helper)
+		if err != nil {
+			fmt.Printf("%s: %v\n", APP_NAME, err)
+			os.Exit(1)
+		}
+
+		command.Elevation = elevator
+		command.TransactionExecutor = command.NewElevatedExecutor(elevator, *pacmanBinary)
+	}
+
+	if *interactive {
+		if !command.TerminalSupported {
+			fmt.Printf("%s: %v\n", APP_NAME, command.ErrTerminalUnsupported)
+			os.Exit(1)
+		}
+
+		command.TransactionExecutor = command.NewTerminalExecutor(command.Elevation, *pacmanBinary)
 	}
 
 	Program = tea.NewProgram(initialModel())
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// markSet holds the packages marked for a batch transaction. When
+// nothing is marked, transactions act on the package under the cursor.
+type markSet map[string]struct{}
+
+func (s markSet) Toggle(name string) {
+	if s.Has(name) {
+		delete(s, name)
+	} else {
+		s[name] = struct{}{}
+	}
+}
+
+func (s markSet) Has(name string) bool {
+	_, exists := s[name]
+	return exists
+}
+
+func (s markSet) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+func (s markSet) Clear() {
+	clear(s)
+}
+
+// prefix is drawn before each name in a list, but only once something
+// has been marked so that the list doesn't shift otherwise.
+func (s markSet) prefix(name string) string {
+	switch {
+	case len(s) == 0:
+		return ""
+	case s.Has(name):
+		return defaultStyle.Foreground(yellow).Render("● ")
+	default:
+		return "  "
+	}
+}
+
+// borderText is appended to a list's bottom border.
+func (s markSet) borderText() string {
+	if len(s) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" · %d marked", len(s))
+}
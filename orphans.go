@@ -0,0 +1,508 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"ptui/alpm"
+	cmd "ptui/command"
+	"ptui/types"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type orphansInitMsg struct{}
+
+// orphanRow is a line of the Orphans tab. Only rows in the first wave
+// are orphans now; the rest become orphans once the waves before them
+// are removed.
+type orphanRow struct {
+	name string
+	wave int
+	size int64
+
+	// For orphans, the removed packages which used to need them. For
+	// later waves, the packages whose removal orphans them.
+	related []string
+}
+
+type orphansModel struct {
+	title string
+
+	listViewport   viewport.Model
+	hotkeyViewport viewport.Model
+	searchInput    textinput.Model
+
+	dbs   *packageDatabases
+	rows  []orphanRow
+	marks markSet
+
+	// The databases the rows were built from, so that they're only
+	// rebuilt when one is reloaded.
+	builtFromLocal *alpm.LocalDB
+	builtFromSync  *alpm.SyncIndex
+
+	cursor     int
+	listCmdId  int
+	fullHeight int
+
+	hasViewportDimensions  bool
+	isViewingHotkeys       bool
+	isFinishedReadingLines bool
+
+	hotkeys        map[string]types.HotkeyBinding
+	hotkeysOrdered []string
+
+	startRoutes types.MessageRouter[*orphansModel, cmd.CommandStartMsg]
+	chunkRoutes types.MessageRouter[*orphansModel, cmd.CommandChunkMsg]
+	doneRoutes  types.MessageRouter[*orphansModel, cmd.CommandDoneMsg]
+
+	cmds []tea.Cmd
+}
+
+func initialOrphansModel(dbs *packageDatabases) *orphansModel {
+	model := orphansModel{
+		title:   "Orphans",
+		dbs:     dbs,
+		marks:   make(markSet),
+		hotkeys: make(map[string]types.HotkeyBinding),
+
+		// Without the local database, pacman -Qdtq lists the orphans
+		// by name alone.
+		startRoutes: types.MessageRouter[*orphansModel, cmd.CommandStartMsg]{
+			PackageList: func(m *orphansModel, msg cmd.CommandStartMsg) tea.Cmd {
+				m.listCmdId = msg.CommandId
+				m.isFinishedReadingLines = false
+				m.rows = m.rows[:0]
+				return nil
+			},
+		},
+		chunkRoutes: types.MessageRouter[*orphansModel, cmd.CommandChunkMsg]{
+			PackageList: func(m *orphansModel, msg cmd.CommandChunkMsg) tea.Cmd {
+				if msg.CommandId != m.listCmdId || msg.IsError {
+					return nil
+				}
+
+				for _, line := range msg.Lines {
+					if name := strings.TrimSpace(line); name != "" {
+						m.rows = append(m.rows, orphanRow{name: name})
+					}
+				}
+				return nil
+			},
+		},
+		doneRoutes: types.MessageRouter[*orphansModel, cmd.CommandDoneMsg]{
+			PackageList: func(m *orphansModel, msg cmd.CommandDoneMsg) tea.Cmd {
+				if msg.CommandId == m.listCmdId {
+					m.isFinishedReadingLines = true
+				}
+				return nil
+			},
+		},
+	}
+
+	model.createHotkey("H", "H", "Toggle Hotkeys", model.toggleHotkeys)
+	model.createHotkey(" ", "Space", "Toggle Mark", model.toggleMark)
+	model.createHotkey("C", "C", "Clear Marks", model.clearMarks)
+	model.createHotkey("R", "R", "Remove Selected", model.removeSelected)
+	model.createHotkey("A", "A", "Remove All Orphans", model.removeAll)
+	model.createHotkey("X", "X", "Cancel Transaction", cancelTransaction)
+
+	slices.SortFunc(model.hotkeysOrdered, func(a, b string) int {
+		hotkeyA := model.hotkeys[a]
+		hotkeyB := model.hotkeys[b]
+
+		return cmp.Compare(hotkeyA.Description, hotkeyB.Description)
+	})
+
+	return &model
+}
+
+func (m *orphansModel) createHotkey(key string, displayKey string, description string, action func() tea.Cmd) {
+	m.hotkeys[key] = types.HotkeyBinding{Shortcut: displayKey, Description: description, Command: action}
+	m.hotkeysOrdered = append(m.hotkeysOrdered, key)
+}
+
+func (m *orphansModel) Init() tea.Cmd {
+	return func() tea.Msg { return orphansInitMsg{} }
+}
+
+func (m *orphansModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m.cmds = m.cmds[:0]
+
+	switch msg := msg.(type) {
+	case orphansInitMsg:
+		if m.dbs.local == nil {
+			m.cmds = append(m.cmds, m.getOrphans())
+		}
+		if m.dbs.needsSyncIndex() {
+			m.cmds = append(m.cmds, loadSyncIndex)
+		}
+
+	case localDbLoadedMsg:
+		if msg.err != nil {
+			m.cmds = append(m.cmds, m.getOrphans())
+		}
+
+	case cmd.CommandStartMsg:
+		if handler, exists := m.startRoutes[msg.Target]; exists {
+			handler(m, msg)
+		}
+
+	case cmd.CommandChunkMsg:
+		if handler, exists := m.chunkRoutes[msg.Target]; exists {
+			handler(m, msg)
+		}
+
+	case cmd.CommandDoneMsg:
+		if handler, exists := m.doneRoutes[msg.Target]; exists {
+			handler(m, msg)
+		}
+
+	case types.ContentRectMsg:
+		// One line is kept for the summary.
+		msg.Width -= 4
+		msg.Height -= 1
+
+		if m.hasViewportDimensions {
+			m.fullHeight = msg.Height
+
+			m.listViewport.Width = msg.Width
+			m.listViewport.Height = msg.Height
+			if m.isViewingHotkeys {
+				m.listViewport.Height -= m.hotkeyViewport.Height
+			}
+
+			m.hotkeyViewport.Width = msg.Width
+			m.hotkeyViewport.Height = len(m.hotkeys)
+		} else {
+			m.fullHeight = msg.Height
+			m.listViewport = viewport.New(msg.Width, msg.Height)
+			m.hotkeyViewport = viewport.New(msg.Width, len(m.hotkeys))
+
+			m.hasViewportDimensions = true
+		}
+
+	case tea.KeyMsg:
+		handleHotkeyAndSearch(m, msg)
+
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.rows)-1 {
+				m.cursor++
+			}
+		}
+	}
+
+	// The local database is reloaded after every transaction, whichever
+	// tab is selected, so the rows follow it rather than its messages.
+	if m.dbs.local != nil && (m.dbs.local != m.builtFromLocal || m.dbs.sync != m.builtFromSync) {
+		m.buildRows()
+	}
+	m.buildList()
+
+	return m, tea.Batch(m.cmds...)
+}
+
+func (m *orphansModel) View() string {
+	if !m.hasViewportDimensions {
+		return "Initialising..."
+	}
+
+	listPanel := m.listViewport.View()
+
+	scrollbar := createScrollbar(
+		2,
+		m.cursor,
+		len(m.rows),
+		lipgloss.Height(listPanel),
+		m.isFinishedReadingLines,
+	)
+
+	var hotkeyPanel string
+	if m.isViewingHotkeys {
+		hotkeyPanel = panelStyle.Render(m.hotkeyViewport.View())
+	}
+
+	mainPanel := lipgloss.JoinHorizontal(lipgloss.Left, listPanel, scrollbar)
+	mainPanel = lipgloss.JoinVertical(lipgloss.Left, m.summaryView(), mainPanel, hotkeyPanel)
+
+	statusText := fmt.Sprintf(" %d orphans%s ", len(m.orphanNames()), m.marks.borderText())
+	return createCustomBottomBorder(mainPanel, statusText, false)
+}
+
+func (m *orphansModel) Title() string {
+	return m.title
+}
+
+func (m *orphansModel) getOrphans() tea.Cmd {
+	if !m.dbs.isLocalUnavailable {
+		return loadLocalDb
+	}
+
+	return cmd.NewCommand().
+		Operation("Q").
+		Options("d", "t", "q").
+		Target(PackageList).
+		Run()
+}
+
+// buildRows lists every wave of orphans from the local database.
+func (m *orphansModel) buildRows() {
+	m.builtFromLocal, m.builtFromSync = m.dbs.local, m.dbs.sync
+	m.isFinishedReadingLines = true
+
+	formerDependents := m.formerDependents()
+
+	m.rows = m.rows[:0]
+	for wave, pkgs := range m.dbs.local.OrphanWaves() {
+		slices.SortFunc(pkgs, func(a, b *alpm.Package) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+
+		for _, pkg := range pkgs {
+			row := orphanRow{name: pkg.Name, wave: wave, size: pkg.InstalledSize}
+
+			if wave == 0 {
+				row.related = formerDependents[pkg.Name]
+				for _, provide := range pkg.Provides {
+					row.related = append(row.related, formerDependents[alpm.DependName(provide)]...)
+				}
+				slices.Sort(row.related)
+				row.related = slices.Compact(row.related)
+			} else {
+				row.related = append(slices.Clone(pkg.RequiredBy), pkg.OptionalFor...)
+			}
+
+			m.rows = append(m.rows, row)
+		}
+	}
+
+	// Marks on packages that are no longer orphans would remove more
+	// than the user expects.
+	orphans := m.orphanNames()
+	for name := range m.marks {
+		if !slices.Contains(orphans, name) {
+			delete(m.marks, name)
+		}
+	}
+
+	m.cursor = min(m.cursor, max(0, len(m.rows)-1))
+}
+
+// formerDependents maps the names packages are depended on by to the
+// packages which depended on them but have since been removed, going
+// by pacman's log and the sync databases.
+func (m *orphansModel) formerDependents() map[string][]string {
+	dependents := make(map[string][]string)
+	if m.dbs.log == nil || m.dbs.sync == nil {
+		return dependents
+	}
+
+	for name := range m.dbs.log.Removed {
+		if _, installed := m.dbs.local.Package(name); installed {
+			continue
+		}
+
+		pkg, exists := m.dbs.sync.Package(name)
+		if !exists {
+			continue
+		}
+
+		for _, dep := range pkg.Depends {
+			depName := alpm.DependName(dep)
+			dependents[depName] = append(dependents[depName], name)
+		}
+	}
+
+	return dependents
+}
+
+func (m *orphansModel) summaryView() string {
+	var orphanCount, laterCount int
+	var orphanSize, laterSize int64
+	for _, row := range m.rows {
+		if row.wave == 0 {
+			orphanCount++
+			orphanSize += row.size
+		} else {
+			laterCount++
+			laterSize += row.size
+		}
+	}
+
+	summary := fmt.Sprintf("%d orphans", orphanCount)
+	if m.dbs.local != nil {
+		summary += fmt.Sprintf(" using %s", formatSize(orphanSize))
+	}
+	if laterCount > 0 {
+		summary += fmt.Sprintf(", and %d more using %s once they're removed", laterCount, formatSize(laterSize))
+	}
+
+	return reducedEmphasisStyle.Render(summary)
+}
+
+func (m *orphansModel) buildList() {
+	if !m.hasViewportDimensions {
+		return
+	}
+
+	if len(m.rows) == 0 {
+		if m.isFinishedReadingLines {
+			m.listViewport.SetContent("No orphans. Every package installed as a dependency is still needed.")
+		} else {
+			m.listViewport.SetContent("Loading orphans...")
+		}
+		return
+	}
+
+	nameWidth := 0
+	for _, row := range m.rows {
+		nameWidth = max(nameWidth, len(row.name)+2*row.wave)
+	}
+	nameWidth = min(nameWidth, 40)
+
+	var builder strings.Builder
+	for i, row := range m.rows {
+		name := strings.Repeat("  ", row.wave) + row.name
+		if row.wave > 0 {
+			name = strings.Repeat("  ", row.wave-1) + "↳ " + row.name
+		}
+		name = padRight(truncate(name, nameWidth), nameWidth)
+
+		var details string
+		if m.dbs.local != nil {
+			details = fmt.Sprintf("  %10s", formatSize(row.size))
+		}
+
+		switch {
+		case row.wave > 0:
+			details += "  after removing " + strings.Join(row.related, ", ")
+		case len(row.related) > 0:
+			details += "  formerly needed by " + strings.Join(row.related, ", ")
+		}
+
+		details = truncate(details, m.listViewport.Width-nameWidth-2)
+
+		if i == m.cursor {
+			name = selectedStyle.Render(name)
+		} else if row.wave > 0 {
+			name = reducedEmphasisStyle.Render(name)
+		}
+
+		builder.WriteString(m.marks.prefix(row.name) + name + reducedEmphasisStyle.Render(details) + "\n")
+	}
+
+	m.listViewport.SetContent(builder.String())
+	scrollIntoView(&m.listViewport, m.cursor)
+}
+
+// orphanNames lists the packages in the first wave, which are the only
+// ones that can be removed on their own.
+func (m *orphansModel) orphanNames() []string {
+	var names []string
+	for _, row := range m.rows {
+		if row.wave == 0 {
+			names = append(names, row.name)
+		}
+	}
+	return names
+}
+
+func (m *orphansModel) toggleMark() tea.Cmd {
+	if len(m.rows) == 0 {
+		return nil
+	}
+
+	row := m.rows[m.cursor]
+	if row.wave > 0 {
+		return setStatus(fmt.Sprintf("%s is still needed by %s", row.name, strings.Join(row.related, ", ")))
+	}
+
+	m.marks.Toggle(row.name)
+	if m.cursor < len(m.rows)-1 {
+		m.cursor++
+	}
+	return nil
+}
+
+func (m *orphansModel) clearMarks() tea.Cmd {
+	m.marks.Clear()
+	return nil
+}
+
+// removeSelected removes the marked orphans, or the one under the
+// cursor. pacman takes the packages they leave orphaned with them.
+func (m *orphansModel) removeSelected() tea.Cmd {
+	if len(m.marks) > 0 {
+		return m.remove(m.marks.Names())
+	}
+
+	if len(m.rows) == 0 {
+		return nil
+	}
+
+	row := m.rows[m.cursor]
+	if row.wave > 0 {
+		return setStatus(fmt.Sprintf("%s is still needed by %s", row.name, strings.Join(row.related, ", ")))
+	}
+
+	return m.remove([]string{row.name})
+}
+
+func (m *orphansModel) removeAll() tea.Cmd {
+	return m.remove(m.orphanNames())
+}
+
+func (m *orphansModel) remove(targets []string) tea.Cmd {
+	if len(targets) == 0 {
+		return setStatus("There are no orphans to remove")
+	}
+
+	return previewTransaction(transaction{
+		kind:          removeTransaction,
+		targets:       targets,
+		removeConfigs: true,
+		callback:      func() tea.Cmd { return loadLocalDb },
+		onConfirm:     func() { m.marks.Clear() },
+	})
+}
+
+func (m *orphansModel) toggleHotkeys() tea.Cmd {
+	m.isViewingHotkeys = !m.isViewingHotkeys
+	if m.isViewingHotkeys {
+		m.listViewport.Height = m.fullHeight - m.hotkeyViewport.Height
+	} else {
+		m.listViewport.Height = m.fullHeight
+	}
+
+	buildSortedHotkeyList(&m.hotkeyViewport, m.hotkeys, m.hotkeysOrdered)
+	scrollIntoView(&m.listViewport, m.cursor)
+
+	return nil
+}
+
+func (m *orphansModel) Hotkeys() map[string]types.HotkeyBinding {
+	return m.hotkeys
+}
+
+func (m *orphansModel) SearchInput() *textinput.Model {
+	return &m.searchInput
+}
+
+func (m *orphansModel) AddCommand(cmd tea.Cmd) {
+	m.cmds = append(m.cmds, cmd)
+}
+
+func (m *orphansModel) ResetCursor() {
+	m.cursor = 0
+}
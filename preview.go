@@ -0,0 +1,390 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"ptui/alpm"
+	cmd "ptui/command"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type transactionKind uint8
+
+const (
+	installTransaction transactionKind = iota
+	removeTransaction
+	upgradeTransaction
+)
+
+// transaction is a mutating pacman operation waiting to be previewed
+// and confirmed. Only the packages the user picked are listed; pacman
+// works out the rest when printing the preview.
+type transaction struct {
+	kind    transactionKind
+	targets []string
+
+	// For upgrades, packages held back and whether the databases are
+	// refreshed first.
+	ignored []string
+	refresh bool
+
+	// For removals, whether modified configuration files are deleted
+	// too rather than saved as .pacsave files.
+	removeConfigs bool
+
+	callback func() tea.Cmd
+
+	// Run once the user confirms, before the job is queued.
+	onConfirm func()
+}
+
+// The preview asks pacman to print targets in a parseable form rather
+// than the usual list of download URLs or names.
+const previewPrintFormat = "%n %v %r %s"
+
+// previewTransactionMsg asks the root model to preview a transaction.
+// It's followed by the preview command itself.
+type previewTransactionMsg struct {
+	tx transaction
+}
+
+func previewTransaction(tx transaction) tea.Cmd {
+	return tea.Sequence(
+		func() tea.Msg { return previewTransactionMsg{tx: tx} },
+		tx.previewCommand().Run(),
+	)
+}
+
+func (t transaction) title() string {
+	switch t.kind {
+	case removeTransaction:
+		return "Remove packages"
+	case upgradeTransaction:
+		return "Upgrade packages"
+	default:
+		return "Install packages"
+	}
+}
+
+func (t transaction) baseCommand() *cmd.Command {
+	command := cmd.NewCommand()
+
+	switch t.kind {
+	case removeTransaction:
+		command.Operation("R").Options("s")
+		if t.removeConfigs {
+			command.Options("n")
+		}
+	case upgradeTransaction:
+		command.Operation("S").Options("u")
+	default:
+		command.Operation("S")
+	}
+
+	command.Arguments(t.targets...)
+	if len(t.ignored) > 0 {
+		command.Arguments("--ignore", strings.Join(t.ignored, ","))
+	}
+
+	return command
+}
+
+// previewCommand prints what the transaction would do without doing
+// it. It can't refresh the databases, which would need root.
+func (t transaction) previewCommand() *cmd.Command {
+	return t.baseCommand().
+		Options("p").
+		Arguments("--print-format", previewPrintFormat, "--noconfirm").
+		Target(Preview)
+}
+
+func (t transaction) command() *cmd.Command {
+	command := t.baseCommand()
+	if t.refresh {
+		command.Options("y")
+	}
+
+	return command.
+		NoConfirm().
+		Target(Background).
+		Callback(t.callback)
+}
+
+// description names the transaction in the queue.
+func (t transaction) description() string {
+	var verb string
+	switch t.kind {
+	case removeTransaction:
+		verb = "Remove"
+	case upgradeTransaction:
+		verb = "Upgrade"
+	default:
+		verb = "Install"
+	}
+
+	description := verb + " " + strings.Join(t.targets, " ")
+	if len(t.targets) == 0 {
+		description = verb + " all packages"
+	}
+
+	if len(t.ignored) > 0 {
+		description += ", except " + strings.Join(t.ignored, " ")
+	}
+	if t.refresh {
+		description += ", refreshing databases"
+	}
+	if t.removeConfigs {
+		description += ", with configuration files"
+	}
+
+	return description
+}
+
+// enqueue adds the transaction to the job queue once it's confirmed.
+func (t transaction) enqueue() tea.Cmd {
+	if t.onConfirm != nil {
+		t.onConfirm()
+	}
+
+	return enqueueJob(t.description(), t.command())
+}
+
+type previewTarget struct {
+	name       string
+	version    string
+	oldVersion string
+	repository string
+	size       int64
+}
+
+// transactionPreview collects the output of a preview command, then
+// summarises it in a confirmation dialog.
+type transactionPreview struct {
+	tx        transaction
+	dbs       *packageDatabases
+	cmdId     int
+	isStarted bool
+
+	targets []previewTarget
+	errors  []string
+}
+
+func newTransactionPreview(tx transaction, dbs *packageDatabases) *transactionPreview {
+	return &transactionPreview{tx: tx, dbs: dbs}
+}
+
+// Update returns a dialog once the preview command has finished.
+func (p *transactionPreview) Update(msg tea.Msg) *dialogModel {
+	switch msg := msg.(type) {
+	case cmd.CommandStartMsg:
+		if !p.isStarted {
+			p.cmdId = msg.CommandId
+			p.isStarted = true
+		}
+
+	case cmd.CommandChunkMsg:
+		if msg.CommandId != p.cmdId {
+			return nil
+		}
+
+		for _, line := range msg.Lines {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+
+			if msg.IsError {
+				p.errors = append(p.errors, line)
+			} else if target, ok := parsePreviewLine(line); ok {
+				p.targets = append(p.targets, target)
+			}
+		}
+
+	case cmd.CommandDoneMsg:
+		if msg.CommandId != p.cmdId {
+			return nil
+		}
+
+		if msg.Err != nil {
+			body := strings.Join(append(p.errors, msg.Err.Error()), "\n")
+			if warning := p.dependentsWarning(p.tx.targets); warning != "" {
+				body += "\n\n" + warning
+			}
+			return newMessageDialog("Transaction would fail", body)
+		}
+
+		if len(p.targets) == 0 {
+			return newMessageDialog(p.tx.title(), "There is nothing to do.")
+		}
+
+		return newConfirmDialog(p.tx.title(), p.summary(), p.tx.enqueue)
+	}
+
+	return nil
+}
+
+func parsePreviewLine(line string) (previewTarget, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 4 {
+		return previewTarget{}, false
+	}
+
+	size, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return previewTarget{}, false
+	}
+
+	return previewTarget{name: fields[0], version: fields[1], repository: fields[2], size: size}, true
+}
+
+// summary groups the targets by what will happen to them. pacman only
+// prints names, so installed versions come from the local database.
+func (p *transactionPreview) summary() string {
+	var installs, upgrades, dependencies, removals []string
+	var downloadSize, sizeDelta int64
+	hasSizeDelta := p.dbs.local != nil && p.dbs.sync != nil
+
+	for _, target := range p.targets {
+		var local *alpm.Package
+		if p.dbs.local != nil {
+			local, _ = p.dbs.local.Package(target.name)
+		}
+
+		if p.tx.kind == removeTransaction {
+			entry := fmt.Sprintf("%s %s", target.name, target.version)
+			if !slices.Contains(p.tx.targets, target.name) {
+				entry += " (no longer needed)"
+			}
+
+			removals = append(removals, entry)
+			sizeDelta -= target.size
+			continue
+		}
+
+		downloadSize += target.size
+
+		if hasSizeDelta {
+			if pkg, exists := p.dbs.sync.Package(target.name); exists {
+				sizeDelta += pkg.InstalledSize
+			}
+			if local != nil {
+				sizeDelta -= local.InstalledSize
+			}
+		}
+
+		switch {
+		case local != nil:
+			upgrades = append(upgrades, fmt.Sprintf("%s %s → %s", target.name, local.Version, target.version))
+		case slices.Contains(p.tx.targets, target.name):
+			installs = append(installs, fmt.Sprintf("%s/%s %s", target.repository, target.name, target.version))
+		default:
+			dependencies = append(dependencies, fmt.Sprintf("%s/%s %s", target.repository, target.name, target.version))
+		}
+	}
+
+	var builder strings.Builder
+	section := func(heading string, entries []string) {
+		if len(entries) == 0 {
+			return
+		}
+
+		fmt.Fprintf(&builder, "%s (%d)\n", heading, len(entries))
+		for _, entry := range entries {
+			builder.WriteString("  " + entry + "\n")
+		}
+		builder.WriteString("\n")
+	}
+
+	section("Install", installs)
+	section("Upgrade", upgrades)
+	section("Install as dependencies", dependencies)
+	section("Remove", removals)
+
+	for _, warning := range p.errors {
+		builder.WriteString(warning + "\n")
+	}
+
+	if p.tx.kind == removeTransaction {
+		removed := make([]string, len(p.targets))
+		for i, target := range p.targets {
+			removed[i] = target.name
+		}
+
+		if warning := p.dependentsWarning(removed); warning != "" {
+			builder.WriteString(warning + "\n\n")
+		}
+	}
+
+	if p.tx.kind == removeTransaction {
+		fmt.Fprintf(&builder, "Space freed: %s\n", formatSize(-sizeDelta))
+	} else {
+		fmt.Fprintf(&builder, "Download size: %s\n", formatSize(downloadSize))
+		if hasSizeDelta {
+			fmt.Fprintf(&builder, "Installed size change: %s\n", formatSizeDelta(sizeDelta))
+		}
+	}
+
+	if len(p.tx.ignored) > 0 {
+		fmt.Fprintf(&builder, "\n%s\nHeld back: %s\n", partialUpgradeWarning, strings.Join(p.tx.ignored, ", "))
+	}
+
+	if p.tx.refresh {
+		builder.WriteString("\nThe databases are refreshed first, so newer versions may be installed.\n")
+	}
+
+	builder.WriteString("\nProceed?")
+	return builder.String()
+}
+
+// dependentsWarning names the installed packages which need the ones
+// being removed, however indirectly, and will be left without them.
+func (p *transactionPreview) dependentsWarning(removed []string) string {
+	if p.tx.kind != removeTransaction || p.dbs.local == nil {
+		return ""
+	}
+
+	var pkgs []*alpm.Package
+	for _, name := range removed {
+		if pkg, exists := p.dbs.local.Package(name); exists {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+
+	required, optional := p.dbs.local.Dependents(pkgs...)
+
+	var builder strings.Builder
+	list := func(heading string, dependents []*alpm.Package) {
+		if len(dependents) == 0 {
+			return
+		}
+
+		names := make([]string, len(dependents))
+		for i, pkg := range dependents {
+			names[i] = pkg.Name
+		}
+		slices.Sort(names)
+
+		fmt.Fprintf(&builder, "%s (%d): %s\n", heading, len(names), strings.Join(names, ", "))
+	}
+
+	list("Still needed by", required)
+	list("Loses optional features", optional)
+
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+func isPreviewMsg(msg tea.Msg) bool {
+	switch msg := msg.(type) {
+	case cmd.CommandStartMsg:
+		return msg.Target == Preview
+	case cmd.CommandChunkMsg:
+		return msg.Target == Preview
+	case cmd.CommandDoneMsg:
+		return msg.Target == Preview
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	cmd "ptui/command"
+
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// transactionPanel follows the progress of the running Background
+// command. pacman only draws progress bars in a terminal, so without
+// -interactive just the latest output line is shown.
+type transactionPanel struct {
+	commandId int
+	isActive  bool
+
+	step     *cmd.ProgressMsg
+	download *cmd.ProgressMsg
+	hook     *cmd.HookMsg
+	lastLine string
+
+	bar progress.Model
+}
+
+const (
+	progressLabelWidth = 13
+	progressBarWidth   = 30
+)
+
+func newTransactionPanel() transactionPanel {
+	return transactionPanel{
+		bar: progress.New(
+			progress.WithSolidFill(string(yellow)),
+			progress.WithoutPercentage(),
+			progress.WithWidth(progressBarWidth),
+		),
+	}
+}
+
+func (p *transactionPanel) Update(msg tea.Msg) {
+	switch msg := msg.(type) {
+	case cmd.CommandStartMsg:
+		if msg.Target == Background {
+			*p = transactionPanel{bar: p.bar, commandId: msg.CommandId, isActive: true}
+		}
+
+	case cmd.CommandChunkMsg:
+		if msg.CommandId != p.commandId || !p.isActive {
+			return
+		}
+
+		for _, line := range msg.Lines {
+			if line = strings.TrimSpace(line); line != "" {
+				p.lastLine = line
+			}
+		}
+
+	case cmd.ProgressMsg:
+		if msg.CommandId != p.commandId || !p.isActive {
+			return
+		}
+
+		switch {
+		case msg.IsTotal:
+			p.download = &msg
+		case msg.Kind == cmd.TransactionProgress:
+			// Downloads are finished once packages are being
+			// checked or installed.
+			p.download = nil
+			p.step = &msg
+		default:
+			p.step = &msg
+		}
+
+	case cmd.HookMsg:
+		if msg.CommandId == p.commandId && p.isActive {
+			p.hook = &msg
+		}
+
+	case cmd.CommandDoneMsg:
+		if msg.CommandId == p.commandId {
+			p.isActive = false
+		}
+	}
+}
+
+func (p *transactionPanel) IsVisible() bool {
+	return p.isActive
+}
+
+func (p *transactionPanel) View(width int) string {
+	innerWidth := width - BORDER_WIDTH - 2
+
+	var rows []string
+	if step := p.step; step != nil {
+		if step.Kind == cmd.DownloadProgress {
+			rows = append(rows, p.progressRow("Downloading", step.Item, downloadStats(step), step.Percent, innerWidth))
+		} else {
+			label := fmt.Sprintf("(%d/%d)", step.Current, step.Total)
+			rows = append(rows, p.progressRow(label, step.Item, "", step.Percent, innerWidth))
+		}
+	}
+
+	if download := p.download; download != nil {
+		label := fmt.Sprintf("%d of %d files", download.Current, download.Total)
+		rows = append(rows, p.progressRow("Total", label, downloadStats(download), download.Percent, innerWidth))
+	} else if step := p.step; step != nil && step.Kind == cmd.TransactionProgress && step.Total > 0 {
+		// Each step is an equal share of the whole transaction.
+		overall := (float64(step.Current-1) + step.Percent) / float64(step.Total)
+		label := fmt.Sprintf("%d of %d steps", step.Current, step.Total)
+		rows = append(rows, p.progressRow("Overall", label, "", overall, innerWidth))
+	}
+
+	if hook := p.hook; hook != nil {
+		text := fmt.Sprintf("(%d/%d) %s", hook.Current, hook.Total, hook.Name)
+		rows = append(rows, padRight("Hook", progressLabelWidth)+truncate(text, innerWidth-progressLabelWidth))
+	}
+
+	if p.lastLine != "" || len(rows) == 0 {
+		rows = append(rows, reducedEmphasisStyle.Render(truncate(p.lastLine, innerWidth)))
+	}
+
+	title := defaultStyle.Foreground(yellow).Render("Transaction")
+	content := lipgloss.JoinVertical(lipgloss.Left, append([]string{title}, rows...)...)
+
+	return panelStyle.Width(width-BORDER_WIDTH).Padding(0, 1).Render(content)
+}
+
+// progressRow lays out a label, a description and its statistics on
+// the left with the bar and percentage aligned on the right.
+func (p *transactionPanel) progressRow(label string, text string, stats string, percent float64, width int) string {
+	barView := fmt.Sprintf(" %s %3.0f%%", p.bar.ViewAs(percent), percent*100)
+	textWidth := width - progressLabelWidth - lipgloss.Width(barView)
+
+	if stats != "" {
+		stats = "  " + stats
+	}
+	text = truncate(text, textWidth-len(stats)) + stats
+
+	return padRight(label, progressLabelWidth) + padRight(text, textWidth) + barView
+}
+
+func downloadStats(msg *cmd.ProgressMsg) string {
+	return fmt.Sprintf("%s  %s/s  %s", formatSize(msg.Transferred), formatSize(msg.Rate), msg.ETA)
+}
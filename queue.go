@@ -0,0 +1,317 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	cmd "ptui/command"
+	"ptui/styles"
+	"ptui/types"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type queueInitMsg struct{}
+
+// enqueueJob adds a transaction to the job queue, saying so if it has
+// to wait for the ones before it.
+func enqueueJob(description string, command *cmd.Command) tea.Cmd {
+	run := cmd.Jobs.Enqueue(description, command)
+	if run == nil {
+		return setStatus(fmt.Sprintf("Queued: %s (%d pending)", description, cmd.Jobs.Pending()))
+	}
+
+	return run
+}
+
+type queueModel struct {
+	title string
+
+	listViewport   viewport.Model
+	hotkeyViewport viewport.Model
+	searchInput    textinput.Model
+
+	jobs   []cmd.Job
+	cursor int
+
+	fullHeight int
+
+	hasViewportDimensions bool
+	isViewingHotkeys      bool
+
+	hotkeys        map[string]types.HotkeyBinding
+	hotkeysOrdered []string
+
+	cmds []tea.Cmd
+}
+
+func initialQueueModel() *queueModel {
+	model := queueModel{
+		title:   "Queue",
+		hotkeys: make(map[string]types.HotkeyBinding),
+	}
+
+	model.createHotkey("H", "H", "Toggle Hotkeys", model.toggleHotkeys)
+	model.createHotkey("K", "K", "Move Job Up", model.moveUp)
+	model.createHotkey("J", "J", "Move Job Down", model.moveDown)
+	model.createHotkey("D", "D", "Drop Job", model.dropJob)
+	model.createHotkey("R", "R", "Retry Job", model.retryJob)
+	model.createHotkey("C", "C", "Clear Finished", model.clearFinished)
+	model.createHotkey("X", "X", "Cancel Transaction", cancelTransaction)
+
+	slices.SortFunc(model.hotkeysOrdered, func(a, b string) int {
+		hotkeyA := model.hotkeys[a]
+		hotkeyB := model.hotkeys[b]
+
+		return cmp.Compare(hotkeyA.Description, hotkeyB.Description)
+	})
+
+	return &model
+}
+
+func (m *queueModel) createHotkey(key string, displayKey string, description string, action func() tea.Cmd) {
+	m.hotkeys[key] = types.HotkeyBinding{Shortcut: displayKey, Description: description, Command: action}
+	m.hotkeysOrdered = append(m.hotkeysOrdered, key)
+}
+
+func (m *queueModel) Init() tea.Cmd {
+	return func() tea.Msg { return queueInitMsg{} }
+}
+
+func (m *queueModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m.cmds = m.cmds[:0]
+
+	switch msg := msg.(type) {
+	case types.ContentRectMsg:
+		// One line is kept for the summary.
+		msg.Width -= 4
+		msg.Height -= 1
+
+		if m.hasViewportDimensions {
+			m.fullHeight = msg.Height
+
+			m.listViewport.Width = msg.Width
+			m.listViewport.Height = msg.Height
+			if m.isViewingHotkeys {
+				m.listViewport.Height -= m.hotkeyViewport.Height
+			}
+
+			m.hotkeyViewport.Width = msg.Width
+			m.hotkeyViewport.Height = len(m.hotkeys)
+		} else {
+			m.fullHeight = msg.Height
+			m.listViewport = viewport.New(msg.Width, msg.Height)
+			m.hotkeyViewport = viewport.New(msg.Width, len(m.hotkeys))
+
+			m.hasViewportDimensions = true
+		}
+
+	case tea.KeyMsg:
+		handleHotkeyAndSearch(m, msg)
+
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.jobs)-1 {
+				m.cursor++
+			}
+		}
+	}
+
+	// Jobs change as commands finish, whichever tab is selected, so
+	// the list is always rebuilt from the queue.
+	m.buildList()
+
+	return m, tea.Batch(m.cmds...)
+}
+
+func (m *queueModel) View() string {
+	if !m.hasViewportDimensions {
+		return "Initialising..."
+	}
+
+	listPanel := m.listViewport.View()
+
+	scrollbar := createScrollbar(
+		2,
+		m.cursor,
+		len(m.jobs),
+		lipgloss.Height(listPanel),
+		true,
+	)
+
+	var hotkeyPanel string
+	if m.isViewingHotkeys {
+		hotkeyPanel = panelStyle.Render(m.hotkeyViewport.View())
+	}
+
+	mainPanel := lipgloss.JoinHorizontal(lipgloss.Left, listPanel, scrollbar)
+	mainPanel = lipgloss.JoinVertical(lipgloss.Left, m.summaryView(), mainPanel, hotkeyPanel)
+
+	statusText := fmt.Sprintf(" %d pending ", cmd.Jobs.Pending())
+	return createCustomBottomBorder(mainPanel, statusText, false)
+}
+
+func (m *queueModel) Title() string {
+	return m.title
+}
+
+func (m *queueModel) summaryView() string {
+	counts := make(map[cmd.JobStatus]int)
+	for _, job := range m.jobs {
+		counts[job.Status]++
+	}
+
+	return reducedEmphasisStyle.Render(fmt.Sprintf(
+		"%d pending, %d running, %d failed, %d done",
+		counts[cmd.JobPending],
+		counts[cmd.JobRunning],
+		counts[cmd.JobFailed],
+		counts[cmd.JobDone],
+	))
+}
+
+func (m *queueModel) buildList() {
+	if !m.hasViewportDimensions {
+		return
+	}
+
+	m.jobs = cmd.Jobs.Jobs()
+	if len(m.jobs) == 0 {
+		m.cursor = 0
+		m.listViewport.SetContent("No jobs queued. Installs, removals and upgrades appear here.")
+		return
+	}
+
+	m.cursor = min(m.cursor, len(m.jobs)-1)
+
+	var builder strings.Builder
+	for i, job := range m.jobs {
+		row := fmt.Sprintf("%3d  %-8s  %s", job.Id, job.Status, job.Description)
+		if job.Status == cmd.JobFailed && job.Err != nil {
+			row += " (" + job.Err.Error() + ")"
+		}
+
+		switch {
+		case i == m.cursor:
+			row = selectedStyle.Render(row)
+		case job.Status == cmd.JobRunning:
+			row = defaultStyle.Foreground(yellow).Render(row)
+		case job.Status == cmd.JobFailed:
+			row = styles.ErrorStyle.Render(row)
+		case job.Status == cmd.JobDone:
+			row = reducedEmphasisStyle.Render(row)
+		}
+
+		builder.WriteString(row + "\n")
+	}
+
+	m.listViewport.SetContent(builder.String())
+	scrollIntoView(&m.listViewport, m.cursor)
+}
+
+func (m *queueModel) selectedJob() (cmd.Job, bool) {
+	if len(m.jobs) == 0 {
+		return cmd.Job{}, false
+	}
+	return m.jobs[m.cursor], true
+}
+
+func (m *queueModel) move(offset int) tea.Cmd {
+	job, exists := m.selectedJob()
+	if !exists {
+		return nil
+	}
+
+	if err := cmd.Jobs.Move(job.Id, offset); err != nil {
+		return setStatus(fmt.Sprintf("Could not move job: %s", err))
+	}
+
+	// Keep the cursor on the job that was moved.
+	m.buildList()
+	for i, moved := range m.jobs {
+		if moved.Id == job.Id {
+			m.cursor = i
+		}
+	}
+
+	return nil
+}
+
+func (m *queueModel) moveUp() tea.Cmd {
+	return m.move(-1)
+}
+
+func (m *queueModel) moveDown() tea.Cmd {
+	return m.move(1)
+}
+
+func (m *queueModel) dropJob() tea.Cmd {
+	job, exists := m.selectedJob()
+	if !exists {
+		return nil
+	}
+
+	if err := cmd.Jobs.Drop(job.Id); err != nil {
+		return setStatus(fmt.Sprintf("Could not drop job: %s", err))
+	}
+
+	return setStatus(fmt.Sprintf("Dropped: %s", job.Description))
+}
+
+func (m *queueModel) retryJob() tea.Cmd {
+	job, exists := m.selectedJob()
+	if !exists {
+		return nil
+	}
+
+	run, err := cmd.Jobs.Retry(job.Id)
+	if err != nil {
+		return setStatus(fmt.Sprintf("Could not retry job: %s", err))
+	}
+
+	return run
+}
+
+func (m *queueModel) clearFinished() tea.Cmd {
+	cmd.Jobs.ClearFinished()
+	return nil
+}
+
+func (m *queueModel) toggleHotkeys() tea.Cmd {
+	m.isViewingHotkeys = !m.isViewingHotkeys
+	if m.isViewingHotkeys {
+		m.listViewport.Height = m.fullHeight - m.hotkeyViewport.Height
+	} else {
+		m.listViewport.Height = m.fullHeight
+	}
+
+	buildSortedHotkeyList(&m.hotkeyViewport, m.hotkeys, m.hotkeysOrdered)
+	scrollIntoView(&m.listViewport, m.cursor)
+
+	return nil
+}
+
+func (m *queueModel) Hotkeys() map[string]types.HotkeyBinding {
+	return m.hotkeys
+}
+
+func (m *queueModel) SearchInput() *textinput.Model {
+	return &m.searchInput
+}
+
+func (m *queueModel) AddCommand(cmd tea.Cmd) {
+	m.cmds = append(m.cmds, cmd)
+}
+
+func (m *queueModel) ResetCursor() {
+	m.cursor = 0
+}
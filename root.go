@@ -1,6 +1,9 @@
 package main
 
 import (
+	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 
@@ -18,8 +21,19 @@ type rootModel struct {
 
 	tabs    []types.ChildModel
 	spinner spinner.Model
+	dbs     *packageDatabases
+	log     *commandLog
 
-	runningCommandsCount int
+	runningCommands map[int]struct{}
+
+	dialog      *dialogModel
+	preview     *transactionPreview
+	transaction transactionPanel
+	statusText  string
+
+	// Dialogs opened while another was showing wait their turn, so a
+	// prompt is never lost before it's answered.
+	queuedDialogs []*dialogModel
 
 	termWidth  int
 	termHeight int
@@ -31,6 +45,8 @@ const (
 	PackageList types.StreamTarget = iota
 	PackageInfo
 	Background
+	Preview
+	PackageFiles
 )
 
 var (
@@ -41,6 +57,8 @@ var (
 	panelStyle           = styles.PanelStyle
 	reducedEmphasisStyle = styles.ReducedEmphasisStyle
 	selectedStyle        = styles.SelectedStyle
+	matchStyle           = styles.MatchStyle
+	selectedMatchStyle   = styles.SelectedMatchStyle
 	windowStyle          = styles.WindowStyle
 	tabStyle             = styles.TabStyle
 	selectedTabStyle     = styles.SelectedTabStyle
@@ -65,8 +83,16 @@ var header = defaultStyle.Foreground(yellow).Render(`
  ╚═╝         ╚═╝     ╚═════╝  ╚═╝`)
 
 func initialModel() *rootModel {
-	installedTab := initialInstalledModel()
-	browseTab := initialBrowseModel()
+	dbs := &packageDatabases{}
+	installedTab := initialInstalledModel(dbs)
+	browseTab := initialBrowseModel(dbs)
+	updatesTab := initialUpdatesModel(dbs)
+	orphansTab := initialOrphansModel(dbs)
+
+	queueTab := initialQueueModel()
+
+	log := newCommandLog()
+	logTab := initialLogModel(log)
 
 	spinner := spinner.New(
 		spinner.WithSpinner(
@@ -82,9 +108,14 @@ func initialModel() *rootModel {
 
 	return &rootModel{
 		selectedTab: 0,
-		tabs:        []types.ChildModel{installedTab, browseTab},
+		tabs:        []types.ChildModel{installedTab, browseTab, updatesTab, orphansTab, queueTab, logTab},
 		spinner:     spinner,
+		dbs:         dbs,
+		log:         log,
+		transaction: newTransactionPanel(),
 		cmds:        make([]tea.Cmd, 0, 6),
+
+		runningCommands: make(map[int]struct{}),
 	}
 }
 
@@ -97,7 +128,7 @@ func (m *rootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case spinner.TickMsg:
-		if m.runningCommandsCount > 0 {
+		if len(m.runningCommands) > 0 {
 			updated, cmd := m.spinner.Update(msg)
 			m.spinner = updated
 			m.cmds = append(m.cmds, cmd)
@@ -122,25 +153,130 @@ func (m *rootModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case types.HotkeyPressedMsg:
 		m.cmds = append(m.cmds, msg.Hotkey.Command())
 
-	case cmd.CommandStartMsg, cmd.CommandChunkMsg, cmd.CommandDoneMsg, installedInitMsg, browseInitMsg:
+	case localDbLoadedMsg:
+		m.dbs.Update(msg)
+
+		if msg.db != nil && len(msg.db.Errors) > 0 {
+			m.statusText = fmt.Sprintf("Skipped %d unreadable packages in the local database", len(msg.db.Errors))
+		}
+
+	case syncIndexLoadedMsg:
+		m.dbs.Update(msg)
+
+		if msg.idx != nil && len(msg.idx.Errors) > 0 {
+			repos := slices.Sorted(maps.Keys(msg.idx.Errors))
+			m.statusText = fmt.Sprintf("Skipped unreadable sync databases: %s", strings.Join(repos, ", "))
+		}
+
+	case selectTabMsg:
+		for i, tab := range m.tabs {
+			if tab.Title() == msg.title && i != m.selectedTab {
+				m.selectedTab = i
+				m.cmds = append(m.cmds, m.InitSelectedTab())
+			}
+		}
+
+	case openDialogMsg:
+		m.showDialog(msg.dialog)
+		return m, nil
+
+	case statusMsg:
+		m.statusText = string(msg)
+		return m, nil
+
+	case cmd.LockWaitMsg:
+		if len(msg.Holders) > 0 {
+			m.statusText = fmt.Sprintf("Waiting for lock held by PID %s", joinInts(msg.Holders, ", "))
+		} else {
+			m.statusText = "Waiting for package database lock"
+		}
+		return m, nil
+
+	case cmd.StaleLockMsg:
+		m.statusText = "Package database lock is stale"
+		m.showDialog(newConfirmDialog(
+			"Stale database lock",
+			fmt.Sprintf("%s exists but no running process holds it, so a previous transaction "+
+				"probably crashed. Only remove it if you're sure no package manager is running.\n\n"+
+				"Remove the lock file?", msg.Path),
+			func() tea.Cmd { return cmd.ClearStaleLock(Background) },
+		))
+		return m, nil
+
+	case previewTransactionMsg:
+		m.preview = newTransactionPreview(msg.tx, m.dbs)
+		m.statusText = "Preparing transaction..."
+		return m, nil
+
+	case cmd.PromptMsg:
+		m.showDialog(newPromptDialog(msg))
+		return m, nil
+
+	case cmd.ProgressMsg, cmd.HookMsg:
+		m.transaction.Update(msg)
+		return m, nil
+
+	case cmd.LockClearedMsg:
+		if msg.Err != nil {
+			m.statusText = fmt.Sprintf("Could not remove lock: %s", msg.Err)
+		} else {
+			m.statusText = "Removed stale lock, try again"
+		}
+		return m, nil
+
+	case cmd.CommandStartMsg, cmd.CommandChunkMsg, cmd.CommandDoneMsg, installedInitMsg, browseInitMsg, updatesInitMsg, orphansInitMsg, queueInitMsg, logInitMsg:
+		m.log.Record(msg)
+
+		if isPreviewMsg(msg) {
+			if m.preview == nil {
+				return m, nil
+			}
+
+			if dialog := m.preview.Update(msg); dialog != nil {
+				m.preview = nil
+				m.showDialog(dialog)
+				m.statusText = ""
+			}
+			return m, nil
+		}
+
+		m.transaction.Update(msg)
+
 		switch msg := msg.(type) {
 		case cmd.CommandStartMsg:
 			if isLongRunning(msg.Target) {
-				m.runningCommandsCount++
+				m.statusText = ""
+				m.runningCommands[msg.CommandId] = struct{}{}
 				m.cmds = append(m.cmds, m.spinner.Tick)
 			}
 
 		case cmd.CommandDoneMsg:
 			if isLongRunning(msg.Target) {
-				m.runningCommandsCount--
+				delete(m.runningCommands, msg.CommandId)
+			}
+
+			m.cmds = append(m.cmds, cmd.Jobs.Finished(msg))
+			m.dropPrompts(msg.CommandId)
+
+			if msg.Interrupted {
+				m.statusText = "Command interrupted"
 			}
 		}
 
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c":
+		if msg.String() == "ctrl+c" {
 			return m, tea.Quit
+		}
+
+		if m.dialog != nil {
+			cmd := m.dialog.Update(msg)
+			if m.dialog.isClosed {
+				m.closeDialog()
+			}
+			return m, cmd
+		}
 
+		switch msg.String() {
 		case "tab":
 			if m.selectedTab < len(m.tabs)-1 {
 				m.selectedTab++
@@ -172,11 +308,25 @@ func (m *rootModel) View() string {
 		renderedTabs = append(renderedTabs, renderTab(m, tab.Title(), i))
 	}
 
-	tabPanel := windowStyle.Render(lipgloss.JoinHorizontal(lipgloss.Left, renderedTabs...))
+	tabRow := lipgloss.JoinHorizontal(lipgloss.Left, renderedTabs...)
+	tabPanel := windowStyle.Render(lipgloss.JoinHorizontal(lipgloss.Center, tabRow, m.statusView()))
 
 	view := lipgloss.JoinVertical(lipgloss.Left, titlePanel, tabPanel)
 
 	tabView := m.tabs[m.selectedTab].View()
+	if m.transaction.IsVisible() {
+		tabView = overlayBottom(tabView, m.transaction.View(lipgloss.Width(tabView)))
+	}
+
+	if m.dialog != nil {
+		tabView = lipgloss.Place(
+			lipgloss.Width(tabView),
+			lipgloss.Height(tabView),
+			lipgloss.Center,
+			lipgloss.Center,
+			m.dialog.View(lipgloss.Width(tabView), lipgloss.Height(tabView)),
+		)
+	}
 
 	lengthToSelectedTabStart := lipgloss.Width(strings.Join(renderedTabs[0:m.selectedTab], ""))
 	tabView = withTabConnectorTopBorder(tabView, lengthToSelectedTabStart, lipgloss.Width(renderedTabs[m.selectedTab]))
@@ -186,10 +336,49 @@ func (m *rootModel) View() string {
 	return windowStyle.MaxWidth(m.termWidth).Height(m.termHeight).Render(view)
 }
 
+// showDialog opens d, or queues it if another dialog is open.
+func (m *rootModel) showDialog(d *dialogModel) {
+	if m.dialog == nil {
+		m.dialog = d
+		return
+	}
+	m.queuedDialogs = append(m.queuedDialogs, d)
+}
+
+// closeDialog closes the open dialog, opening the next queued one.
+func (m *rootModel) closeDialog() {
+	m.dialog = nil
+	if len(m.queuedDialogs) > 0 {
+		m.dialog = m.queuedDialogs[0]
+		m.queuedDialogs = m.queuedDialogs[1:]
+	}
+}
+
+// dropPrompts closes prompts from a command which has finished, since
+// there's nothing left to answer them.
+func (m *rootModel) dropPrompts(commandId int) {
+	m.queuedDialogs = slices.DeleteFunc(m.queuedDialogs, func(d *dialogModel) bool {
+		return d.isPromptFor(commandId)
+	})
+
+	if m.dialog != nil && m.dialog.isPromptFor(commandId) {
+		m.closeDialog()
+	}
+}
+
 func (m *rootModel) InitSelectedTab() tea.Cmd {
 	return m.tabs[m.selectedTab].Init()
 }
 
+func (m *rootModel) statusView() string {
+	var status string
+	if len(m.runningCommands) > 0 {
+		status = m.spinner.View() + " "
+	}
+
+	return status + reducedEmphasisStyle.PaddingLeft(2).Render(m.statusText)
+}
+
 func renderTab(m *rootModel, title string, index int) (renderedTab string) {
 	if m.selectedTab == index {
 		renderedTab = selectedTabStyle.Render(title)
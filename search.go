@@ -0,0 +1,200 @@
+package main
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"ptui/fuzzy"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+type searchMode uint8
+
+const (
+	searchFuzzy searchMode = iota
+	searchExact
+	searchRegex
+)
+
+func (s searchMode) String() string {
+	switch s {
+	case searchExact:
+		return "Exact"
+	case searchRegex:
+		return "Regex"
+	default:
+		return "Fuzzy"
+	}
+}
+
+func (s searchMode) prompt() string {
+	return strings.ToLower(s.String()) + "> "
+}
+
+func nextSearchMode(current searchMode) searchMode {
+	return (current + 1) % 3
+}
+
+// searchMatch is a line that matched the search, and the rune offsets
+// of the characters to highlight in it.
+type searchMatch struct {
+	index     int
+	score     int
+	positions []int
+}
+
+// searchLines filters lines of the form "name\n" by pattern, ignoring
+// case. Fuzzy matches are ranked best first; the other modes keep the
+// order of lines.
+func searchLines(lines []string, pattern string, mode searchMode) ([]searchMatch, error) {
+	var regex *regexp.Regexp
+	if mode == searchRegex && pattern != "" {
+		var err error
+		if regex, err = compileSearchRegex(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	// Lowering rune by rune, rather than with strings.ToLower, keeps
+	// every rune in place, so offsets in the lowered name still apply
+	// to the original.
+	lowerPattern := strings.Map(unicode.ToLower, pattern)
+
+	matches := make([]searchMatch, 0, len(lines))
+	for i, line := range lines {
+		name, _, _ := strings.Cut(line, "\n")
+
+		match := searchMatch{index: i}
+		switch {
+		case pattern == "":
+
+		case mode == searchExact:
+			lowerName := strings.Map(unicode.ToLower, name)
+			start := strings.Index(lowerName, lowerPattern)
+			if start < 0 {
+				continue
+			}
+			match.positions = runeRange(lowerName, start, start+len(lowerPattern))
+
+		case mode == searchRegex:
+			loc := regex.FindStringIndex(name)
+			if loc == nil {
+				continue
+			}
+			match.positions = runeRange(name, loc[0], loc[1])
+
+		default:
+			found, ok := fuzzy.Find(pattern, name)
+			if !ok {
+				continue
+			}
+			match.score, match.positions = found.Score, found.Positions
+		}
+
+		matches = append(matches, match)
+	}
+
+	// Like fzf, equally good matches favour the shorter line.
+	if mode == searchFuzzy && pattern != "" {
+		slices.SortStableFunc(matches, func(a, b searchMatch) int {
+			return cmp.Or(
+				cmp.Compare(b.score, a.score),
+				cmp.Compare(len(lines[a.index]), len(lines[b.index])),
+			)
+		})
+	}
+
+	return matches, nil
+}
+
+// compileSearchRegex compiles a regular expression which ignores case.
+func compileSearchRegex(pattern string) (*regexp.Regexp, error) {
+	// Checking the pattern alone keeps the flag out of errors.
+	if _, err := regexp.Compile(pattern); err != nil {
+		return nil, err
+	}
+	return regexp.MustCompile("(?i)" + pattern), nil
+}
+
+// rankLines is searchLines for lines which have already been found by
+// a search that looks further than names. Lines whose names match come
+// first, ranked and highlighted, followed by the rest in order.
+func rankLines(lines []string, pattern string, mode searchMode) ([]searchMatch, error) {
+	matches, err := searchLines(lines, pattern, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	isMatched := make([]bool, len(lines))
+	for _, match := range matches {
+		isMatched[match.index] = true
+	}
+
+	for i := range lines {
+		if !isMatched[i] {
+			matches = append(matches, searchMatch{index: i})
+		}
+	}
+
+	return matches, nil
+}
+
+// runeRange converts the byte range [start, end) of text into the
+// offsets of the runes it covers.
+func runeRange(text string, start int, end int) []int {
+	first := utf8.RuneCountInString(text[:min(start, len(text))])
+	last := utf8.RuneCountInString(text[:min(end, len(text))])
+
+	positions := make([]int, 0, last-first)
+	for i := first; i < last; i++ {
+		positions = append(positions, i)
+	}
+	return positions
+}
+
+// highlightMatches renders text in style, with the runes at positions
+// in highlight. Runs of matches are rendered together to keep the
+// escape codes down.
+func highlightMatches(text string, positions []int, style lipgloss.Style, highlight lipgloss.Style) string {
+	if len(positions) == 0 {
+		return style.Render(text)
+	}
+
+	var builder strings.Builder
+	var run []rune
+	isRunHighlighted := false
+
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		if isRunHighlighted {
+			builder.WriteString(highlight.Render(string(run)))
+		} else {
+			builder.WriteString(style.Render(string(run)))
+		}
+		run = run[:0]
+	}
+
+	next := 0
+	for i, r := range []rune(text) {
+		isHighlighted := next < len(positions) && positions[next] == i
+		if isHighlighted {
+			next++
+		}
+
+		if isHighlighted != isRunHighlighted {
+			flush()
+			isRunHighlighted = isHighlighted
+		}
+		run = append(run, r)
+	}
+	flush()
+
+	return builder.String()
+}
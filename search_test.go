@@ -0,0 +1,185 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+var testLines = []string{
+	"vim\n",
+	"gvim\n",
+	"neovim\n",
+	"vim-airline\n",
+	"Visual-Studio-Code\n",
+	"vifm\n",
+}
+
+// names returns the lines matches point to, without their newlines.
+func names(lines []string, matches []searchMatch) []string {
+	var names []string
+	for _, match := range matches {
+		names = append(names, lines[match.index][:len(lines[match.index])-1])
+	}
+	return names
+}
+
+func TestSearchExact(t *testing.T) {
+	matches, err := searchLines(testLines, "VIM", searchExact)
+	if err != nil {
+		t.Fatalf("searchLines: %v", err)
+	}
+
+	// Exact matches keep their order.
+	if got, want := names(testLines, matches), []string{"vim", "gvim", "neovim", "vim-airline"}; !slices.Equal(got, want) {
+		t.Errorf("matched %v, want %v", got, want)
+	}
+	if got := matches[2].positions; !slices.Equal(got, []int{3, 4, 5}) {
+		t.Errorf("neovim positions = %v, want [3 4 5]", got)
+	}
+}
+
+func TestSearchRegex(t *testing.T) {
+	matches, err := searchLines(testLines, "^v.*m$", searchRegex)
+	if err != nil {
+		t.Fatalf("searchLines: %v", err)
+	}
+	if got, want := names(testLines, matches), []string{"vim", "vifm"}; !slices.Equal(got, want) {
+		t.Errorf("matched %v, want %v", got, want)
+	}
+
+	// Regular expressions ignore case too.
+	matches, err = searchLines(testLines, "studio", searchRegex)
+	if err != nil {
+		t.Fatalf("searchLines: %v", err)
+	}
+	if len(matches) != 1 || !slices.Equal(matches[0].positions, []int{7, 8, 9, 10, 11, 12}) {
+		t.Errorf("matches = %+v, want Visual-Studio-Code at 7-12", matches)
+	}
+}
+
+func TestSearchRegexInvalid(t *testing.T) {
+	_, err := searchLines(testLines, "vim(", searchRegex)
+	if err == nil {
+		t.Fatal("an invalid regex was accepted")
+	}
+
+	// The error is about the user's pattern, not the one compiled.
+	if want := "error parsing regexp: missing closing ): `vim(`"; err.Error() != want {
+		t.Errorf("err = %q, want %q", err, want)
+	}
+}
+
+func TestSearchFuzzy(t *testing.T) {
+	matches, err := searchLines(testLines, "vim", searchFuzzy)
+	if err != nil {
+		t.Fatalf("searchLines: %v", err)
+	}
+
+	// Prefixes come first, the shorter ahead, even if the rest is
+	// scattered, then the matches within words.
+	want := []string{"vim", "vim-airline", "vifm", "gvim", "neovim"}
+	if got := names(testLines, matches); !slices.Equal(got, want) {
+		t.Errorf("matched %v, want %v", got, want)
+	}
+	if got := matches[2].positions; !slices.Equal(got, []int{0, 1, 3}) {
+		t.Errorf("vifm positions = %v, want [0 1 3]", got)
+	}
+}
+
+// Without a pattern, every line matches in order, whatever the mode.
+func TestSearchEmpty(t *testing.T) {
+	for _, mode := range []searchMode{searchFuzzy, searchExact, searchRegex} {
+		matches, err := searchLines(testLines, "", mode)
+		if err != nil {
+			t.Fatalf("%v: searchLines: %v", mode, err)
+		}
+		for i, match := range matches {
+			if match.index != i || len(match.positions) != 0 {
+				t.Errorf("%v: match %d = %+v", mode, i, match)
+			}
+		}
+		if len(matches) != len(testLines) {
+			t.Errorf("%v: %d matches, want %d", mode, len(matches), len(testLines))
+		}
+	}
+}
+
+// Positions are runes, so they line up with what's highlighted however
+// many bytes the characters before take.
+func TestSearchMultibyte(t *testing.T) {
+	lines := []string{
+		"日本語-fonts\n",
+		"İstanbul-fonts\n",
+		"noto-fonts-cjk\n",
+	}
+
+	tests := []struct {
+		pattern   string
+		mode      searchMode
+		positions [][]int
+	}{
+		{"fonts", searchExact, [][]int{{4, 5, 6, 7, 8}, {9, 10, 11, 12, 13}, {5, 6, 7, 8, 9}}},
+		{"FONTS", searchRegex, [][]int{{4, 5, 6, 7, 8}, {9, 10, 11, 12, 13}, {5, 6, 7, 8, 9}}},
+		// İ lowers to a shorter i, which mustn't shift what follows.
+		{"istanbul-f", searchExact, [][]int{nil, {0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, nil}},
+		{"語-f", searchExact, [][]int{{2, 3, 4}, nil, nil}},
+		{"語f", searchFuzzy, [][]int{{2, 4}, nil, nil}},
+	}
+
+	for _, test := range tests {
+		matches, err := searchLines(lines, test.pattern, test.mode)
+		if err != nil {
+			t.Fatalf("searchLines(%q): %v", test.pattern, err)
+		}
+
+		got := make([][]int, len(lines))
+		for _, match := range matches {
+			got[match.index] = match.positions
+		}
+		for i := range lines {
+			if !slices.Equal(got[i], test.positions[i]) {
+				t.Errorf("%v %q in %q: positions = %v, want %v", test.mode, test.pattern, lines[i], got[i], test.positions[i])
+			}
+		}
+	}
+}
+
+func TestRankLines(t *testing.T) {
+	matches, err := rankLines(testLines, "code", searchExact)
+	if err != nil {
+		t.Fatalf("rankLines: %v", err)
+	}
+
+	// Lines that don't match by name are kept, unhighlighted, at the end.
+	want := []string{"Visual-Studio-Code", "vim", "gvim", "neovim", "vim-airline", "vifm"}
+	if got := names(testLines, matches); !slices.Equal(got, want) {
+		t.Errorf("ranked %v, want %v", got, want)
+	}
+	for _, match := range matches[1:] {
+		if len(match.positions) != 0 {
+			t.Errorf("unmatched line %d has positions %v", match.index, match.positions)
+		}
+	}
+}
+
+func TestRuneRange(t *testing.T) {
+	tests := []struct {
+		text  string
+		start int
+		end   int
+		want  []int
+	}{
+		{"vim", 0, 3, []int{0, 1, 2}},
+		{"vim", 1, 1, []int{}},
+		{"日本語-fonts", 10, 15, []int{4, 5, 6, 7, 8}},
+		{"日本語", 3, 6, []int{1}},
+		// Ranges past the end are cut short.
+		{"vim", 2, 10, []int{2}},
+	}
+
+	for _, test := range tests {
+		if got := runeRange(test.text, test.start, test.end); !slices.Equal(got, test.want) {
+			t.Errorf("runeRange(%q, %d, %d) = %v, want %v", test.text, test.start, test.end, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"ptui/alpm"
+)
+
+type sortMode uint8
+
+const (
+	sortByName sortMode = iota
+	sortByVersion
+	sortByRepository
+	sortBySize
+	sortByInstallDate
+	sortByReason
+	sortByLastUpgrade
+	sortByDependents
+)
+
+func (s sortMode) String() string {
+	switch s {
+	case sortByVersion:
+		return "Version"
+	case sortByRepository:
+		return "Repository"
+	case sortBySize:
+		return "Size"
+	case sortByInstallDate:
+		return "Install Date"
+	case sortByReason:
+		return "Install Reason"
+	case sortByLastUpgrade:
+		return "Last Upgrade"
+	case sortByDependents:
+		return "Dependents"
+	default:
+		return "Name"
+	}
+}
+
+// nextSortMode cycles through the modes a tab supports.
+func nextSortMode(current sortMode, modes []sortMode) sortMode {
+	i := slices.Index(modes, current)
+	return modes[(i+1)%len(modes)]
+}
+
+// sortBorderText describes the sort for a list's bottom border.
+func sortBorderText(mode sortMode, isReversed bool) string {
+	if isReversed {
+		return fmt.Sprintf("(%s ▼)", mode)
+	}
+	return fmt.Sprintf("(%s ▲)", mode)
+}
+
+func sortStatus(mode sortMode, isReversed bool) string {
+	if isReversed {
+		return fmt.Sprintf("Sorted by %s, descending", mode)
+	}
+	return fmt.Sprintf("Sorted by %s", mode)
+}
+
+// sortPackages orders pkgs in place, falling back to their names to
+// keep the order stable between loads. Install details come from the
+// local database, so sync packages can be sorted by them too.
+func (d *packageDatabases) sortPackages(pkgs []*alpm.Package, mode sortMode, isReversed bool) {
+	slices.SortStableFunc(pkgs, func(a, b *alpm.Package) int {
+		result := 0
+		switch mode {
+		case sortByVersion:
+			result = alpm.Vercmp(a.Version, b.Version)
+		case sortByRepository:
+			result = strings.Compare(d.repository(a), d.repository(b))
+		case sortBySize:
+			result = cmp.Compare(a.InstalledSize, b.InstalledSize)
+		case sortByInstallDate:
+			result = d.installDate(a).Compare(d.installDate(b))
+		case sortByReason:
+			result = cmp.Compare(d.installReason(a), d.installReason(b))
+		case sortByLastUpgrade:
+			result = d.lastUpgrade(a).Compare(d.lastUpgrade(b))
+		case sortByDependents:
+			result = cmp.Compare(len(a.RequiredBy), len(b.RequiredBy))
+		}
+
+		if result == 0 {
+			result = strings.Compare(a.Name, b.Name)
+		}
+		if isReversed {
+			result = -result
+		}
+		return result
+	})
+}
+
+func (d *packageDatabases) installDate(pkg *alpm.Package) time.Time {
+	if installed, exists := d.installedPackage(pkg.Name); exists {
+		return installed.InstallDate
+	}
+	return time.Time{}
+}
+
+// installReason orders explicitly installed packages before
+// dependencies, and packages which aren't installed last.
+func (d *packageDatabases) installReason(pkg *alpm.Package) int {
+	if installed, exists := d.installedPackage(pkg.Name); exists {
+		return int(installed.Reason)
+	}
+	return math.MaxInt
+}
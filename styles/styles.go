@@ -49,6 +49,11 @@ var (
 	ErrorStyle    = DefaultStyle.Foreground(lipgloss.Color("#FD0000"))
 	SuccessStyle  = DefaultStyle.Foreground(lipgloss.Color("#00FF00"))
 
+	// Yellow can't be read on the selection, so matches in it are
+	// underlined instead.
+	MatchStyle         = DefaultStyle.Foreground(Yellow).Bold(true)
+	SelectedMatchStyle = SelectedStyle.Underline(true).Bold(true)
+
 	ReducedEmphasisStyle = DefaultStyle.Foreground(lipgloss.Color("242"))
 	HotkeyStyle          = ReducedEmphasisStyle.Underline(true).PaddingLeft(1)
 )
@@ -0,0 +1,533 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	cmd "ptui/command"
+	"ptui/styles"
+	"ptui/types"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type updatesInitMsg struct{}
+
+// pendingUpdate is one upgradable package. Sizes are only known when
+// the databases can be read directly, rather than through pacman -Qu.
+type pendingUpdate struct {
+	name       string
+	oldVersion string
+	newVersion string
+	repository string
+
+	downloadSize int64
+	sizeDelta    int64
+	hasSizes     bool
+
+	isSelected bool
+}
+
+type updatesModel struct {
+	title string
+
+	listViewport   viewport.Model
+	hotkeyViewport viewport.Model
+	searchInput    textinput.Model
+
+	dbs     *packageDatabases
+	updates []pendingUpdate
+
+	fullHeight int
+	cursor     int
+	listCmdId  int
+
+	hasViewportDimensions  bool
+	isFinishedReadingLines bool
+	isViewingHotkeys       bool
+
+	hotkeys        map[string]types.HotkeyBinding
+	hotkeysOrdered []string
+
+	startRoutes types.MessageRouter[*updatesModel, cmd.CommandStartMsg]
+	chunkRoutes types.MessageRouter[*updatesModel, cmd.CommandChunkMsg]
+	doneRoutes  types.MessageRouter[*updatesModel, cmd.CommandDoneMsg]
+
+	cmds []tea.Cmd
+}
+
+const partialUpgradeWarning = "Partial upgrades are unsupported on Arch Linux and can leave " +
+	"packages with mismatched library versions."
+
+func initialUpdatesModel(dbs *packageDatabases) *updatesModel {
+	model := updatesModel{
+		title:   "Updates",
+		dbs:     dbs,
+		hotkeys: make(map[string]types.HotkeyBinding),
+
+		startRoutes: types.MessageRouter[*updatesModel, cmd.CommandStartMsg]{
+			PackageList: func(m *updatesModel, msg cmd.CommandStartMsg) tea.Cmd {
+				m.isFinishedReadingLines = false
+				m.listCmdId = msg.CommandId
+				m.updates = m.updates[:0]
+
+				m.listViewport.SetContent("Checking for updates...")
+				return nil
+			},
+		},
+		chunkRoutes: types.MessageRouter[*updatesModel, cmd.CommandChunkMsg]{
+			PackageList: func(m *updatesModel, msg cmd.CommandChunkMsg) tea.Cmd {
+				if msg.CommandId != m.listCmdId || msg.IsError {
+					return nil
+				}
+
+				for _, line := range msg.Lines {
+					if update, ok := parseQueryUpgradeLine(line); ok {
+						m.updates = append(m.updates, update)
+					}
+				}
+
+				m.buildList()
+				return nil
+			},
+		},
+		doneRoutes: types.MessageRouter[*updatesModel, cmd.CommandDoneMsg]{
+			PackageList: func(m *updatesModel, msg cmd.CommandDoneMsg) tea.Cmd {
+				if msg.CommandId != m.listCmdId {
+					return nil
+				}
+
+				m.isFinishedReadingLines = true
+				m.buildList()
+				return nil
+			},
+		},
+	}
+
+	model.createHotkey("H", "H", "Toggle Hotkeys", model.toggleHotkeys)
+	model.createHotkey(" ", "Space", "Toggle Selected", model.toggleSelected)
+	model.createHotkey("A", "A", "Select All", model.selectAll)
+	model.createHotkey("F", "F", "Refresh Databases", model.refreshDatabases)
+	model.createHotkey("enter", "Enter", "Upgrade Selected", model.upgradeSelected)
+	model.createHotkey("X", "X", "Cancel Transaction", cancelTransaction)
+
+	slices.SortFunc(model.hotkeysOrdered, func(a, b string) int {
+		hotkeyA := model.hotkeys[a]
+		hotkeyB := model.hotkeys[b]
+
+		return cmp.Compare(hotkeyA.Description, hotkeyB.Description)
+	})
+
+	return &model
+}
+
+func (m *updatesModel) createHotkey(key string, displayKey string, description string, action func() tea.Cmd) {
+	m.hotkeys[key] = types.HotkeyBinding{Shortcut: displayKey, Description: description, Command: action}
+	m.hotkeysOrdered = append(m.hotkeysOrdered, key)
+}
+
+func (m *updatesModel) Init() tea.Cmd {
+	return func() tea.Msg { return updatesInitMsg{} }
+}
+
+func (m *updatesModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m.cmds = m.cmds[:0]
+
+	switch msg := msg.(type) {
+	case updatesInitMsg:
+		m.cmds = append(m.cmds, m.checkForUpdates())
+
+	case localDbLoadedMsg, syncIndexLoadedMsg:
+		m.cmds = append(m.cmds, m.checkForUpdates())
+
+	case cmd.CommandStartMsg:
+		handler, exists := m.startRoutes[msg.Target]
+		if exists {
+			handler(m, msg)
+		}
+
+	case cmd.CommandChunkMsg:
+		handler, exists := m.chunkRoutes[msg.Target]
+		if exists {
+			handler(m, msg)
+		}
+
+	case cmd.CommandDoneMsg:
+		handler, exists := m.doneRoutes[msg.Target]
+		if exists {
+			handler(m, msg)
+		}
+
+	case types.ContentRectMsg:
+		// The root model determines the height for the tab panel, but
+		// the internal layout of the tab affects width usage via borders
+		// and margins. One line is kept for the summary.
+		msg.Width -= 4
+		msg.Height -= 1
+
+		if m.hasViewportDimensions {
+			m.fullHeight = msg.Height
+
+			m.listViewport.Width = msg.Width
+			m.listViewport.Height = msg.Height
+			if m.isViewingHotkeys {
+				m.listViewport.Height -= m.hotkeyViewport.Height
+			}
+
+			m.hotkeyViewport.Width = msg.Width
+			m.hotkeyViewport.Height = len(m.hotkeys)
+		} else {
+			m.fullHeight = msg.Height
+			m.listViewport = viewport.New(msg.Width, msg.Height)
+			m.hotkeyViewport = viewport.New(msg.Width, len(m.hotkeys))
+
+			m.hasViewportDimensions = true
+		}
+
+		m.buildList()
+
+	case tea.KeyMsg:
+		handleHotkeyAndSearch(m, msg)
+
+		switch msg.String() {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+				m.buildList()
+				scrollIntoView(&m.listViewport, m.cursor)
+			}
+		case "down", "j":
+			if m.cursor < len(m.updates)-1 {
+				m.cursor++
+				m.buildList()
+				scrollIntoView(&m.listViewport, m.cursor)
+			}
+		}
+	}
+
+	return m, tea.Batch(m.cmds...)
+}
+
+func (m *updatesModel) View() string {
+	if !m.hasViewportDimensions {
+		return "Initialising..."
+	}
+
+	listPanel := m.listViewport.View()
+
+	scrollbar := createScrollbar(
+		2,
+		m.cursor,
+		len(m.updates),
+		lipgloss.Height(listPanel),
+		m.isFinishedReadingLines,
+	)
+
+	var hotkeyPanel string
+	if m.isViewingHotkeys {
+		hotkeyPanel = panelStyle.Render(m.hotkeyViewport.View())
+	}
+
+	mainPanel := lipgloss.JoinHorizontal(lipgloss.Left, listPanel, scrollbar)
+	mainPanel = lipgloss.JoinVertical(lipgloss.Left, m.summaryView(), mainPanel, hotkeyPanel)
+
+	var statusText string
+	if len(m.updates) > 0 {
+		statusText = fmt.Sprintf(" %d of %d selected ", m.selectedCount(), len(m.updates))
+	} else {
+		statusText = " Up to date "
+	}
+
+	return createCustomBottomBorder(mainPanel, statusText, false)
+}
+
+func (m *updatesModel) Title() string {
+	return m.title
+}
+
+// summaryView totals the selected updates, warning when some have been
+// deselected since that makes the upgrade partial.
+func (m *updatesModel) summaryView() string {
+	if m.selectedCount() < len(m.updates) {
+		return styles.ErrorStyle.Width(m.listViewport.Width).MaxHeight(1).Render(partialUpgradeWarning)
+	}
+
+	var downloadSize, sizeDelta int64
+	hasSizes := true
+	for _, update := range m.updates {
+		if update.isSelected {
+			downloadSize += update.downloadSize
+			sizeDelta += update.sizeDelta
+			hasSizes = hasSizes && update.hasSizes
+		}
+	}
+
+	if !hasSizes || len(m.updates) == 0 {
+		return ""
+	}
+
+	return reducedEmphasisStyle.Render(fmt.Sprintf(
+		"Total download %s, installed size change %s",
+		formatSize(downloadSize),
+		formatSizeDelta(sizeDelta),
+	))
+}
+
+func (m *updatesModel) buildList() {
+	if len(m.updates) == 0 {
+		if m.isFinishedReadingLines {
+			m.listViewport.SetContent("No updates available")
+		}
+		return
+	}
+
+	if m.cursor >= len(m.updates) {
+		m.cursor = 0
+	}
+
+	var nameWidth, oldWidth, newWidth, repoWidth int
+	for _, update := range m.updates {
+		nameWidth = max(nameWidth, len(update.name))
+		oldWidth = max(oldWidth, len(update.oldVersion))
+		newWidth = max(newWidth, len(update.newVersion))
+		repoWidth = max(repoWidth, len(update.repository))
+	}
+
+	var builder strings.Builder
+	for i, update := range m.updates {
+		checkbox := "[ ]"
+		if update.isSelected {
+			checkbox = "[x]"
+		}
+
+		row := fmt.Sprintf(
+			"%s %-*s  %*s → %-*s  %-*s",
+			checkbox,
+			nameWidth, update.name,
+			oldWidth, update.oldVersion,
+			newWidth, update.newVersion,
+			repoWidth, update.repository,
+		)
+
+		if update.hasSizes {
+			row += fmt.Sprintf("  %10s  %12s", formatSize(update.downloadSize), formatSizeDelta(update.sizeDelta))
+		}
+
+		if i == m.cursor {
+			row = selectedStyle.Render(row)
+		} else if !update.isSelected {
+			row = reducedEmphasisStyle.Render(row)
+		}
+
+		builder.WriteString(row + "\n")
+	}
+
+	m.listViewport.SetContent(builder.String())
+}
+
+func (m *updatesModel) selectedCount() int {
+	count := 0
+	for _, update := range m.updates {
+		if update.isSelected {
+			count++
+		}
+	}
+	return count
+}
+
+func (m *updatesModel) toggleHotkeys() tea.Cmd {
+	m.isViewingHotkeys = !m.isViewingHotkeys
+	if m.isViewingHotkeys {
+		m.listViewport.Height = m.fullHeight - m.hotkeyViewport.Height
+	} else {
+		m.listViewport.Height = m.fullHeight
+	}
+
+	buildSortedHotkeyList(&m.hotkeyViewport, m.hotkeys, m.hotkeysOrdered)
+	scrollIntoView(&m.listViewport, m.cursor)
+
+	return nil
+}
+
+func (m *updatesModel) toggleSelected() tea.Cmd {
+	if len(m.updates) == 0 {
+		return nil
+	}
+
+	m.updates[m.cursor].isSelected = !m.updates[m.cursor].isSelected
+	m.buildList()
+	return nil
+}
+
+func (m *updatesModel) selectAll() tea.Cmd {
+	for i := range m.updates {
+		m.updates[i].isSelected = true
+	}
+
+	m.buildList()
+	return nil
+}
+
+// checkForUpdates compares the local and sync databases, or asks
+// pacman -Qu when they can't be read directly.
+func (m *updatesModel) checkForUpdates() tea.Cmd {
+	if m.dbs.local == nil && !m.dbs.isLocalUnavailable {
+		return loadLocalDb
+	}
+
+	if m.dbs.needsSyncIndex() {
+		return loadSyncIndex
+	}
+
+	if m.dbs.local == nil || m.dbs.sync == nil {
+		return cmd.NewCommand().
+			Operation("Q").
+			Options("u").
+			Target(PackageList).
+			Run()
+	}
+
+	// Keep whatever the user deselected across reloads.
+	deselected := make(map[string]bool)
+	for _, update := range m.updates {
+		deselected[update.name] = !update.isSelected
+	}
+
+	m.updates = m.updates[:0]
+	for _, pkg := range m.dbs.local.Packages {
+		newer, exists := m.dbs.upgradeFor(pkg)
+		if !exists {
+			continue
+		}
+
+		m.updates = append(m.updates, pendingUpdate{
+			name:         pkg.Name,
+			oldVersion:   pkg.Version,
+			newVersion:   newer.Version,
+			repository:   newer.Repository,
+			downloadSize: newer.DownloadSize,
+			sizeDelta:    newer.InstalledSize - pkg.InstalledSize,
+			hasSizes:     true,
+			isSelected:   !deselected[pkg.Name],
+		})
+	}
+
+	m.isFinishedReadingLines = true
+	m.buildList()
+
+	return nil
+}
+
+// refreshDatabases downloads fresh sync databases, then reloads them
+// to find any new updates. Like checkupdates, it refreshes a copy, since
+// refreshing the system's databases without upgrading would make any
+// later install a partial upgrade.
+func (m *updatesModel) refreshDatabases() tea.Cmd {
+	path, err := prepareRefreshDB()
+	if err != nil {
+		return setStatus(fmt.Sprintf("Could not refresh databases: %s", err))
+	}
+
+	return enqueueJob("Refresh databases", cmd.NewCommand().
+		Operation("S").
+		Options("y").
+		Arguments("--dbpath", path, "--logfile", "/dev/null").
+		Executor(cmd.NewFakerootExecutor("pacman")).
+		Target(Background).
+		Callback(func() tea.Cmd { return loadRefreshedSyncIndex(path) }))
+}
+
+// prepareRefreshDB returns the directory databases are refreshed in,
+// which shares the system's local database through a symlink. It's
+// kept between runs so only changed databases are downloaded.
+func prepareRefreshDB() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(cacheDir, "ptui", "db")
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return "", err
+	}
+
+	local := filepath.Join(path, "local")
+	if _, err := os.Lstat(local); errors.Is(err, fs.ErrNotExist) {
+		err = os.Symlink(filepath.Join(dbPath, "local"), local)
+	}
+	if err != nil && !errors.Is(err, fs.ErrExist) {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// upgradeSelected upgrades everything selected, asking for confirmation
+// first if anything was left out.
+func (m *updatesModel) upgradeSelected() tea.Cmd {
+	if len(m.updates) == 0 {
+		return nil
+	}
+
+	var ignored []string
+	for _, update := range m.updates {
+		if !update.isSelected {
+			ignored = append(ignored, update.name)
+		}
+	}
+
+	if len(ignored) == len(m.updates) {
+		return setStatus("No updates selected")
+	}
+
+	// The list may come from refreshed databases, so the system's must
+	// be refreshed too for the upgrade to install what it shows.
+	return previewTransaction(transaction{
+		kind:     upgradeTransaction,
+		ignored:  ignored,
+		refresh:  true,
+		callback: func() tea.Cmd { return tea.Batch(loadLocalDb, loadSyncIndex) },
+	})
+}
+
+func (m *updatesModel) Hotkeys() map[string]types.HotkeyBinding {
+	return m.hotkeys
+}
+
+func (m *updatesModel) SearchInput() *textinput.Model {
+	return &m.searchInput
+}
+
+func (m *updatesModel) AddCommand(cmd tea.Cmd) {
+	m.cmds = append(m.cmds, cmd)
+}
+
+func (m *updatesModel) ResetCursor() {
+	m.cursor = 0
+	m.buildList()
+}
+
+// parseQueryUpgradeLine reads a line of pacman -Qu output, such as
+// "bash 5.2.026-1 -> 5.2.026-2". Ignored packages are skipped.
+func parseQueryUpgradeLine(line string) (pendingUpdate, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || fields[2] != "->" || strings.Contains(line, "[ignored]") {
+		return pendingUpdate{}, false
+	}
+
+	return pendingUpdate{
+		name:       fields[0],
+		oldVersion: fields[1],
+		newVersion: fields[3],
+		isSelected: true,
+	}, true
+}
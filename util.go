@@ -1,9 +1,15 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"math"
+	"os"
+	cmd "ptui/command"
 	"ptui/types"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
@@ -37,8 +43,10 @@ func createScrollbar(width int, pos int, listLength int, contentHeight int, list
 	return str
 }
 
+// matchesSearch reports whether text contains search, ignoring case
+// like the other searches.
 func matchesSearch(text string, search string) (match bool) {
-	if search == "" || strings.Contains(text, search) {
+	if search == "" || strings.Contains(strings.ToLower(text), strings.ToLower(search)) {
 		return true
 	}
 
@@ -84,8 +92,8 @@ func handleHotkeyAndSearch(m types.PackageListModel, msg tea.KeyMsg) {
 
 	// In specific cases, "global" hotkeys should be consumed by the program
 	// rather than passed to the text input. If hotkeys are ever made configurable
-	// there'll need to be a way to resolve which one toggles the search.
-	if msgStr == "/" {
+	// there'll need to be a way to resolve which ones control the search.
+	if msgStr == "/" || msgStr == "ctrl+t" {
 		if hotkey, exists := hotkeys[msgStr]; exists {
 			m.AddCommand(func() tea.Msg { return types.HotkeyPressedMsg{Hotkey: hotkey} })
 			msgConsumed = true
@@ -185,3 +193,120 @@ func isLongRunning(t types.StreamTarget) bool {
 		return false
 	}
 }
+
+func joinInts(values []int, sep string) string {
+	strs := make([]string, len(values))
+	for i, v := range values {
+		strs[i] = strconv.Itoa(v)
+	}
+	return strings.Join(strs, sep)
+}
+
+// selectTabMsg switches to the tab with the given title.
+type selectTabMsg struct {
+	title string
+}
+
+func selectTab(title string) tea.Cmd {
+	return func() tea.Msg { return selectTabMsg{title: title} }
+}
+
+// statusMsg replaces the status text shown next to the tabs.
+type statusMsg string
+
+func setStatus(text string) tea.Cmd {
+	return func() tea.Msg { return statusMsg(text) }
+}
+
+// cancelTransaction interrupts the most recent transaction. Queries
+// finish by themselves, and each tab cancels its own when replacing it.
+func cancelTransaction() tea.Cmd {
+	handle, exists := cmd.LatestTransaction()
+	if !exists {
+		return setStatus("No running transaction")
+	}
+
+	argv := strings.Join(handle.Argv, " ")
+
+	sig, err := handle.Cancel()
+	switch {
+	case errors.Is(err, os.ErrPermission):
+		kill, err := handle.SignalThroughHelper(sig, Background)
+		if err != nil {
+			return setStatus(fmt.Sprintf("Could not cancel %s: %s", argv, err))
+		}
+		return tea.Batch(kill, setStatus(fmt.Sprintf("Sending %s to %s through %s", sig, argv, handle.Argv[0])))
+
+	case err != nil:
+		return setStatus(fmt.Sprintf("Could not cancel %s: %s", argv, err))
+
+	case sig == nil:
+		return setStatus(fmt.Sprintf("Cancelled %s before it started", argv))
+	}
+
+	return setStatus(fmt.Sprintf("Sent %s to %s", sig, argv))
+}
+
+// formatSize renders a byte count using the binary units pacman uses.
+func formatSize(bytes int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+
+	size := float64(bytes)
+	unit := 0
+	for math.Abs(size) >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+
+	return fmt.Sprintf("%.2f %s", size, units[unit])
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "None"
+	}
+	return t.Format("Mon 02 Jan 2006 15:04:05 MST")
+}
+
+func formatSizeDelta(bytes int64) string {
+	if bytes >= 0 {
+		return "+" + formatSize(bytes)
+	}
+	return "-" + formatSize(-bytes)
+}
+
+// truncate shortens plain text to at most width cells, marking the cut
+// with an ellipsis.
+func truncate(text string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+
+	runes := []rune(text)
+	if len(runes) <= width {
+		return text
+	}
+
+	return string(runes[:width-1]) + "…"
+}
+
+func padRight(text string, width int) string {
+	return text + strings.Repeat(" ", max(0, width-lipgloss.Width(text)))
+}
+
+// overlayBottom draws panel over the bottom of base, just above its
+// bottom border so that the status text stays visible. Both are
+// expected to be the same width.
+func overlayBottom(base string, panel string) string {
+	baseLines := strings.Split(base, "\n")
+	panelLines := strings.Split(panel, "\n")
+
+	start := max(0, len(baseLines)-len(panelLines)-1)
+	for i, line := range panelLines {
+		if start+i < len(baseLines) {
+			baseLines[start+i] = line
+		}
+	}
+
+	return strings.Join(baseLines, "\n")
+}